@@ -0,0 +1,237 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+// Conf namespace for command aliases, keyed by lowercase alias name.
+// Values are the text an alias expands to, in which $1 to $9 are replaced
+// by the words following the alias and $* by all of them. If there are no
+// $ references, the words following the alias are appended instead.
+const aliasNs = "aliases"
+
+// Aliases may expand to other aliases, but only this many times.
+const maxAliasDepth = 5
+
+var aliasArg = regexp.MustCompile(`\$([1-9*])`)
+
+// aliasSet caches aliases, since they're checked for every command.
+type aliasSet struct {
+	sync.Mutex
+	loaded bool
+	m      map[string]string
+}
+
+var aliases = &aliasSet{}
+
+// load must be called with the lock held.
+func (as *aliasSet) load() {
+	if as.loaded {
+		return
+	}
+	as.m = make(map[string]string)
+	for _, e := range conf.Ns(aliasNs).All() {
+		if exp, ok := e.Value.(string); ok {
+			as.m[e.Key] = exp
+		}
+	}
+	as.loaded = true
+}
+
+// snapshot returns a copy of the aliases that can be used without locking.
+func (as *aliasSet) snapshot() map[string]string {
+	as.Lock()
+	defer as.Unlock()
+	as.load()
+	m := make(map[string]string, len(as.m))
+	for name, exp := range as.m {
+		m[name] = exp
+	}
+	return m
+}
+
+// get returns the expansion of the alias name, if there is one.
+func (as *aliasSet) get(name string) (string, bool) {
+	as.Lock()
+	defer as.Unlock()
+	as.load()
+	exp, ok := as.m[strings.ToLower(name)]
+	return exp, ok
+}
+
+// resolve expands any alias at the start of txt.
+func (as *aliasSet) resolve(txt string) (string, error) {
+	as.Lock()
+	defer as.Unlock()
+	as.load()
+	return resolveAlias(as.m, txt)
+}
+
+// set stores an alias; an empty exp removes it.
+func (as *aliasSet) set(name, exp string) {
+	name = strings.ToLower(name)
+	as.Lock()
+	defer as.Unlock()
+	as.load()
+	if exp == "" {
+		conf.Ns(aliasNs).Delete(name)
+		delete(as.m, name)
+		return
+	}
+	conf.Ns(aliasNs).String(name, exp)
+	as.m[name] = exp
+}
+
+// list returns "name => expansion" for each alias, sorted by name.
+func (as *aliasSet) list() []string {
+	m := as.snapshot()
+	l := make([]string, 0, len(m))
+	for name, exp := range m {
+		l = append(l, fmt.Sprintf("%s => %s", name, exp))
+	}
+	sort.Strings(l)
+	return l
+}
+
+// matchAlias finds the longest alias that txt starts with, on a word
+// boundary, and returns it along with the text following it.
+func matchAlias(m map[string]string, txt string) (name, rest string, ok bool) {
+	lower := strings.ToLower(txt)
+	for a := range m {
+		if len(a) <= len(name) || !strings.HasPrefix(lower, a) {
+			continue
+		}
+		if len(lower) == len(a) || lower[len(a)] == ' ' {
+			name, rest, ok = a, strings.TrimSpace(txt[len(a):]), true
+		}
+	}
+	return
+}
+
+// expandAlias fills in tmpl with the words in args.
+func expandAlias(name, tmpl, args string) (string, error) {
+	words := strings.Fields(args)
+	refs := aliasArg.FindAllStringSubmatch(tmpl, -1)
+	if len(refs) == 0 {
+		return strings.TrimSpace(tmpl + " " + args), nil
+	}
+	need := 0
+	for _, ref := range refs {
+		if n, err := strconv.Atoi(ref[1]); err == nil && n > need {
+			need = n
+		}
+	}
+	if len(words) < need {
+		return "", fmt.Errorf("'%s' needs %d argument(s), got %d.",
+			name, need, len(words))
+	}
+	return aliasArg.ReplaceAllStringFunc(tmpl, func(ref string) string {
+		if ref == "$*" {
+			return strings.Join(words, " ")
+		}
+		n, _ := strconv.Atoi(ref[1:])
+		return words[n-1]
+	}), nil
+}
+
+// checkChain fails if the last alias in chain appears earlier in it,
+// or if the chain is too long.
+func checkChain(chain []string) error {
+	name := chain[len(chain)-1]
+	for _, seen := range chain[:len(chain)-1] {
+		if seen == name {
+			return fmt.Errorf("Alias loop: %s.", strings.Join(chain, " => "))
+		}
+	}
+	if len(chain) > maxAliasDepth {
+		return fmt.Errorf("Aliases nested too deeply: %s.",
+			strings.Join(chain, " => "))
+	}
+	return nil
+}
+
+// resolveAlias repeatedly expands aliases at the start of txt until it
+// no longer starts with one, failing if an alias refers back to itself.
+func resolveAlias(m map[string]string, txt string) (string, error) {
+	var chain []string
+	for {
+		name, rest, ok := matchAlias(m, txt)
+		if !ok {
+			return txt, nil
+		}
+		chain = append(chain, name)
+		if err := checkChain(chain); err != nil {
+			return "", err
+		}
+		var err error
+		if txt, err = expandAlias(name, m[name], rest); err != nil {
+			return "", err
+		}
+	}
+}
+
+// aliasTarget follows the alias name through m to the text it finally
+// expands to, without filling in arguments.
+func aliasTarget(m map[string]string, name string) (string, error) {
+	chain, txt := []string{name}, m[name]
+	for {
+		next, _, ok := matchAlias(m, txt)
+		if !ok {
+			return txt, nil
+		}
+		chain = append(chain, next)
+		if err := checkChain(chain); err != nil {
+			return "", err
+		}
+		txt = m[next]
+	}
+}
+
+// splitQuoted splits off the first argument of s, which is either a
+// double-quoted string or a single word, and returns it and the rest.
+func splitQuoted(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, `"`) {
+		if i := strings.IndexByte(s, ' '); i != -1 {
+			return s[:i], strings.TrimSpace(s[i:]), nil
+		}
+		return s, "", nil
+	}
+	end := strings.IndexByte(s[1:], '"')
+	if end == -1 {
+		return "", "", fmt.Errorf("Missing closing quote in %s", s)
+	}
+	return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+}
+
+// parseAlias parses `"<name>" "<expansion>"`. Quotes are optional for a
+// single-word name and an expansion that is the rest of the text.
+func parseAlias(txt string) (string, string, error) {
+	name, rest, err := splitQuoted(txt)
+	if err != nil {
+		return "", "", err
+	}
+	exp := rest
+	if strings.HasPrefix(rest, `"`) {
+		var more string
+		if exp, more, err = splitQuoted(rest); err != nil {
+			return "", "", err
+		}
+		if more != "" {
+			return "", "", fmt.Errorf("Unexpected text after expansion: %s", more)
+		}
+	}
+	name = strings.Join(strings.Fields(strings.ToLower(name)), " ")
+	exp = strings.TrimSpace(exp)
+	if name == "" || exp == "" {
+		return "", "", fmt.Errorf("Usage: alias add \"<name>\" \"<expansion>\"")
+	}
+	return name, exp, nil
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"testing"
+)
+
+var testAliases = map[string]string{
+	"q":        "quote",
+	"tz":       "date now in $1",
+	"say both": "say $2 $1 and $*",
+	"qq":       "q",
+	"loop":     "pool $1",
+	"pool":     "loop $1",
+}
+
+func TestMatchAlias(t *testing.T) {
+	tests := []struct {
+		in, name, rest string
+		ok             bool
+	}{
+		{"q", "q", "", true},
+		{"Q  foo bar", "q", "foo bar", true},
+		{"qadd foo", "", "", false},
+		{"qq", "qq", "", true},
+		{"say both a b", "say both", "a b", true},
+		{"say", "", "", false},
+	}
+	for i, test := range tests {
+		name, rest, ok := matchAlias(testAliases, test.in)
+		if name != test.name || rest != test.rest || ok != test.ok {
+			t.Errorf("matchAlias(%d) %q: exp %q %q %t got %q %q %t", i, test.in,
+				test.name, test.rest, test.ok, name, rest, ok)
+		}
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	tests := []struct {
+		in, out string
+		err     bool
+	}{
+		{"quote foo", "quote foo", false},
+		{"q foo", "quote foo", false},
+		{"qq foo bar", "quote foo bar", false},
+		{"tz Europe/London", "date now in Europe/London", false},
+		{"tz", "", true},
+		{"say both a b", "say b a and a b", false},
+		{"loop x", "", true},
+	}
+	for i, test := range tests {
+		out, err := resolveAlias(testAliases, test.in)
+		if out != test.out || (err != nil) != test.err {
+			t.Errorf("resolveAlias(%d) %q: exp %q, error %t got %q, %v",
+				i, test.in, test.out, test.err, out, err)
+		}
+	}
+}
+
+func TestAliasTarget(t *testing.T) {
+	tests := []struct {
+		name, target string
+		err          bool
+	}{
+		{"q", "quote", false},
+		{"qq", "quote", false},
+		{"say both", "say $2 $1 and $*", false},
+		{"loop", "", true},
+	}
+	for i, test := range tests {
+		target, err := aliasTarget(testAliases, test.name)
+		if target != test.target || (err != nil) != test.err {
+			t.Errorf("aliasTarget(%d) %q: exp %q, error %t got %q, %v",
+				i, test.name, test.target, test.err, target, err)
+		}
+	}
+}
+
+func TestParseAlias(t *testing.T) {
+	tests := []struct {
+		in, name, exp string
+		err           bool
+	}{
+		{`"q" "quote"`, "q", "quote", false},
+		{`q quote me`, "q", "quote me", false},
+		{`"Say  Both" "say $2 $1"`, "say both", "say $2 $1", false},
+		{`"tz" date now in $1`, "tz", "date now in $1", false},
+		{`"q" "quote" extra`, "", "", true},
+		{`"q quote`, "", "", true},
+		{`q`, "", "", true},
+		{``, "", "", true},
+	}
+	for i, test := range tests {
+		name, exp, err := parseAlias(test.in)
+		if name != test.name || exp != test.exp || (err != nil) != test.err {
+			t.Errorf("parseAlias(%d) %q: exp %q %q, error %t got %q %q, %v",
+				i, test.in, test.name, test.exp, test.err, name, exp, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// An ArgType determines how an Arg is parsed and validated.
+type ArgType int
+
+const (
+	// A single word.
+	Word ArgType = iota
+	// A single word that must be a valid IRC nick.
+	NickName
+	// A single word that must be an IRC channel.
+	Channel
+	// An integer, optionally prefixed with '#', e.g. a quote ID.
+	Int
+	// A duration understood by util.ParseDuration, e.g. 90m or 2d.
+	Duration
+	// The rest of the line, parsed as a date/time by util/datetime
+	// in the caller's timezone.
+	Time
+	// The rest of the line, as-is.
+	Rest
+	// An optional "--name" boolean flag, which may appear anywhere
+	// before the rest of the line.
+	Flag
+)
+
+// An Arg describes one argument a command takes. Args are declared in
+// the order they are expected with Takes().
+type Arg struct {
+	Name     string
+	Type     ArgType
+	optional bool
+}
+
+func WordArg(name string) Arg     { return Arg{Name: name, Type: Word} }
+func NickArg(name string) Arg     { return Arg{Name: name, Type: NickName} }
+func ChanArg(name string) Arg     { return Arg{Name: name, Type: Channel} }
+func IntArg(name string) Arg      { return Arg{Name: name, Type: Int} }
+func DurationArg(name string) Arg { return Arg{Name: name, Type: Duration} }
+func TimeArg(name string) Arg     { return Arg{Name: name, Type: Time} }
+func RestArg(name string) Arg     { return Arg{Name: name, Type: Rest} }
+func FlagArg(name string) Arg     { return Arg{Name: name, Type: Flag, optional: true} }
+
+// Optional marks an argument as not required. If an optional single-word
+// argument doesn't parse, the word is tried against the next argument.
+func (a Arg) Optional() Arg {
+	a.optional = true
+	return a
+}
+
+// Takes declares the arguments a command expects. The command's text is
+// parsed before it runs, and if it doesn't match the caller is given the
+// usage from the command's help text. Parsed values are in ctx.Params.
+func Takes(args ...Arg) CommandOpt {
+	return func(c *command) { c.args = args }
+}
+
+// Params holds the parsed arguments to a command, keyed by name.
+// Optional arguments that were not given are absent.
+type Params map[string]interface{}
+
+func (p Params) Has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+func (p Params) String(name string) string {
+	s, _ := p[name].(string)
+	return s
+}
+
+func (p Params) Int(name string) int {
+	i, _ := p[name].(int)
+	return i
+}
+
+func (p Params) Duration(name string) time.Duration {
+	d, _ := p[name].(time.Duration)
+	return d
+}
+
+func (p Params) Time(name string) time.Time {
+	t, _ := p[name].(time.Time)
+	return t
+}
+
+func (p Params) Flag(name string) bool {
+	b, _ := p[name].(bool)
+	return b
+}
+
+// usage returns the part of a help string before the description.
+func usage(help string) string {
+	if idx := strings.Index(help, "  -- "); idx != -1 {
+		return help[:idx]
+	}
+	return help
+}
+
+// isNick checks s against the characters RFC 2812 allows in nicks.
+func isNick(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+			strings.ContainsRune("[]\\`_^{|}", r):
+		case i > 0 && (r >= '0' && r <= '9' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseWord parses a single-word argument.
+func parseWord(a Arg, w string) (interface{}, error) {
+	switch a.Type {
+	case NickName:
+		if !isNick(w) {
+			return nil, fmt.Errorf("'%s' doesn't look like a nick.", w)
+		}
+	case Channel:
+		if !isChannel(w) {
+			return nil, fmt.Errorf("'%s' doesn't look like a channel.", w)
+		}
+	case Int:
+		i, err := strconv.Atoi(strings.TrimPrefix(w, "#"))
+		if err != nil {
+			return nil, fmt.Errorf("'%s' doesn't look like a number.", w)
+		}
+		return i, nil
+	case Duration:
+		d, err := util.ParseDuration(w)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("'%s' doesn't look like a duration.", w)
+		}
+		return d, nil
+	}
+	return w, nil
+}
+
+// parseArgs parses txt according to args. Time args are parsed in zone.
+func parseArgs(args []Arg, txt string, zone *time.Location) (Params, error) {
+	p := Params{}
+	words := make([]string, 0)
+	flags := make(map[string]bool)
+	for _, a := range args {
+		if a.Type == Flag {
+			flags[a.Name] = true
+		}
+	}
+	// Pull flags out of the line, as long as they come before any
+	// Rest or Time argument would start. We can't know where that is
+	// yet, so a literal "--flag" in the rest of the line is taken too.
+	for _, w := range strings.Fields(txt) {
+		if strings.HasPrefix(w, "--") && flags[w[2:]] {
+			p[w[2:]] = true
+			continue
+		}
+		words = append(words, w)
+	}
+	for _, a := range args {
+		if a.Type == Flag {
+			continue
+		}
+		if len(words) == 0 {
+			if a.optional {
+				continue
+			}
+			return nil, fmt.Errorf("Missing <%s>.", a.Name)
+		}
+		switch a.Type {
+		case Rest:
+			p[a.Name] = strings.Join(words, " ")
+			words = nil
+		case Time:
+			ts := strings.Join(words, " ")
+			t, err := datetime.ParseZ(ts, zone)
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't parse time %q.", ts)
+			}
+			p[a.Name] = t
+			words = nil
+		default:
+			v, err := parseWord(a, words[0])
+			if err != nil {
+				if a.optional {
+					continue
+				}
+				return nil, err
+			}
+			p[a.Name] = v
+			words = words[1:]
+		}
+	}
+	if len(words) > 0 {
+		return nil, fmt.Errorf("Didn't understand '%s'.", strings.Join(words, " "))
+	}
+	return p, nil
+}
+
+// parseParams parses a command's arguments into ctx.Params, replying
+// with usage information and returning false if that fails.
+func (c *command) parseParams(ctx *Context) bool {
+	zone := time.Local
+	for _, a := range c.args {
+		if a.Type == Time {
+			zone = datetime.ZoneOrLocal(conf.Zone(ctx.Nick))
+		}
+	}
+	p, err := parseArgs(c.args, ctx.Text(), zone)
+	if err != nil {
+		ctx.Fail("%s Usage: %s", err, usage(c.help))
+		return false
+	}
+	ctx.Params = p
+	return true
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args []Arg
+		in   string
+		exp  Params
+		err  bool
+	}{
+		{[]Arg{IntArg("qid")}, "#42", Params{"qid": 42}, false},
+		{[]Arg{IntArg("qid")}, "42", Params{"qid": 42}, false},
+		{[]Arg{IntArg("qid")}, "fish", nil, true},
+		{[]Arg{IntArg("qid")}, "", nil, true},
+		{[]Arg{IntArg("qid")}, "1 2", nil, true},
+		{[]Arg{NickArg("nick")}, "fluffle", Params{"nick": "fluffle"}, false},
+		{[]Arg{NickArg("nick")}, "[m]-1", Params{"nick": "[m]-1"}, false},
+		{[]Arg{NickArg("nick")}, "1fluffle", nil, true},
+		{[]Arg{ChanArg("chan")}, "#sp0rklf", Params{"chan": "#sp0rklf"}, false},
+		{[]Arg{ChanArg("chan")}, "sp0rklf", nil, true},
+		{[]Arg{DurationArg("for")}, "2d", Params{"for": 48 * time.Hour}, false},
+		{[]Arg{DurationArg("for")}, "-1h", nil, true},
+		{[]Arg{NickArg("nick"), RestArg("msg")}, "bob hello there",
+			Params{"nick": "bob", "msg": "hello there"}, false},
+		{[]Arg{NickArg("nick"), RestArg("msg")}, "bob", nil, true},
+		{[]Arg{ChanArg("chan").Optional(), RestArg("msg")}, "#foo hi",
+			Params{"chan": "#foo", "msg": "hi"}, false},
+		{[]Arg{ChanArg("chan").Optional(), RestArg("msg")}, "hi there",
+			Params{"msg": "hi there"}, false},
+		{[]Arg{WordArg("role").Optional()}, "", Params{}, false},
+		{[]Arg{FlagArg("all"), WordArg("w")}, "--all x",
+			Params{"all": true, "w": "x"}, false},
+		{[]Arg{FlagArg("all"), WordArg("w")}, "x", Params{"w": "x"}, false},
+		{[]Arg{FlagArg("all"), WordArg("w")}, "--none x", nil, true},
+	}
+	for i, test := range tests {
+		p, err := parseArgs(test.args, test.in, time.UTC)
+		if (err != nil) != test.err || (!test.err && !reflect.DeepEqual(p, test.exp)) {
+			t.Errorf("parseArgs(%d) %q: exp %v (err %t) got %v (%v)",
+				i, test.in, test.exp, test.err, p, err)
+		}
+	}
+}
+
+func TestParseArgsTime(t *testing.T) {
+	p, err := parseArgs([]Arg{TimeArg("at")}, "2030-01-02 15:04", time.UTC)
+	if err != nil {
+		t.Fatalf("parseArgs time: unexpected error %v", err)
+	}
+	exp := time.Date(2030, 1, 2, 15, 4, 0, 0, time.UTC)
+	if at := p.Time("at"); !at.Equal(exp) {
+		t.Errorf("parseArgs time: exp %s got %s", exp, at)
+	}
+	if _, err := parseArgs([]Arg{TimeArg("at")}, "whenever", time.UTC); err == nil {
+		t.Errorf("parseArgs time: expected error for 'whenever'")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if u := usage("quote del #<qID>  -- Deletes a quote."); u != "quote del #<qID>" {
+		t.Errorf("usage: exp %q got %q", "quote del #<qID>", u)
+	}
+}
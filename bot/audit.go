@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/audit"
+)
+
+// Audit records a destructive or administrative action taken by ctx's
+// sender in the audit log. Item identifies what in coll was changed, and
+// prior describes its value beforehand, if it had one. Failing to record
+// the action is logged, but doesn't stop it.
+func (ctx *Context) Audit(coll, item, prior string) {
+	action := ctx.cmd
+	if action == "" {
+		// NOTICE-driven commands aren't dispatched by the command set.
+		if f := strings.Fields(ctx.Text()); len(f) > 0 {
+			action = f[0]
+		}
+	}
+	e := &audit.Entry{
+		Nick:       ctx.Nick,
+		Src:        ctx.Src,
+		Chan:       ctx.channel(),
+		Action:     action,
+		Collection: coll,
+		Item:       item,
+		Prior:      prior,
+	}
+	if err := audit.Record(ctx.Ctx(), e); err != nil {
+		logging.Error("Failed to record %s by %s in audit log: %v", action, ctx.Src, err)
+	}
+}
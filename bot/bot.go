@@ -8,8 +8,8 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/util/config"
 )
 
 // This is here because I'm not sure where better to put it...
@@ -41,33 +41,135 @@ func Init(ctx context.Context) {
 
 	bot = &botData{
 		ctx:       ctx,
-		servers:   newServerSet(),
+		servers:   newServerSet(ctx),
 		commands:  newCommandSet(),
 		rewriters: newRewriteSet(),
 		pollers:   newPollerSet(),
 	}
 
 	// This is a special handler that dispatches commands from the command set
-	bot.servers.HandleAll(client.PRIVMSG, bot.commands)
+	bot.servers.HandleAll(PRIVMSG, bot.commands)
 
 	// The poller set handles these two to start and stop registered pollers
-	bot.servers.HandleAll(client.CONNECTED, bot.pollers)
-	bot.servers.HandleAll(client.DISCONNECTED, bot.pollers)
+	bot.servers.HandleAll(CONNECTED, bot.pollers)
+	bot.servers.HandleAll(DISCONNECTED, bot.pollers)
+	// The scheduler starts when the bot first connects; see schedule.go.
+	bot.servers.HandleAll(CONNECTED, sched)
 
 	// These three in handlers.go
-	Handle(connected, client.CONNECTED)
-	Handle(rebuild, client.NOTICE)
-	Handle(shutdown, client.NOTICE)
+	Handle(connected, CONNECTED)
+	Handle(rebuild, NOTICE)
+	Handle(shutdown, NOTICE)
 
-	// These two in commands.go
-	Command(ignore, "ignore", "ignore <nick>  -- "+
-		"make the bot ignore <nick> completely.")
-	Command(unignore, "unignore", "unignore <nick>  -- "+
-		"make the bot unignore <nick> again.")
+	// These in commands.go
+	Command(ignore, "ignore", "ignore <mask|/regex/> [in <#chan>] "+
+		"[for <duration>] [commands <cmd>, ...]  -- make the bot ignore "+
+		"matching nick!ident@host sources.", Requires(Admin))
+	Command(unignore, "unignore", "unignore <mask|/regex/>  -- "+
+		"make the bot stop ignoring <mask|/regex/> again.", Requires(Admin))
+	Command(ignoreList, "ignore list", "ignore list  -- "+
+		"list the active ignore rules.", Requires(Trusted))
+	Command(grant, "grant", "grant <role> <nick|mask>  -- "+
+		"give <nick|mask> the user, trusted, admin or owner role.",
+		Requires(Admin), Takes(WordArg("role"), WordArg("nick|mask")))
+	Command(revoke, "revoke", "revoke <nick|mask>  -- "+
+		"remove any role granted to <nick|mask>.", Requires(Admin),
+		Takes(WordArg("nick|mask")))
+	Command(roles, "roles", "roles [role]  -- "+
+		"list who holds [role], or all roles.",
+		Takes(WordArg("role").Optional()))
+	Command(disable, "disable", "disable <name> ... [in <#chan>]  -- "+
+		"stop drivers, commands or handlers responding in a channel. "+
+		"Quote commands with spaces in, e.g. \"fact search\".",
+		Requires(Admin))
+	Command(enable, "enable", "enable <name> ... [in <#chan>]  -- "+
+		"let drivers, commands or handlers respond in a channel again.",
+		Requires(Admin))
+	Command(allowOnly, "only allow", "only allow <name> ... [in <#chan>]  -- "+
+		"disable everything in a channel except the named things.",
+		Requires(Admin))
+	Command(showPolicy, "policy", "policy [<#chan>]  -- "+
+		"show what is enabled or disabled in a channel.")
+	Command(resetPolicy, "policy reset", "policy reset [<#chan>]  -- "+
+		"enable everything in a channel again.", Requires(Admin))
+	Command(showTriggers, "triggers", "triggers [<#chan>]  -- "+
+		"show the prefixes that trigger commands, e.g. !remind.")
+	Command(setTriggers, "triggers set", "triggers set <prefix> ... "+
+		"[in <#chan>]  -- set the command trigger prefixes, globally or for "+
+		"<#chan>; 'none' disables them.", Requires(Admin))
+	Command(resetTriggers, "triggers reset", "triggers reset [<#chan>]  -- "+
+		"make <#chan> use the global triggers, or remove the global ones.",
+		Requires(Admin))
+	Command(more, "more", "more  -- show the next page of a long reply.",
+		Pipes())
+	Command(rateLimit, "ratelimit", "ratelimit [[<name>:]<scope> "+
+		"<burst>/<period>|off]  -- show or change per-nick, per-chan or "+
+		"per-cmd rate limits, optionally for one <name> only.",
+		Requires(Admin))
+	Command(join, "join", "join <#chan>  -- join <#chan>, "+
+		"and rejoin it after reconnecting.", Requires(Admin),
+		Takes(ChanArg("chan")))
+	Command(part, "part", "part [<#chan>] [message]  -- leave <#chan> "+
+		"or this channel, and don't rejoin it.", Requires(Admin),
+		Takes(ChanArg("chan").Optional(), RestArg("message").Optional()))
+	Command(listChannels, "channels", "channels  -- list the channels "+
+		"joined after connecting.", Requires(Trusted))
+	Command(resetChannelsCmd, "channels reset", "channels reset  -- "+
+		"forget channels joined and parted with 'join' and 'part', "+
+		"and join only those in the config after connecting.",
+		Requires(Admin))
+	Command(cycle, "cycle", "cycle [<#chan>]  -- part and rejoin <#chan> "+
+		"or this channel.", Requires(Admin),
+		Takes(ChanArg("chan").Optional()))
+	Command(changeNick, "nick", "nick <nick>  -- change the bot's nick "+
+		"until it reconnects.", Requires(Admin), Takes(NickArg("nick")))
+	Command(say, "say", "say <#chan|nick> <text>  -- make the bot say "+
+		"<text>.", Requires(Admin), Takes(WordArg("target"), RestArg("text")))
+	Command(act, "act", "act <#chan|nick> <text>  -- make the bot /me "+
+		"<text>.", Requires(Admin), Takes(WordArg("target"), RestArg("text")))
+	Command(topic, "topic", "topic [<#chan>] <topic>  -- set the topic of "+
+		"<#chan> or this channel.", Requires(Admin),
+		Takes(ChanArg("chan").Optional(), RestArg("topic")))
+	Command(aliasAdd, "alias add", "alias add \"<name>\" \"<command>\"  -- "+
+		"make <name> run <command>, replacing $1 to $9 and $* with the "+
+		"words after <name>.", Requires(Admin))
+	Command(aliasDel, "alias del", "alias del <name>  -- remove the "+
+		"alias <name>.", Requires(Admin))
+	Command(aliasList, "alias list", "alias list  -- list command aliases.")
+	Command(listPollers, "pollers", "pollers  -- show when each poller "+
+		"last ran and any errors.", Requires(Trusted))
+	Command(startPoller, "poller start", "poller start <name>  -- "+
+		"start a poller stopped with 'poller stop'.", Requires(Admin),
+		Takes(WordArg("name")))
+	Command(stopPoller, "poller stop", "poller stop <name>  -- "+
+		"stop a poller, until it's started again.", Requires(Admin),
+		Takes(WordArg("name")))
+	Command(schedule, "schedule", "schedule say <text> [in <#chan>] | "+
+		"run <command> every <when> [catch up]  -- say or run something "+
+		"regularly, e.g. every weekday at 9am or every 2 hours; "+
+		"'catch up' makes up for runs missed while the bot was down.",
+		Requires(Admin))
+	Command(scheduleList, "schedule list", "schedule list  -- "+
+		"list scheduled actions.", Requires(Trusted))
+	Command(scheduleDel, "schedule del", "schedule del <N>  -- "+
+		"delete scheduled action <N> from 'schedule list'.",
+		Requires(Admin), Takes(IntArg("N")))
+	Command(listDrivers, "drivers", "drivers  -- list drivers and "+
+		"whether they are loaded.")
+
+	checkOwners()
+
+	// Pick up changes to reloadable settings.
+	config.OnReload(reload)
 
 	// Mongo -> Bolt migration. Run in background goroutine
 	// because some migrations can take a looong time.
-	HandleBG(migrate, client.NOTICE)
+	HandleBG(migrate, NOTICE)
+}
+
+func reload() {
+	bot.servers.Reload()
+	limiter.reset()
 }
 
 func Connect() chan bool {
@@ -97,27 +199,54 @@ func Shutdown() {
 }
 
 func Handle(fn HandlerFunc, events ...string) {
+	nh := namedHandler{fn: fn, names: funcNames(fn)}
+	policies.register(nh.names...)
+	for _, ev := range events {
+		bot.servers.HandleAll(ev, nh)
+	}
+}
+
+// HandleReplays is Handle for handlers that only record what they see,
+// so they're also given lines replayed from before the bot connected.
+// They must cope with lines older than ones they've already seen.
+func HandleReplays(fn HandlerFunc, events ...string) {
+	nh := namedHandler{fn: fn, names: funcNames(fn), replays: true}
+	policies.register(nh.names...)
 	for _, ev := range events {
-		bot.servers.HandleAll(ev, fn)
+		bot.servers.HandleAll(ev, nh)
 	}
 }
 
 func HandleBG(fn HandlerFunc, events ...string) {
+	nh := namedHandler{fn: fn, names: funcNames(fn)}
+	policies.register(nh.names...)
 	for _, ev := range events {
-		bot.servers.HandleAllBG(ev, fn)
+		bot.servers.HandleAllBG(ev, nh)
 	}
 }
 
-func Command(fn HandlerFunc, prefix, help string) {
-	bot.commands.Add(&command{fn, help}, prefix)
+func Command(fn HandlerFunc, prefix, help string, opts ...CommandOpt) {
+	c := &command{fn: fn, help: help}
+	if names := funcNames(fn); names != nil {
+		c.names = append(names, prefix)
+		policies.register(c.names...)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	bot.commands.Add(c, prefix)
 }
 
 func Rewrite(fn RewriteFunc) {
-	bot.rewriters.Add(fn)
+	nr := namedRewriter{fn, funcNames(fn)}
+	policies.register(nr.names...)
+	bot.rewriters.Add(nr)
 }
 
-func Poll(p Poller) {
-	bot.pollers.Add(p)
+// Poll registers a Poller, which runs while the bot is connected unless
+// it's stopped with "poller stop <name>".
+func Poll(name string, p Poller) {
+	bot.pollers.Add(name, p)
 }
 
 func GetSecret(s string) string {
@@ -132,8 +261,8 @@ func GetSecret(s string) string {
 	return s
 }
 
-// TODO(fluffle): The long slog refactor of subsuming the bot context
-// into a context.Context Value and using context.Context everywhere.
+// Ctx returns the bot's base context.Context. Code running in response
+// to IRC lines should prefer the request-scoped Context.Ctx().
 func Ctx() context.Context {
 	return bot.ctx
 }
@@ -0,0 +1,246 @@
+// Package bottest helps test handlers, commands and rewriters without a
+// server. A Bot is a fake bot.Transport that delivers scripted lines to
+// the registered handlers and commands, and records everything sent in
+// response, after rewriters have run.
+//
+// Conf namespaces, the audit log and schedules are kept in memory. Other
+// collections need a database; tests using them should call UseDB first.
+package bottest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/audit"
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/collections/schedules"
+	"github.com/fluffle/sp0rkle/db"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// Server is the host:port of the fake server test lines come from.
+const Server = "irc.example.com:6667"
+
+var once, logOnce sync.Once
+
+func initLogging() {
+	logOnce.Do(func() { logging.InitFromFlags() })
+}
+
+// The handlers the bot registered with its transport, by event. They're
+// given lines by every Bot.
+var handlers = struct {
+	sync.Mutex
+	m map[string][]bot.Handler
+}{m: make(map[string][]bot.Handler)}
+
+// Init sets up the bot package for testing. It's called by New, but
+// drivers registering commands, handlers or rewriters in tests must call
+// it first.
+func Init() {
+	once.Do(func() {
+		initLogging()
+		conf.UseInMem()
+		audit.UseInMem()
+		schedules.UseInMem()
+		datetime.SetTZ("UTC")
+		// The bot needs a server configured, but never connects to it.
+		bot.RegisterTransport("bottest", func(sc *bot.ServerConfig) bot.Transport {
+			return &Bot{nick: sc.Nick}
+		})
+		flag.Set("servers", Server)
+		flag.Set("transport", "bottest")
+		bot.Init(context.Background())
+	})
+}
+
+// UseDB keeps collections that need a database in a BoltDB in dir, so
+// there's no need for MongoDB. It must be called once, before any
+// collections are initialised, e.g. from TestMain before drivers are.
+func UseDB(dir string) error {
+	initLogging()
+	db.BoltOnly()
+	return db.Bolt.Init(filepath.Join(dir, "sp0rkle.boltdb"),
+		filepath.Join(dir, "backup"), 24*time.Hour)
+}
+
+// Sent is a line sent by the bot.
+type Sent struct {
+	Cmd, Target, Text string
+}
+
+func (s Sent) String() string {
+	return fmt.Sprintf("%s %s :%s", s.Cmd, s.Target, s.Text)
+}
+
+// A Bot collects what is sent in response to the lines it's given.
+type Bot struct {
+	sync.Mutex
+	nick string
+	sent []Sent
+}
+
+var _ bot.Unpaced = (*Bot)(nil)
+
+// New returns a Bot with the nick "sp0rkle".
+func New() *Bot {
+	Init()
+	return &Bot{nick: "sp0rkle"}
+}
+
+// Nick returns the bot's nick.
+func (b *Bot) Nick() string {
+	return b.Me().Nick
+}
+
+// Name implements bot.Transport.
+func (b *Bot) Name() string {
+	return Server
+}
+
+// Connect implements bot.Transport. A Bot is always connected.
+func (b *Bot) Connect() error {
+	return nil
+}
+
+// Connected implements bot.Transport.
+func (b *Bot) Connected() bool {
+	return true
+}
+
+// Me implements bot.Transport.
+func (b *Bot) Me() bot.Identity {
+	b.Lock()
+	defer b.Unlock()
+	return bot.Identity{Nick: b.nick, Ident: "boing", Host: "localhost"}
+}
+
+// Send implements bot.Output.
+func (b *Bot) Send(cmd, target, text string) {
+	b.Lock()
+	defer b.Unlock()
+	b.sent = append(b.sent, Sent{cmd, target, text})
+}
+
+// Topic implements bot.Output. Topic queries are recorded with no text.
+func (b *Bot) Topic(ch string, topic ...string) {
+	b.Send(bot.TOPIC, ch, strings.Join(topic, " "))
+}
+
+// Room implements bot.Transport. Output isn't split, so it's unlimited.
+func (b *Bot) Room(cmd, target string) int {
+	return math.MaxInt
+}
+
+// Join, Part, SetNick and Quit implement bot.Transport, and are recorded
+// like lines that are sent.
+func (b *Bot) Join(ch string) {
+	b.Send(bot.JOIN, ch, "")
+}
+
+func (b *Bot) Part(ch, msg string) {
+	b.Send(bot.PART, ch, msg)
+}
+
+func (b *Bot) SetNick(nick string) {
+	b.Lock()
+	b.nick = nick
+	b.Unlock()
+	b.Send(bot.NICK, nick, "")
+}
+
+func (b *Bot) Quit(msg string) {
+	b.Send(bot.QUIT, "", msg)
+}
+
+// Unpaced implements bot.Unpaced, so output is recorded straight away.
+func (b *Bot) Unpaced() {}
+
+// Handle and HandleBG implement bot.Transport. Handlers are run by Hear
+// and Say, which run background handlers in the foreground too, so
+// everything they send has been sent when they return.
+func (b *Bot) Handle(event string, h bot.Handler) {
+	handlers.Lock()
+	defer handlers.Unlock()
+	handlers.m[event] = append(handlers.m[event], h)
+}
+
+func (b *Bot) HandleBG(event string, h bot.Handler) {
+	b.Handle(event, h)
+}
+
+// line builds a line of cmd from nick, who is given the ident "ident"
+// and host "host.example.com".
+func line(nick, cmd string, args ...string) *bot.Line {
+	ident, host := "ident", "host.example.com"
+	src := nick + "!" + ident + "@" + host
+	return &bot.Line{
+		Nick: nick, Ident: ident, Host: host, Src: src,
+		Cmd: cmd, Args: args, Time: time.Now(),
+		Raw: fmt.Sprintf(":%s %s %s", src, cmd, strings.Join(args, " ")),
+	}
+}
+
+// Line returns a Context for a line of cmd from nick. It returns nil if
+// the line would be ignored.
+func (b *Bot) Line(nick, cmd string, args ...string) *bot.Context {
+	return bot.NewContext(b, line(nick, cmd, args...), b)
+}
+
+// Hear delivers a line of cmd from nick to the handlers registered for
+// cmd, as if it had come from a server. For PRIVMSG these include the
+// bot's commands.
+func (b *Bot) Hear(nick, cmd string, args ...string) {
+	handlers.Lock()
+	hs := handlers.m[cmd]
+	handlers.Unlock()
+	l := line(nick, cmd, args...)
+	for _, h := range hs {
+		h.Handle(b, l.Copy())
+	}
+}
+
+// Privmsg returns a Context for text said by nick to target, which
+// may be a channel or the bot's nick.
+func (b *Bot) Privmsg(nick, target, text string) *bot.Context {
+	return b.Line(nick, bot.PRIVMSG, target, text)
+}
+
+// Say delivers text said by nick to target to the registered handlers
+// and commands, as if it had come from a server.
+func (b *Bot) Say(nick, target, text string) {
+	b.Hear(nick, bot.PRIVMSG, target, text)
+}
+
+// Sent returns the lines sent since it was last called.
+func (b *Bot) Sent() []Sent {
+	b.Lock()
+	defer b.Unlock()
+	sent := b.sent
+	b.sent = nil
+	return sent
+}
+
+// Texts returns the text of the lines sent since Sent was last called.
+func (b *Bot) Texts() []string {
+	sent := b.Sent()
+	texts := make([]string, len(sent))
+	for i, s := range sent {
+		texts[i] = s.Text
+	}
+	return texts
+}
+
+// Grant gives mask role r, as the grant command would.
+func Grant(mask string, r bot.Role) {
+	Init()
+	conf.Ns("roles").String(bot.NormalizeMask(mask), r.String())
+}
@@ -0,0 +1,376 @@
+package bottest
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/bot"
+)
+
+func TestSay(t *testing.T) {
+	b := New()
+	tests := []struct {
+		target, text string
+		exp          []Sent
+	}{
+		// Lines that aren't addressed to the bot don't run commands.
+		{"#chan", "more", nil},
+		{"#chan", "sp0rkle: more", []Sent{{bot.PRIVMSG, "#chan",
+			"tester: There's nothing more to see here."}}},
+		// Replies to private messages go back to the sender.
+		{"sp0rkle", "more", []Sent{{bot.PRIVMSG, "tester",
+			"tester: There's nothing more to see here."}}},
+		{"#chan", "sp0rkle: join #other", []Sent{{bot.PRIVMSG, "#chan",
+			"tester: You need to be admin to do that."}}},
+		// Commands only match whole words.
+		{"#chan", "sp0rkle: actually, party time", nil},
+	}
+	for i, test := range tests {
+		b.Say("tester", test.target, test.text)
+		if sent := b.Sent(); !reflect.DeepEqual(sent, test.exp) {
+			t.Errorf("Say(%d) %q: exp %v got %v", i, test.text, test.exp, sent)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	b := New()
+	ctx := b.Privmsg("tester", "#chan", "hello")
+	ctx.ReplyN("hi")
+	ctx.Do("waves")
+	ctx.Notice("tester", "psst")
+	ctx.Topic("#chan", "new topic")
+	exp := []Sent{
+		{bot.PRIVMSG, "#chan", "tester: hi"},
+		{bot.ACTION, "#chan", "waves"},
+		{bot.NOTICE, "tester", "psst"},
+		{bot.TOPIC, "#chan", "new topic"},
+	}
+	if sent := b.Sent(); !reflect.DeepEqual(sent, exp) {
+		t.Errorf("Sent: exp %v got %v", exp, sent)
+	}
+	if sent := b.Sent(); len(sent) != 0 {
+		t.Errorf("Sent: expected output to be cleared, got %v", sent)
+	}
+}
+
+func TestHear(t *testing.T) {
+	b := New()
+	var got []string
+	bot.Handle(func(ctx *bot.Context) {
+		got = append(got, ctx.Nick+" "+ctx.Cmd+" "+ctx.Target())
+		ctx.Privmsg(ctx.Target(), "hello "+ctx.Nick)
+	}, bot.JOIN)
+	b.Hear("tester", bot.JOIN, "#chan")
+	exp := []string{"tester JOIN #chan"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Hear: exp %q got %q", exp, got)
+	}
+	sent := []Sent{{bot.PRIVMSG, "#chan", "hello tester"}}
+	if s := b.Sent(); !reflect.DeepEqual(s, sent) {
+		t.Errorf("Hear: exp %v got %v", sent, s)
+	}
+	// Handlers only get the events they were registered for.
+	b.Hear("tester", bot.PART, "#chan")
+	if len(got) != 1 {
+		t.Errorf("Hear: PART ran a JOIN handler: %q", got)
+	}
+}
+
+func TestGrant(t *testing.T) {
+	b := New()
+	Grant("boss!ident@*", bot.Admin)
+	exp := []string{"boss: Commands can be triggered with ! in #chan."}
+	if texts := b.Texts(); len(texts) != 0 {
+		t.Fatalf("Texts: unexpected output %v", texts)
+	}
+	b.Say("boss", "#chan", "sp0rkle: triggers set ! in #chan")
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("triggers set: exp %q got %q", exp, texts)
+	}
+	b.Say("boss", "#chan", "!triggers #chan")
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("!triggers: exp %q got %q", exp, texts)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	b := New()
+	Grant("boss!ident@*", bot.Owner)
+	Grant("nobody", bot.Owner)
+	Grant("mallory", bot.Admin)
+	tests := []struct {
+		nick, text string
+		exp        []string
+	}{
+		{"boss", "grant owner carol", []string{"boss: Granting owner needs " +
+			"a mask with an ident or host, since anyone can use a nick."}},
+		{"boss", "grant owner carol!*@carol.example.com",
+			[]string{"boss: 'carol!*@carol.example.com' is now owner."}},
+		{"boss", "grant admin dave!ident@*",
+			[]string{"boss: 'dave!ident@*' is now admin."}},
+		{"boss", "grant admin erin!ident@*",
+			[]string{"boss: 'erin!ident@*' is now admin."}},
+		// Admins can't make or unmake their peers.
+		{"dave", "grant admin frank",
+			[]string{"dave: You can't grant a role as high as your own."}},
+		{"dave", "grant trusted erin!ident@*", []string{
+			"dave: 'erin!ident@*' is already admin, which you can't change."}},
+		{"dave", "revoke erin!ident@*", []string{
+			"dave: 'erin!ident@*' is admin, which you can't revoke."}},
+		{"dave", "grant trusted frank", []string{"dave: Granting trusted needs " +
+			"a mask with an ident or host, since anyone can use a nick."}},
+		{"dave", "grant trusted frank!*@frank.example.com",
+			[]string{"dave: 'frank!*@frank.example.com' is now trusted."}},
+		{"boss", "revoke erin!ident@*",
+			[]string{"boss: 'erin!ident@*' is no longer admin."}},
+		// Grants to bare nicks are ignored.
+		{"nobody", "grant admin carol",
+			[]string{"nobody: You need to be admin to do that."}},
+		{"mallory", "grant trusted mallory!*@mallory.example.com",
+			[]string{"mallory: You need to be admin to do that."}},
+	}
+	for i, test := range tests {
+		b.Say(test.nick, "#chan", "sp0rkle: "+test.text)
+		if texts := b.Texts(); !reflect.DeepEqual(texts, test.exp) {
+			t.Errorf("Roles(%d) %q: exp %q got %q", i, test.text, test.exp, texts)
+		}
+	}
+}
+
+func TestAlias(t *testing.T) {
+	b := New()
+	Grant("boss!ident@*", bot.Admin)
+	tests := []struct {
+		text string
+		exp  []string
+	}{
+		{`alias add "pol" "policy"`, []string{"boss: 'pol' now runs 'policy'."}},
+		{"pol", []string{"boss: Everything is enabled in #chan."}},
+		{"help pol", []string{"boss: 'pol' is an alias for 'policy'. " +
+			"policy [<#chan>]  -- show what is enabled or disabled in a channel."}},
+		{`alias add "pol2" "pol $1"`, []string{"boss: 'pol2' now runs 'pol $1'."}},
+		{"pol2 #other", []string{"boss: Everything is enabled in #other."}},
+		{"pol2", []string{"boss: 'pol2' needs 1 argument(s), got 0."}},
+		{`alias add "pol" "pol2 $1"`, []string{"boss: Alias loop: pol => pol2 => pol."}},
+		{`alias add "policy" "more"`, []string{"boss: 'policy' would hide an existing command."}},
+		{`alias add "x" "nonsense"`, []string{"boss: 'nonsense' doesn't start with a command I know."}},
+		{"alias list", []string{"boss: Aliases: pol => policy; pol2 => pol $1"}},
+		{"alias del pol2", []string{"boss: 'pol2' no longer runs 'pol $1'."}},
+		{"alias del pol2", []string{"boss: 'pol2' isn't an alias."}},
+	}
+	for i, test := range tests {
+		b.Say("boss", "#chan", "sp0rkle: "+test.text)
+		if texts := b.Texts(); !reflect.DeepEqual(texts, test.exp) {
+			t.Errorf("Alias(%d) %q: exp %q got %q", i, test.text, test.exp, texts)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	b := New()
+	tests := []struct {
+		text string
+		exp  []string
+	}{
+		{"help bot", []string{"tester: Commands from bot: act, alias add, " +
+			"alias del, alias list, channels, channels reset, cycle, disable, " +
+			"drivers, enable (26 more, say 'more')"}},
+		{"help polcy", []string{`tester: Commands matching "polcy": "policy".`}},
+		{"help nosuch", []string{"tester: Unrecognised command 'nosuch'."}},
+	}
+	for i, test := range tests {
+		b.Say("tester", "#chan", "sp0rkle: "+test.text)
+		if texts := b.Texts(); !reflect.DeepEqual(texts, test.exp) {
+			t.Errorf("Help(%d) %q: exp %q got %q", i, test.text, test.exp, texts)
+		}
+	}
+
+	b.Say("tester", "#chan", "sp0rkle: help")
+	if texts := b.Texts(); len(texts) != 2 || !strings.HasPrefix(texts[1], "bot: act, ") {
+		t.Errorf("help: got %q", texts)
+	}
+
+	if !bot.Suggest(b.Privmsg("tester", "#chan", "sp0rkle: polcy #chan")) {
+		t.Errorf("Suggest: expected a suggestion")
+	}
+	exp := []string{"tester: Did you mean 'policy'?"}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("Suggest: exp %q got %q", exp, texts)
+	}
+	if bot.Suggest(b.Privmsg("tester", "#chan", "polcy #chan")) {
+		t.Errorf("Suggest: unaddressed line got a suggestion")
+	}
+
+	rec := httptest.NewRecorder()
+	bot.HelpHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/help", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<b>policy</b>") {
+		t.Errorf("HelpHandler: policy missing from page:\n%s", body)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	b := New()
+	Grant("boss!ident@*", bot.Admin)
+	tests := []struct {
+		text string
+		exp  []string
+	}{
+		{"more | more", []string{"boss: There's nothing more to see here."}},
+		{"more | more | more | more | more | more",
+			[]string{"boss: Pipelines can have at most 5 commands."}},
+		// " | " only starts a new command if a command follows it.
+		{"more | b", []string{"boss: There's nothing more to see here."}},
+		// Commands taking free text aren't split at all.
+		{"say #other hi | more", []string{"hi | more"}},
+		{`alias add "m" "more | more"`, []string{"boss: 'm' now runs 'more | more'."}},
+		{"m", []string{"boss: There's nothing more to see here."}},
+		{"more | m", []string{"boss: There's nothing more to see here."}},
+		// Commands aren't piped unless the line starts with one.
+		{"nonsense | more", []string{}},
+	}
+	for i, test := range tests {
+		b.Say("boss", "#chan", "sp0rkle: "+test.text)
+		if texts := b.Texts(); !reflect.DeepEqual(texts, test.exp) {
+			t.Errorf("Pipe(%d) %q: exp %q got %q", i, test.text, test.exp, texts)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	b := New()
+	Grant("boss!ident@*", bot.Admin)
+	b.Say("boss", "#chan", "sp0rkle: schedule list")
+	exp := []string{"boss: Nothing is scheduled."}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("schedule list: exp %q got %q", exp, texts)
+	}
+
+	now := time.Now()
+	b.Say("boss", "#chan", "sp0rkle: schedule say hi in #other every hour")
+	b.Say("boss", "#chan", "sp0rkle: schedule run more every 2 hours catch up")
+	texts := b.Texts()
+	prefixes := []string{
+		`boss: I'll say "hi" in #other every hour, next at `,
+		`boss: I'll run "more" in #chan as boss every 2 hours, catching up, next at `,
+	}
+	if len(texts) != len(prefixes) {
+		t.Fatalf("schedule: exp %d replies got %q", len(prefixes), texts)
+	}
+	for i, p := range prefixes {
+		if !strings.HasPrefix(texts[i], p) {
+			t.Errorf("schedule(%d): exp prefix %q got %q", i, p, texts[i])
+		}
+	}
+
+	tests := []struct {
+		after time.Duration
+		exp   []Sent
+	}{
+		{30 * time.Minute, nil},
+		{time.Hour + time.Second, []Sent{{bot.PRIVMSG, "#other", "hi"}}},
+		{2*time.Hour + time.Second, []Sent{{bot.PRIVMSG, "#other", "hi"},
+			{bot.PRIVMSG, "#chan", "boss: There's nothing more to see here."}}},
+		// Five hours later, the hourly say has missed its run, but the
+		// other catches up.
+		{7 * time.Hour, []Sent{{bot.PRIVMSG, "#chan",
+			"boss: There's nothing more to see here."}}},
+	}
+	for i, test := range tests {
+		bot.RunSchedules(b.Privmsg("boss", "#chan", ""), now.Add(test.after))
+		if sent := b.Sent(); !reflect.DeepEqual(sent, test.exp) {
+			t.Errorf("RunSchedules(%d): exp %v got %v", i, test.exp, sent)
+		}
+	}
+
+	b.Say("boss", "#chan", "sp0rkle: schedule list")
+	if texts := b.Texts(); len(texts) != 3 || !strings.HasPrefix(texts[1], `1: say "hi"`) {
+		t.Errorf("schedule list: got %q", texts)
+	}
+	b.Say("boss", "#chan", "sp0rkle: schedule del 3")
+	b.Say("boss", "#chan", "sp0rkle: schedule del 1")
+	b.Say("tester", "#chan", "sp0rkle: schedule del 1")
+	exp = []string{
+		"boss: There's no schedule 3; see 'schedule list'.",
+		`boss: I won't say "hi" in #other every hour.`,
+		"tester: You need to be admin to do that.",
+	}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("schedule del: exp %q got %q", exp, texts)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	b := New()
+	left := func(ctx *bot.Context) {
+		if d, ok := ctx.Ctx().Deadline(); ok {
+			ctx.Reply("%v", time.Until(d).Round(time.Second))
+		}
+	}
+	bot.Command(left, "deadline default", "deadline default  -- test.")
+	bot.Command(left, "deadline long", "deadline long  -- test.",
+		bot.Timeout(time.Hour))
+	b.Say("tester", "#chan", "sp0rkle: deadline default")
+	b.Say("tester", "#chan", "sp0rkle: deadline long")
+	exp := []string{"30s", "1h0m0s"}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("Timeout: exp %q got %q", exp, texts)
+	}
+}
+
+func TestLimitArgs(t *testing.T) {
+	b := New()
+	bot.Command(func(ctx *bot.Context) {
+		ctx.ReplyN("got %d", ctx.Params.Int("n"))
+	}, "limited", "limited <n>  -- test.", bot.Limit("limited"),
+		bot.Takes(bot.IntArg("n")))
+	usage := []string{"limiter: 'x' doesn't look like a number. " +
+		"Usage: limited <n>"}
+	for i := 0; i < 5; i++ {
+		b.Say("limiter", "#chan", "sp0rkle: limited x")
+		if texts := b.Texts(); !reflect.DeepEqual(texts, usage) {
+			t.Fatalf("limited x (%d): exp %q got %q", i, usage, texts)
+		}
+	}
+	// Bad arguments don't count towards the limit.
+	b.Say("limiter", "#chan", "sp0rkle: limited 1")
+	exp := []string{"limiter: got 1"}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("limited 1: exp %q got %q", exp, texts)
+	}
+}
+
+func TestPagedRewrite(t *testing.T) {
+	b := New()
+	bot.Rewrite(func(in string, ctx *bot.Context) string {
+		return strings.ReplaceAll(in, "$pager", "rewritten")
+	})
+	lines := []string{"1 $pager", "2 $pager", "3 $pager", "4 $pager",
+		"5 $pager", "6 $pager"}
+	bot.Command(func(ctx *bot.Context) {
+		ctx.ReplyPaged("", lines)
+	}, "paged", "paged  -- test.")
+	bot.Command(func(ctx *bot.Context) {
+		ctx.ReplyPagedLiteral("", lines)
+	}, "paged literal", "paged literal  -- test.")
+	tests := []struct {
+		text string
+		exp  []string
+	}{
+		{"paged", []string{"1 rewritten", "2 rewritten", "3 rewritten",
+			"4 rewritten", "5 rewritten", "tester: (1 more, say 'more')"}},
+		{"more", []string{"6 rewritten"}},
+		{"paged literal", []string{"1 $pager", "2 $pager", "3 $pager",
+			"4 $pager", "5 $pager", "tester: (1 more, say 'more')"}},
+		{"more", []string{"6 $pager"}},
+	}
+	for i, test := range tests {
+		b.Say("tester", "#chan", "sp0rkle: "+test.text)
+		if texts := b.Texts(); !reflect.DeepEqual(texts, test.exp) {
+			t.Errorf("Paged(%d) %q: exp %q got %q", i, test.text, test.exp, texts)
+		}
+	}
+}
@@ -0,0 +1,635 @@
+// Package ircd is a minimal in-process IRC server for integration tests.
+// It speaks just enough RFC 1459 for the bot and scripted users to
+// register and talk over real sockets: NICK, USER, PING, JOIN, PART,
+// PRIVMSG, NOTICE, TOPIC, KICK, MODE and QUIT. There are no channel
+// operators, modes or bans; everyone may do everything.
+//
+// It also supports IRCv3 capability negotiation with CAP, for the
+// capabilities in Caps. Tag values are passed on as they are, without
+// escaping or unescaping them.
+package ircd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name is the server's name, used as the prefix of numeric replies.
+const Name = "irc.test"
+
+// Caps are the IRCv3 capabilities the server supports.
+var Caps = []string{"account-notify", "account-tag", "extended-join",
+	"message-tags", "server-time"}
+
+// The format of server-time tags.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// A Server accepts connections on a random localhost port.
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[*conn]bool
+	nicks map[string]*conn // Registered clients, by lowercase nick.
+	chans map[string]*channel
+	// If set, the time put in server-time tags instead of the real time.
+	now time.Time
+}
+
+type channel struct {
+	name, topic string
+	members     map[*conn]bool
+}
+
+type conn struct {
+	s    *Server
+	sock net.Conn
+	wmu  sync.Mutex
+
+	// These are protected by s.mu.
+	nick, user, real string
+	registered       bool
+	chans            map[string]*channel
+	// The services account the client is logged in to, if any.
+	account string
+	// Capabilities the client has enabled. While capping is true,
+	// registration waits for the client to send CAP END.
+	caps    map[string]bool
+	capping bool
+	// Client-only tags of the message being relayed from the client.
+	ctags map[string]string
+}
+
+// New starts a server.
+func New() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:    ln,
+		conns: make(map[*conn]bool),
+		nicks: make(map[string]*conn),
+		chans: make(map[string]*channel),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns the host:port the server is listening on.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the server and drops all its clients.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.sock.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Disconnect drops nick's connection without warning, as if the network
+// had failed. It returns false if nick isn't connected.
+func (s *Server) Disconnect(nick string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.nicks[strings.ToLower(nick)]
+	if ok {
+		c.sock.Close()
+	}
+	return ok
+}
+
+// SetTime makes the server put t in server-time tags rather than the
+// current time, as a bouncer replaying old messages would. The zero
+// time goes back to the current time.
+func (s *Server) SetTime(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = t
+}
+
+// Login logs nick in to a services account, or out of one if account
+// is empty, telling clients on its channels that asked for
+// account-notify. It returns false if nick isn't connected.
+func (s *Server) Login(nick, account string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.nicks[strings.ToLower(nick)]
+	if !ok {
+		return false
+	}
+	c.account = account
+	seen := map[*conn]bool{c: true}
+	for _, ch := range c.chans {
+		for m := range ch.members {
+			if !seen[m] && m.caps["account-notify"] {
+				m.sendFrom(c, ":%s ACCOUNT %s", c.prefix(), c.accountOrStar())
+			}
+			seen[m] = true
+		}
+	}
+	return true
+}
+
+// Nicks returns the nicks of the registered clients, sorted.
+func (s *Server) Nicks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nicks := make([]string, 0, len(s.nicks))
+	for _, c := range s.nicks {
+		nicks = append(nicks, c.nick)
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+// Members returns the nicks in ch, sorted.
+func (s *Server) Members(ch string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.chans[strings.ToLower(ch)]; ok {
+		return c.nicks()
+	}
+	return nil
+}
+
+// Topic returns the topic of ch.
+func (s *Server) Topic(ch string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.chans[strings.ToLower(ch)]; ok {
+		return c.topic
+	}
+	return ""
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		sock, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{s: s, sock: sock, chans: make(map[string]*channel),
+			caps: make(map[string]bool)}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go c.serve()
+	}
+}
+
+func (ch *channel) nicks() []string {
+	nicks := make([]string, 0, len(ch.members))
+	for m := range ch.members {
+		nicks = append(nicks, m.nick)
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+// A message is a parsed line from a client. Prefixes are ignored.
+type message struct {
+	tags map[string]string
+	cmd  string
+	args []string
+}
+
+func parse(line string) *message {
+	line = strings.TrimRight(line, "\r\n")
+	var tags map[string]string
+	if strings.HasPrefix(line, "@") {
+		idx := strings.Index(line, " ")
+		if idx == -1 {
+			return nil
+		}
+		tags = make(map[string]string)
+		for _, tag := range strings.Split(line[1:idx], ";") {
+			if kv := strings.SplitN(tag, "=", 2); len(kv) == 2 {
+				tags[kv[0]] = kv[1]
+			} else if tag != "" {
+				tags[tag] = ""
+			}
+		}
+		line = strings.TrimLeft(line[idx+1:], " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		if idx := strings.Index(line, " "); idx != -1 {
+			line = line[idx+1:]
+		} else {
+			return nil
+		}
+	}
+	var trailing *string
+	if idx := strings.Index(line, " :"); idx != -1 {
+		t := line[idx+2:]
+		trailing, line = &t, line[:idx]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	m := &message{tags: tags, cmd: strings.ToUpper(fields[0]), args: fields[1:]}
+	if trailing != nil {
+		m.args = append(m.args, *trailing)
+	}
+	return m
+}
+
+func (c *conn) serve() {
+	defer c.s.wg.Done()
+	defer c.quit("Connection closed")
+	r := bufio.NewReader(c.sock)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if m := parse(line); m != nil && !c.handle(m) {
+			return
+		}
+	}
+}
+
+// send sends a line from the server. It must be called with s.mu held.
+func (c *conn) send(format string, args ...interface{}) {
+	c.sendFrom(nil, format, args...)
+}
+
+// sendFrom sends a line relayed from src, or from the server if src is
+// nil, tagged as c asked for with CAP. It must be called with s.mu held.
+func (c *conn) sendFrom(src *conn, format string, args ...interface{}) {
+	var tags []string
+	if c.caps["server-time"] {
+		now := c.s.now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		tags = append(tags, "time="+now.UTC().Format(timeFormat))
+	}
+	if src != nil && src.account != "" && c.caps["account-tag"] {
+		tags = append(tags, "account="+src.account)
+	}
+	if src != nil && c.caps["message-tags"] {
+		var ctags []string
+		for k, v := range src.ctags {
+			if !strings.HasPrefix(k, "+") {
+				continue
+			}
+			if v != "" {
+				k += "=" + v
+			}
+			ctags = append(ctags, k)
+		}
+		sort.Strings(ctags)
+		tags = append(tags, ctags...)
+	}
+	line := fmt.Sprintf(format, args...)
+	if len(tags) > 0 {
+		line = "@" + strings.Join(tags, ";") + " " + line
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	fmt.Fprint(c.sock, line+"\r\n")
+}
+
+// target is how replies to c address it. It must be called with s.mu held.
+func (c *conn) target() string {
+	if c.nick == "" {
+		return "*"
+	}
+	return c.nick
+}
+
+// numeric sends a numeric reply. It must be called with s.mu held.
+func (c *conn) numeric(num string, args ...string) {
+	if n := len(args); n > 0 {
+		args[n-1] = ":" + args[n-1]
+	}
+	c.send(":%s %s %s %s", Name, num, c.target(), strings.Join(args, " "))
+}
+
+// prefix must be called with s.mu held.
+func (c *conn) prefix() string {
+	return c.nick + "!" + c.user + "@localhost"
+}
+
+// relay sends a line from c to everyone sharing a channel with c,
+// and to c itself if self is true. It must be called with s.mu held.
+func (c *conn) relay(self bool, format string, args ...interface{}) {
+	seen := map[*conn]bool{c: !self}
+	if self {
+		c.sendFrom(c, format, args...)
+	}
+	for _, ch := range c.chans {
+		for m := range ch.members {
+			if !seen[m] {
+				seen[m] = true
+				m.sendFrom(c, format, args...)
+			}
+		}
+	}
+}
+
+// send sends a line from src to everyone in ch, except skip.
+// It must be called with s.mu held.
+func (ch *channel) send(src, skip *conn, format string, args ...interface{}) {
+	for m := range ch.members {
+		if m != skip {
+			m.sendFrom(src, format, args...)
+		}
+	}
+}
+
+// handle deals with a message, returning false if c should disconnect.
+func (c *conn) handle(m *message) bool {
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !c.registered {
+		switch m.cmd {
+		case "CAP", "NICK", "USER", "PASS", "PING", "QUIT":
+		default:
+			c.numeric("451", "You have not registered")
+			return true
+		}
+	}
+	need := map[string]int{"CAP": 1, "NICK": 1, "USER": 4, "JOIN": 1, "PART": 1,
+		"PRIVMSG": 2, "NOTICE": 2, "TOPIC": 1, "KICK": 2, "MODE": 1}
+	if n, ok := need[m.cmd]; ok && len(m.args) < n {
+		c.numeric("461", m.cmd, "Not enough parameters")
+		return true
+	}
+	switch m.cmd {
+	case "PASS", "PONG", "MODE":
+		// Accepted and ignored.
+	case "CAP":
+		c.capability(m.args)
+	case "PING":
+		c.send(":%s PONG %s :%s", Name, Name, strings.Join(m.args, " "))
+	case "NICK":
+		c.doNick(m.args[0])
+	case "USER":
+		if c.registered {
+			c.numeric("462", "You may not reregister")
+			return true
+		}
+		c.user, c.real = m.args[0], m.args[3]
+		c.welcome()
+	case "JOIN":
+		for _, name := range strings.Split(m.args[0], ",") {
+			c.join(name)
+		}
+	case "PART":
+		msg := c.nick
+		if len(m.args) > 1 {
+			msg = m.args[1]
+		}
+		for _, name := range strings.Split(m.args[0], ",") {
+			if ch := c.onChannel(name); ch != nil {
+				ch.send(c, nil, ":%s PART %s :%s", c.prefix(), ch.name, msg)
+				c.leave(ch)
+			}
+		}
+	case "PRIVMSG", "NOTICE":
+		target, text := m.args[0], m.args[1]
+		c.ctags = m.tags
+		defer func() { c.ctags = nil }()
+		if isChannel(target) {
+			if ch, ok := s.chans[strings.ToLower(target)]; ok {
+				ch.send(c, c, ":%s %s %s :%s", c.prefix(), m.cmd, ch.name, text)
+			} else {
+				c.numeric("403", target, "No such channel")
+			}
+		} else if to, ok := s.nicks[strings.ToLower(target)]; ok {
+			to.sendFrom(c, ":%s %s %s :%s", c.prefix(), m.cmd, to.nick, text)
+		} else {
+			c.numeric("401", target, "No such nick/channel")
+		}
+	case "TOPIC":
+		ch := c.onChannel(m.args[0])
+		switch {
+		case ch == nil:
+		case len(m.args) == 1 && ch.topic == "":
+			c.numeric("331", ch.name, "No topic is set")
+		case len(m.args) == 1:
+			c.numeric("332", ch.name, ch.topic)
+		default:
+			ch.topic = m.args[1]
+			ch.send(c, nil, ":%s TOPIC %s :%s", c.prefix(), ch.name, ch.topic)
+		}
+	case "KICK":
+		ch := c.onChannel(m.args[0])
+		if ch == nil {
+			break
+		}
+		msg := c.nick
+		if len(m.args) > 2 {
+			msg = m.args[2]
+		}
+		for _, nick := range strings.Split(m.args[1], ",") {
+			victim, ok := s.nicks[strings.ToLower(nick)]
+			if !ok || !ch.members[victim] {
+				c.numeric("441", nick, ch.name, "They aren't on that channel")
+				continue
+			}
+			ch.send(c, nil, ":%s KICK %s %s :%s", c.prefix(), ch.name, victim.nick, msg)
+			victim.leave(ch)
+		}
+	case "QUIT":
+		msg := "Quit"
+		if len(m.args) > 0 {
+			msg = "Quit: " + m.args[0]
+		}
+		c.send("ERROR :Closing link (%s)", msg)
+		c.relay(false, ":%s QUIT :%s", c.prefix(), msg)
+		c.part()
+		return false
+	default:
+		c.numeric("421", m.cmd, "Unknown command")
+	}
+	return true
+}
+
+func isChannel(s string) bool {
+	return len(s) > 0 && strings.IndexByte("#&+!", s[0]) != -1
+}
+
+// capability handles CAP subcommands. It must be called with s.mu held.
+func (c *conn) capability(args []string) {
+	sub := strings.ToUpper(args[0])
+	if (sub == "LS" || sub == "REQ") && !c.registered {
+		// Negotiating before registering holds it up until CAP END.
+		c.capping = true
+	}
+	switch sub {
+	case "LS":
+		c.send(":%s CAP %s LS :%s", Name, c.target(), strings.Join(Caps, " "))
+	case "LIST":
+		var caps []string
+		for cp := range c.caps {
+			caps = append(caps, cp)
+		}
+		sort.Strings(caps)
+		c.send(":%s CAP %s LIST :%s", Name, c.target(), strings.Join(caps, " "))
+	case "REQ":
+		var req []string
+		if len(args) > 1 {
+			req = strings.Fields(args[1])
+		}
+		for _, cp := range req {
+			if !supported(strings.TrimPrefix(cp, "-")) {
+				c.send(":%s CAP %s NAK :%s", Name, c.target(), strings.Join(req, " "))
+				return
+			}
+		}
+		for _, cp := range req {
+			if strings.HasPrefix(cp, "-") {
+				delete(c.caps, cp[1:])
+			} else {
+				c.caps[cp] = true
+			}
+		}
+		c.send(":%s CAP %s ACK :%s", Name, c.target(), strings.Join(req, " "))
+	case "END":
+		c.capping = false
+		c.welcome()
+	default:
+		c.numeric("410", args[0], "Invalid CAP command")
+	}
+}
+
+func supported(cp string) bool {
+	for _, s := range Caps {
+		if s == cp {
+			return true
+		}
+	}
+	return false
+}
+
+// accountOrStar must be called with s.mu held.
+func (c *conn) accountOrStar() string {
+	if c.account == "" {
+		return "*"
+	}
+	return c.account
+}
+
+// onChannel returns the channel called name if c is on it, or replies
+// with an error. It must be called with s.mu held.
+func (c *conn) onChannel(name string) *channel {
+	ch, ok := c.chans[strings.ToLower(name)]
+	if !ok {
+		c.numeric("442", name, "You're not on that channel")
+		return nil
+	}
+	return ch
+}
+
+// doNick must be called with s.mu held.
+func (c *conn) doNick(nick string) {
+	s := c.s
+	if other, ok := s.nicks[strings.ToLower(nick)]; ok && other != c {
+		c.numeric("433", nick, "Nickname is already in use")
+		return
+	}
+	if !c.registered {
+		c.nick = nick
+		c.welcome()
+		return
+	}
+	c.relay(true, ":%s NICK :%s", c.prefix(), nick)
+	delete(s.nicks, strings.ToLower(c.nick))
+	c.nick = nick
+	s.nicks[strings.ToLower(nick)] = c
+}
+
+// welcome registers c once it has sent both NICK and USER.
+// It must be called with s.mu held.
+func (c *conn) welcome() {
+	if c.nick == "" || c.user == "" || c.registered || c.capping {
+		return
+	}
+	c.registered = true
+	c.s.nicks[strings.ToLower(c.nick)] = c
+	c.numeric("001", "Welcome to the test IRC network "+c.prefix())
+	c.numeric("376", "End of /MOTD command.")
+}
+
+// join must be called with s.mu held.
+func (c *conn) join(name string) {
+	if !isChannel(name) {
+		c.numeric("403", name, "No such channel")
+		return
+	}
+	key := strings.ToLower(name)
+	ch, ok := c.s.chans[key]
+	if !ok {
+		ch = &channel{name: name, members: make(map[*conn]bool)}
+		c.s.chans[key] = ch
+	}
+	if ch.members[c] {
+		return
+	}
+	ch.members[c] = true
+	c.chans[key] = ch
+	for m := range ch.members {
+		if m.caps["extended-join"] {
+			m.sendFrom(c, ":%s JOIN %s %s :%s", c.prefix(), ch.name,
+				c.accountOrStar(), c.real)
+		} else {
+			m.sendFrom(c, ":%s JOIN %s", c.prefix(), ch.name)
+		}
+	}
+	if ch.topic != "" {
+		c.numeric("332", ch.name, ch.topic)
+	}
+	c.numeric("353", "=", ch.name, strings.Join(ch.nicks(), " "))
+	c.numeric("366", ch.name, "End of /NAMES list.")
+}
+
+// leave removes c from ch. It must be called with s.mu held.
+func (c *conn) leave(ch *channel) {
+	delete(ch.members, c)
+	delete(c.chans, strings.ToLower(ch.name))
+	if len(ch.members) == 0 {
+		delete(c.s.chans, strings.ToLower(ch.name))
+	}
+}
+
+// part removes c from all channels. It must be called with s.mu held.
+func (c *conn) part() {
+	for _, ch := range c.chans {
+		c.leave(ch)
+	}
+}
+
+// quit cleans up after c disconnects, telling others if it didn't QUIT.
+func (c *conn) quit(msg string) {
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(c.chans) > 0 {
+		c.relay(false, ":%s QUIT :%s", c.prefix(), msg)
+		c.part()
+	}
+	if c.registered && s.nicks[strings.ToLower(c.nick)] == c {
+		delete(s.nicks, strings.ToLower(c.nick))
+	}
+	delete(s.conns, c)
+	c.sock.Close()
+}
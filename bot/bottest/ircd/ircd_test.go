@@ -0,0 +1,167 @@
+package ircd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		cmd  string
+		args []string
+	}{
+		{"NICK foo\r\n", "NICK", []string{"foo"}},
+		{":foo!bar@baz privmsg #chan :hello there", "PRIVMSG", []string{"#chan", "hello there"}},
+		{"USER foo 0 * :Real Name", "USER", []string{"foo", "0", "*", "Real Name"}},
+		{"TOPIC #chan :", "TOPIC", []string{"#chan", ""}},
+	}
+	for i, test := range tests {
+		m := parse(test.in)
+		if m == nil || m.cmd != test.cmd || !reflect.DeepEqual(m.args, test.args) {
+			t.Errorf("parse(%d) %q: exp %s %q got %+v", i, test.in, test.cmd, test.args, m)
+		}
+	}
+	for _, bad := range []string{"", "  ", ":prefix-only"} {
+		if m := parse(bad); m != nil {
+			t.Errorf("parse(%q): expected nil, got %+v", bad, m)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	m := parse("@+foo=bar;baz :nick PRIVMSG #chan :hi")
+	exp := map[string]string{"+foo": "bar", "baz": ""}
+	if m == nil || m.cmd != "PRIVMSG" || !reflect.DeepEqual(m.tags, exp) {
+		t.Errorf("parse: exp tags %v got %+v", exp, m)
+	}
+	if m := parse("@tags-only"); m != nil {
+		t.Errorf("parse: expected nil, got %+v", m)
+	}
+}
+
+func connect(t *testing.T, s *Server, nick string) *User {
+	t.Helper()
+	u, err := s.Connect(nick)
+	if err != nil {
+		t.Fatalf("Connect(%s): %v", nick, err)
+	}
+	return u
+}
+
+func expect(t *testing.T, u *User, substr string) {
+	t.Helper()
+	if _, err := u.Expect(substr); err != nil {
+		t.Errorf("%s: %v", u.Nick, err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	alice, bob := connect(t, s, "alice"), connect(t, s, "bob")
+	defer alice.Close()
+	defer bob.Close()
+
+	if _, err := Connect(s.Addr(), "Alice"); err == nil {
+		t.Errorf("Connect: expected nick collision error")
+	}
+
+	alice.Join("#test")
+	expect(t, alice, " 366 alice #test ")
+	bob.Join("#test")
+	expect(t, alice, ":bob!bob@localhost JOIN #test")
+	expect(t, bob, " 353 bob = #test :alice bob")
+
+	alice.Privmsg("#test", "hi bob")
+	expect(t, bob, ":alice!alice@localhost PRIVMSG #test :hi bob")
+	bob.Notice("alice", "psst")
+	expect(t, alice, ":bob!bob@localhost NOTICE alice :psst")
+	bob.Privmsg("carol", "hello?")
+	expect(t, bob, " 401 bob carol ")
+
+	alice.Send("TOPIC #test :testing things")
+	expect(t, bob, "TOPIC #test :testing things")
+	if topic := s.Topic("#test"); topic != "testing things" {
+		t.Errorf("Topic: got %q", topic)
+	}
+
+	bob.SetNick("robert")
+	expect(t, alice, ":bob!bob@localhost NICK :robert")
+	alice.Send("KICK #test robert :bye")
+	expect(t, bob, "KICK #test robert :bye")
+	if m := s.Members("#test"); !reflect.DeepEqual(m, []string{"alice"}) {
+		t.Errorf("Members after kick: got %v", m)
+	}
+
+	bob.Join("#test")
+	expect(t, alice, "JOIN #test")
+	bob.Quit("gone")
+	expect(t, alice, ":robert!bob@localhost QUIT :Quit: gone")
+
+	if !s.Disconnect("alice") {
+		t.Errorf("Disconnect: expected alice to be connected")
+	}
+	if _, err := alice.Expect("never"); err == nil {
+		t.Errorf("Expect: expected error after disconnect")
+	}
+	if s.Disconnect("alice") {
+		t.Errorf("Disconnect: expected alice to be gone")
+	}
+}
+
+func TestCaps(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	alice := connect(t, s, "alice")
+	defer alice.Close()
+	bob, err := s.Connect("bob", "server-time", "account-tag", "extended-join",
+		"message-tags", "account-notify")
+	if err != nil {
+		t.Fatalf("Connect(bob): %v", err)
+	}
+	defer bob.Close()
+
+	bob.Send("CAP REQ :sasl")
+	expect(t, bob, "CAP bob NAK :sasl")
+	bob.Send("CAP LIST")
+	expect(t, bob, "CAP bob LIST :account-notify account-tag extended-join message-tags server-time")
+
+	bob.Join("#test")
+	expect(t, bob, " 366 bob #test ")
+	s.Login("alice", "alice_acct")
+	alice.Join("#test")
+	expect(t, bob, " :alice!alice@localhost JOIN #test alice_acct :Test user alice")
+
+	when := time.Date(2020, 2, 29, 12, 34, 56, 789e6, time.UTC)
+	s.SetTime(when)
+	alice.Send("@+example=yes;dropped=no PRIVMSG #test :hi")
+	expect(t, bob, "@time=2020-02-29T12:34:56.789Z;account=alice_acct;+example=yes "+
+		":alice!alice@localhost PRIVMSG #test :hi")
+	s.SetTime(time.Time{})
+
+	s.Login("alice", "")
+	expect(t, bob, ":alice!alice@localhost ACCOUNT *")
+	alice.Privmsg("#test", "bye")
+	l, err := bob.Expect("PRIVMSG #test :bye")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(l, "@time=") || strings.Contains(l, "account=") {
+		t.Errorf("expected only a time tag, got %q", l)
+	}
+
+	// Alice didn't ask for any capabilities, so gets no tags.
+	bob.Privmsg("#test", "hello")
+	expect(t, alice, ":bob!bob@localhost PRIVMSG #test :hello")
+	bob.Send("CAP BOGUS")
+	expect(t, bob, " 410 bob BOGUS ")
+}
@@ -0,0 +1,162 @@
+package ircd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A User is a scripted IRC client for talking to the bot in tests.
+type User struct {
+	Nick string
+	sock net.Conn
+
+	mu    sync.Mutex
+	lines []string
+	more  chan struct{}
+	err   error
+}
+
+// Connect registers a new User with nick on the server at addr,
+// first enabling any IRCv3 capabilities given.
+func Connect(addr, nick string, caps ...string) (*User, error) {
+	sock, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	u := &User{Nick: nick, sock: sock, more: make(chan struct{}, 1)}
+	go u.recv()
+	if len(caps) > 0 {
+		u.Send("CAP LS 302")
+		u.Send("CAP REQ :%s", strings.Join(caps, " "))
+	}
+	u.Send("NICK %s", nick)
+	u.Send("USER %s 0 * :Test user %s", strings.ToLower(nick), nick)
+	if len(caps) > 0 {
+		u.Send("CAP END")
+	}
+	l, err := u.expect("registration", func(l string) bool {
+		return strings.Contains(l, " 001 ") || strings.Contains(l, " 433 ")
+	})
+	if err == nil && strings.Contains(l, " 433 ") {
+		err = fmt.Errorf("nick in use")
+	}
+	if err != nil {
+		sock.Close()
+		return nil, fmt.Errorf("registering %s: %v", nick, err)
+	}
+	return u, nil
+}
+
+// Connect registers a new User with nick on s.
+func (s *Server) Connect(nick string, caps ...string) (*User, error) {
+	return Connect(s.Addr(), nick, caps...)
+}
+
+func (u *User) recv() {
+	r := bufio.NewReader(u.sock)
+	for {
+		line, err := r.ReadString('\n')
+		u.mu.Lock()
+		if err != nil {
+			u.err = err
+		} else {
+			u.lines = append(u.lines, strings.TrimRight(line, "\r\n"))
+		}
+		u.mu.Unlock()
+		select {
+		case u.more <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Send sends a raw line to the server.
+func (u *User) Send(format string, args ...interface{}) {
+	fmt.Fprintf(u.sock, format+"\r\n", args...)
+}
+
+func (u *User) Join(ch string) {
+	u.Send("JOIN %s", ch)
+}
+
+func (u *User) Part(ch string) {
+	u.Send("PART %s", ch)
+}
+
+func (u *User) Privmsg(target, text string) {
+	u.Send("PRIVMSG %s :%s", target, text)
+}
+
+func (u *User) Notice(target, text string) {
+	u.Send("NOTICE %s :%s", target, text)
+}
+
+// SetNick changes the User's nick. It doesn't check the server accepted it.
+func (u *User) SetNick(nick string) {
+	u.Send("NICK %s", nick)
+	u.Nick = nick
+}
+
+// Quit disconnects from the server politely.
+func (u *User) Quit(msg string) {
+	u.Send("QUIT :%s", msg)
+	u.Expect("ERROR ")
+	u.sock.Close()
+}
+
+// Close drops the connection.
+func (u *User) Close() {
+	u.sock.Close()
+}
+
+// Timeout is how long Expect waits for a line.
+var Timeout = 5 * time.Second
+
+// Expect waits for a line containing substr, discarding lines received
+// before it. Lines after it are kept for the next call.
+func (u *User) Expect(substr string) (string, error) {
+	return u.expect(fmt.Sprintf("%q", substr), func(l string) bool {
+		return strings.Contains(l, substr)
+	})
+}
+
+func (u *User) expect(what string, match func(string) bool) (string, error) {
+	deadline := time.After(Timeout)
+	for {
+		u.mu.Lock()
+		for i, l := range u.lines {
+			if match(l) {
+				u.lines = u.lines[i+1:]
+				u.mu.Unlock()
+				return l, nil
+			}
+		}
+		u.lines = nil
+		err := u.err
+		u.mu.Unlock()
+		if err != nil {
+			return "", fmt.Errorf("waiting for %s: %v", what, err)
+		}
+		select {
+		case <-u.more:
+		case <-deadline:
+			return "", fmt.Errorf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// Lines returns the lines received since the last call to Expect or Lines.
+func (u *User) Lines() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	lines := u.lines
+	u.lines = nil
+	return lines
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+// Conf namespace for changes the join and part commands have made to the
+// channels joined on each server, keyed by the server's lowercase
+// host:port. Values are space-separated lists of channels, each prefixed
+// with "+" if it was joined or "-" if it was parted. The channels in a
+// server's config are joined unless they have been parted, so changes
+// to the config still take effect.
+const channelsNs = "channels"
+
+// parseChanges splits a stored list of changes into the channels joined
+// and parted. Channels without a prefix were stored by older versions,
+// which kept the whole list; they count as joined.
+func parseChanges(s string) (joined, parted []string) {
+	for _, ch := range strings.Fields(s) {
+		switch {
+		case ch == "none":
+		case strings.HasPrefix(ch, "-"):
+			parted = append(parted, ch[1:])
+		default:
+			joined = append(joined, strings.TrimPrefix(ch, "+"))
+		}
+	}
+	return joined, parted
+}
+
+// channelChanges returns the channels joined and parted on server.
+func channelChanges(server string) (joined, parted []string) {
+	return parseChanges(conf.Ns(channelsNs).String(strings.ToLower(server)))
+}
+
+func saveChannelChanges(server string, joined, parted []string) {
+	var changes []string
+	for _, ch := range joined {
+		changes = append(changes, "+"+ch)
+	}
+	for _, ch := range parted {
+		changes = append(changes, "-"+ch)
+	}
+	if len(changes) == 0 {
+		conf.Ns(channelsNs).Delete(strings.ToLower(server))
+		return
+	}
+	conf.Ns(channelsNs).String(strings.ToLower(server), strings.Join(changes, " "))
+}
+
+// mergeChannels returns chans with joined added and parted removed.
+func mergeChannels(chans, joined, parted []string) []string {
+	for _, ch := range joined {
+		chans, _ = withChannel(chans, ch)
+	}
+	for _, ch := range parted {
+		chans, _ = withoutChannel(chans, ch)
+	}
+	return chans
+}
+
+// channelsFor returns the channels the bot should be in on t.
+func channelsFor(t Transport) []string {
+	joined, parted := channelChanges(t.Name())
+	return mergeChannels(bot.servers.Config(t).Channels, joined, parted)
+}
+
+// withChannel returns chans with ch added, and whether it was missing.
+func withChannel(chans []string, ch string) ([]string, bool) {
+	for _, c := range chans {
+		if strings.EqualFold(c, ch) {
+			return chans, false
+		}
+	}
+	return append(chans[:len(chans):len(chans)], ch), true
+}
+
+// withoutChannel returns chans with ch removed, and whether it was present.
+func withoutChannel(chans []string, ch string) ([]string, bool) {
+	out := make([]string, 0, len(chans))
+	for _, c := range chans {
+		if !strings.EqualFold(c, ch) {
+			out = append(out, c)
+		}
+	}
+	return out, len(out) != len(chans)
+}
+
+// addChannel remembers to join ch on t after reconnecting.
+func addChannel(t Transport, ch string) {
+	joined, parted := channelChanges(t.Name())
+	joined, _ = withChannel(joined, ch)
+	parted, _ = withoutChannel(parted, ch)
+	saveChannelChanges(t.Name(), joined, parted)
+}
+
+// removeChannel stops ch being joined on t after reconnecting.
+func removeChannel(t Transport, ch string) {
+	joined, parted := channelChanges(t.Name())
+	joined, _ = withoutChannel(joined, ch)
+	parted, _ = withChannel(parted, ch)
+	saveChannelChanges(t.Name(), joined, parted)
+}
+
+// resetChannels forgets the join and part commands used on t, so that
+// only the channels in its config are joined after reconnecting.
+func resetChannels(t Transport) {
+	saveChannelChanges(t.Name(), nil, nil)
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChanges(t *testing.T) {
+	tests := []struct {
+		in             string
+		joined, parted []string
+	}{
+		{"", nil, nil},
+		{"none", nil, nil},
+		{"+#a  -#b +#c", []string{"#a", "#c"}, []string{"#b"}},
+		{"#a #b", []string{"#a", "#b"}, nil},
+	}
+	for i, test := range tests {
+		joined, parted := parseChanges(test.in)
+		if !reflect.DeepEqual(joined, test.joined) || !reflect.DeepEqual(parted, test.parted) {
+			t.Errorf("parseChanges(%d) %q: exp %v %v got %v %v",
+				i, test.in, test.joined, test.parted, joined, parted)
+		}
+	}
+}
+
+func TestMergeChannels(t *testing.T) {
+	out := mergeChannels([]string{"#a", "#b"}, []string{"#c", "#A"}, []string{"#B"})
+	if exp := []string{"#a", "#c"}; !reflect.DeepEqual(out, exp) {
+		t.Errorf("mergeChannels: exp %v got %v", exp, out)
+	}
+}
+
+func TestWithChannel(t *testing.T) {
+	chans := []string{"#a", "#B"}
+	if out, ok := withChannel(chans, "#b"); ok || !reflect.DeepEqual(out, chans) {
+		t.Errorf("withChannel: expected #b to be present already, got %v %t", out, ok)
+	}
+	out, ok := withChannel(chans, "#c")
+	if exp := []string{"#a", "#B", "#c"}; !ok || !reflect.DeepEqual(out, exp) {
+		t.Errorf("withChannel: exp %v got %v %t", exp, out, ok)
+	}
+	if len(chans) != 2 {
+		t.Errorf("withChannel: modified input %v", chans)
+	}
+	if out, ok := withChannel(nil, "#a"); !ok || !reflect.DeepEqual(out, []string{"#a"}) {
+		t.Errorf("withChannel(nil): got %v %t", out, ok)
+	}
+}
+
+func TestWithoutChannel(t *testing.T) {
+	chans := []string{"#a", "#B"}
+	if out, ok := withoutChannel(chans, "#c"); ok || !reflect.DeepEqual(out, chans) {
+		t.Errorf("withoutChannel: expected #c to be absent, got %v %t", out, ok)
+	}
+	out, ok := withoutChannel(chans, "#b")
+	if exp := []string{"#a"}; !ok || !reflect.DeepEqual(out, exp) {
+		t.Errorf("withoutChannel: exp %v got %v %t", exp, out, ok)
+	}
+	if out, ok := withoutChannel([]string{"#a"}, "#A"); !ok || len(out) != 0 {
+		t.Errorf("withoutChannel: expected empty list, got %v %t", out, ok)
+	}
+}
@@ -1,27 +1,633 @@
 package bot
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/collections/schedules"
+	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/datetime"
 )
 
-const ignoreNs = "ignore"
-
+// ignore <mask|/regex/> [in <#chan>] [for <duration>] [commands <cmd>, ...]
 func ignore(ctx *Context) {
-	nick := strings.ToLower(strings.Fields(ctx.Text())[0])
-	if nick == "" {
+	ir, err := parseIgnore(ctx.Text())
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	ir.By = ctx.Nick
+	if err := ignores.add(ir); err != nil {
+		ctx.ReplyN("Couldn't store ignore rule: %v", err)
 		return
 	}
-	conf.Ns(ignoreNs).String(nick, "ignore")
-	ctx.ReplyN("I'll ignore '%s'.", nick)
+	ctx.Audit("ignores", ir.key(), "")
+	ctx.ReplyN("I'll ignore %s.", ir)
+}
+
+func parseIgnore(txt string) (*ignoreRule, error) {
+	txt = strings.TrimSpace(txt)
+	if txt == "" {
+		return nil, fmt.Errorf("Ignore whom?")
+	}
+	// Regexes may contain spaces, so find the closing slash by hand.
+	end := strings.Index(txt, " ")
+	if txt[0] == '/' {
+		if end = strings.Index(txt[1:], "/ ") + 2; end == 1 {
+			end = len(txt)
+		}
+	}
+	if end == -1 {
+		end = len(txt)
+	}
+	ir, err := newIgnoreRule(txt[:end])
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't parse '%s': %v", txt[:end], err)
+	}
+	fields := strings.Fields(txt[end:])
+	for i := 0; i < len(fields); i++ {
+		switch kw := strings.ToLower(fields[i]); {
+		case kw == "commands" && i+1 < len(fields):
+			for _, c := range strings.Split(strings.Join(fields[i+1:], " "), ",") {
+				if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+					ir.Cmds = append(ir.Cmds, c)
+				}
+			}
+			sort.Strings(ir.Cmds)
+			i = len(fields)
+		case kw == "in" && i+1 < len(fields):
+			i++
+			if ir.Chan = strings.ToLower(fields[i]); ir.Chan[0] != '#' {
+				return nil, fmt.Errorf("'%s' doesn't look like a channel.", fields[i])
+			}
+		case kw == "for" && i+1 < len(fields):
+			i++
+			d, err := util.ParseDuration(fields[i])
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("'%s' doesn't look like a duration.", fields[i])
+			}
+			ir.Expires = time.Now().Add(d)
+		default:
+			return nil, fmt.Errorf("Didn't understand '%s'. Usage: ignore "+
+				"<mask|/regex/> [in <#chan>] [for <duration>] [commands <cmd>, ...]",
+				strings.Join(fields[i:], " "))
+		}
+	}
+	return ir, nil
 }
 
 func unignore(ctx *Context) {
-	nick := strings.ToLower(strings.Fields(ctx.Text())[0])
-	if nick == "" {
+	pattern := strings.TrimSpace(ctx.Text())
+	if pattern == "" {
+		ctx.ReplyN("Unignore whom?")
+		return
+	}
+	if removed := ignores.remove(pattern); len(removed) > 0 {
+		for _, ir := range removed {
+			ctx.Audit("ignores", ir.key(), ir.String())
+		}
+		ctx.ReplyN("No longer ignoring '%s' (%d rules removed).", pattern, len(removed))
+	} else {
+		ctx.ReplyN("I wasn't ignoring '%s'.", pattern)
+	}
+}
+
+func ignoreList(ctx *Context) {
+	rules := ignores.active()
+	if len(rules) == 0 {
+		ctx.ReplyN("I'm not ignoring anyone.")
+		return
+	}
+	s := make([]string, len(rules))
+	for i, ir := range rules {
+		s[i] = ir.String()
+	}
+	ctx.ReplyN("Ignoring: %s.", strings.Join(s, "; "))
+}
+
+func grant(ctx *Context) {
+	name := ctx.Params.String("role")
+	r, ok := RoleForName(name)
+	if !ok {
+		ctx.ReplyN("'%s' isn't a role. Try user, trusted, admin or owner.", name)
+		return
+	}
+	mine := ctx.Role()
+	if !canManage(mine, r) {
+		ctx.ReplyN("You can't grant a role as high as your own.")
+		return
+	}
+	mask := NormalizeMask(ctx.Params.String("nick|mask"))
+	if r > User && AnyHost(mask) {
+		ctx.ReplyN("Granting %s needs a mask with an ident or host, "+
+			"since anyone can use a nick.", r)
+		return
+	}
+	prior := conf.Ns(rolesNs).String(mask)
+	if old, ok := RoleForName(prior); ok && !canManage(mine, old) {
+		ctx.ReplyN("'%s' is already %s, which you can't change.", mask, old)
+		return
+	}
+	grantRole(mask, r)
+	ctx.Audit("roles", mask, prior)
+	ctx.ReplyN("'%s' is now %s.", mask, r)
+}
+
+func revoke(ctx *Context) {
+	mask := NormalizeMask(ctx.Params.String("nick|mask"))
+	if old, ok := RoleForName(conf.Ns(rolesNs).String(mask)); ok && !canManage(ctx.Role(), old) {
+		ctx.ReplyN("'%s' is %s, which you can't revoke.", mask, old)
+		return
+	}
+	if r, ok := revokeRole(mask); ok {
+		ctx.Audit("roles", mask, r.String())
+		ctx.ReplyN("'%s' is no longer %s.", mask, r)
+	} else {
+		ctx.ReplyN("'%s' doesn't hold any role.", mask)
+	}
+}
+
+// canManage returns true if someone holding role mine may grant or revoke
+// role r. Only owners may hand out or take away their own role.
+func canManage(mine, r Role) bool {
+	return r < mine || mine == Owner
+}
+
+func roles(ctx *Context) {
+	want, all := User, !ctx.Params.Has("role")
+	if !all {
+		var ok bool
+		name := ctx.Params.String("role")
+		if want, ok = RoleForName(name); !ok {
+			ctx.ReplyN("'%s' isn't a role. Try user, trusted, admin or owner.", name)
+			return
+		}
+	}
+	held := make([]string, 0)
+	for _, g := range Grants() {
+		if all || g.Role == want {
+			held = append(held, g.Mask+" ("+g.Role.String()+")")
+		}
+	}
+	if len(held) == 0 {
+		ctx.ReplyN("Nobody has been granted that.")
+		return
+	}
+	sort.Strings(held)
+	ctx.ReplyN("Roles: %s.", strings.Join(held, ", "))
+}
+
+// policyArgs splits "<name> ... [in <#chan>]" into a channel and names,
+// defaulting to the channel the command was given in. Multi-word command
+// prefixes are quoted, e.g. disable "fact search".
+func policyArgs(ctx *Context) (string, []string, error) {
+	fields, err := splitItems(strings.ToLower(ctx.Text()))
+	if err != nil {
+		return "", nil, err
+	}
+	ch := ctx.channel()
+	if n := len(fields); n >= 2 && fields[n-2] == "in" {
+		ch, fields = fields[n-1], fields[:n-2]
+	} else if n == 1 && isChannel(fields[0]) {
+		ch, fields = fields[0], nil
+	}
+	if !isChannel(ch) {
+		return "", nil, fmt.Errorf("Which channel?")
+	}
+	for _, name := range fields {
+		if !policies.isKnown(name) {
+			return "", nil, fmt.Errorf("I don't know anything called '%s'.", name)
+		}
+	}
+	return ch, fields, nil
+}
+
+func disable(ctx *Context) {
+	changePolicy(ctx, false)
+}
+
+func enable(ctx *Context) {
+	changePolicy(ctx, true)
+}
+
+func changePolicy(ctx *Context, on bool) {
+	ch, names, err := policyArgs(ctx)
+	if err == nil && len(names) == 0 {
+		err = fmt.Errorf("Which drivers, commands or handlers?")
+	}
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	p := policies.get(ch).copy()
+	// In allow mode items are enabled, in deny mode they're disabled.
+	for _, name := range names {
+		if on == p.allow {
+			p.items[name] = true
+		} else {
+			delete(p.items, name)
+		}
+	}
+	policies.set(ch, p)
+	reportPolicy(ctx, ch)
+}
+
+func allowOnly(ctx *Context) {
+	ch, names, err := policyArgs(ctx)
+	if err == nil && len(names) == 0 {
+		err = fmt.Errorf("Allow what?")
+	}
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	p := &policy{allow: true, items: map[string]bool{}}
+	for _, name := range names {
+		p.items[name] = true
+	}
+	policies.set(ch, p)
+	reportPolicy(ctx, ch)
+}
+
+func showPolicy(ctx *Context) {
+	ch, names, err := policyArgs(ctx)
+	if err == nil && len(names) > 0 {
+		err = fmt.Errorf("Usage: policy [<#chan>]")
+	}
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	reportPolicy(ctx, ch)
+}
+
+func resetPolicy(ctx *Context) {
+	ch, names, err := policyArgs(ctx)
+	if err == nil && len(names) > 0 {
+		err = fmt.Errorf("Usage: policy reset [<#chan>]")
+	}
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	policies.set(ch, nil)
+	reportPolicy(ctx, ch)
+}
+
+func reportPolicy(ctx *Context, ch string) {
+	switch p := policies.get(ch); {
+	case p == nil:
+		ctx.ReplyN("Everything is enabled in %s.", ch)
+	case p.allow:
+		ctx.ReplyN("Only %s enabled in %s.", strings.Join(p.list(), ", "), ch)
+	default:
+		ctx.ReplyN("Everything except %s enabled in %s.",
+			strings.Join(p.list(), ", "), ch)
+	}
+}
+
+// ratelimit [[<name>:]<scope> <burst>/<period>|off]
+func rateLimit(ctx *Context) {
+	fields := strings.Fields(strings.ToLower(ctx.Text()))
+	if len(fields) == 0 {
+		ctx.ReplyN("Rate limits: %s.", strings.Join(limiter.list(), ", "))
+		return
+	}
+	if len(fields) != 2 {
+		ctx.ReplyN("Usage: ratelimit [[<name>:]<scope> <burst>/<period>|off]")
+		return
+	}
+	key, scope := fields[0], fields[0]
+	if idx := strings.Index(key, ":"); idx != -1 {
+		scope = key[idx+1:]
+	}
+	valid := false
+	for _, s := range limitScopes {
+		valid = valid || s == scope
+	}
+	if !valid {
+		ctx.ReplyN("'%s' isn't a scope. Try %s.", scope, strings.Join(limitScopes, ", "))
+		return
+	}
+	l, err := parseLimit(fields[1])
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	limiter.set(key, l)
+	ctx.ReplyN("Rate limit for %s is now %s.", key, l)
+}
+
+// triggerArgs splits "[<prefix> ...] [in <#chan>]" into a conf key,
+// defaulting to the global triggers, and prefixes.
+func triggerArgs(ctx *Context) (string, []string) {
+	fields := strings.Fields(ctx.Text())
+	if n := len(fields); n >= 2 && fields[n-2] == "in" && isChannel(fields[n-1]) {
+		return fields[n-1], fields[:n-2]
+	} else if n == 1 && isChannel(fields[0]) {
+		return fields[0], nil
+	}
+	return globalTriggers, fields
+}
+
+func showTriggers(ctx *Context) {
+	key, args := triggerArgs(ctx)
+	if len(args) > 0 {
+		ctx.ReplyN("Usage: triggers [<#chan>]")
+		return
+	}
+	reportTriggers(ctx, key)
+}
+
+func setTriggers(ctx *Context) {
+	key, args := triggerArgs(ctx)
+	if len(args) == 0 {
+		ctx.ReplyN("Set which triggers? Use 'none' to disable them.")
+		return
+	}
+	t := []string{}
+	if len(args) > 1 || args[0] != "none" {
+		for _, arg := range args {
+			if !validTrigger(arg) {
+				ctx.ReplyN("'%s' can't be a trigger; use up to three "+
+					"punctuation characters.", arg)
+				return
+			}
+		}
+		t = args
+	}
+	triggers.set(key, t)
+	reportTriggers(ctx, key)
+}
+
+func resetTriggers(ctx *Context) {
+	key, args := triggerArgs(ctx)
+	if len(args) > 0 {
+		ctx.ReplyN("Usage: triggers reset [<#chan>]")
+		return
+	}
+	triggers.set(key, nil)
+	reportTriggers(ctx, key)
+}
+
+func reportTriggers(ctx *Context, key string) {
+	where := "by default"
+	if key != globalTriggers {
+		where = "in " + key
+	}
+	if t := triggers.For(key); len(t) > 0 {
+		ctx.ReplyN("Commands can be triggered with %s %s.",
+			strings.Join(t, " "), where)
+	} else {
+		ctx.ReplyN("Commands must be addressed to me %s.", where)
+	}
+}
+
+func join(ctx *Context) {
+	ch := ctx.Params.String("chan")
+	addChannel(ctx.conn, ch)
+	ctx.conn.Join(ch)
+	ctx.ReplyN("Joining %s.", ch)
+}
+
+// targetChan returns the "chan" param, or the channel the command was
+// given in, replying and returning "" if there's neither.
+func targetChan(ctx *Context) string {
+	ch := ctx.Params.String("chan")
+	if ch == "" {
+		ch = ctx.channel()
+	}
+	if ch == "" {
+		ctx.ReplyN("Which channel?")
+	}
+	return ch
+}
+
+func part(ctx *Context) {
+	ch := targetChan(ctx)
+	if ch == "" {
+		return
+	}
+	removeChannel(ctx.conn, ch)
+	msg := ctx.Params.String("message")
+	if msg == "" {
+		msg = "Bye!"
+	}
+	// Replies are queued, so they'd arrive after we'd left.
+	if !strings.EqualFold(ch, ctx.Target()) {
+		ctx.ReplyN("Leaving %s.", ch)
+	}
+	ctx.conn.Part(ch, msg)
+}
+
+func listChannels(ctx *Context) {
+	if chans := channelsFor(ctx.conn); len(chans) > 0 {
+		ctx.ReplyN("I join %s after connecting.", strings.Join(chans, " "))
+	} else {
+		ctx.ReplyN("I don't join any channels after connecting.")
+	}
+}
+
+func resetChannelsCmd(ctx *Context) {
+	resetChannels(ctx.conn)
+	ctx.ReplyN("Forgot channels joined and parted; I'll join the "+
+		"configured channels after connecting: %s.",
+		strings.Join(bot.servers.Config(ctx.conn).Channels, " "))
+}
+
+func cycle(ctx *Context) {
+	if ch := targetChan(ctx); ch != "" {
+		ctx.conn.Part(ch, "Cycling.")
+		ctx.conn.Join(ch)
+	}
+}
+
+func changeNick(ctx *Context) {
+	n := ctx.Params.String("nick")
+	ctx.ReplyN("Trying to change nick to %s.", n)
+	ctx.conn.SetNick(n)
+}
+
+// sayArgs returns the target and text params, replying if the target
+// is neither a channel nor a nick.
+func sayArgs(ctx *Context) (string, string, bool) {
+	target := ctx.Params.String("target")
+	if !isChannel(target) && !isNick(target) {
+		ctx.ReplyN("'%s' isn't a channel or a nick.", target)
+		return "", "", false
+	}
+	return target, ctx.Params.String("text"), true
+}
+
+func say(ctx *Context) {
+	if target, text, ok := sayArgs(ctx); ok {
+		ctx.Privmsg(target, text)
+	}
+}
+
+func act(ctx *Context) {
+	if target, text, ok := sayArgs(ctx); ok {
+		ctx.Action(target, text)
+	}
+}
+
+func topic(ctx *Context) {
+	if ch := targetChan(ctx); ch != "" {
+		ctx.Topic(ch, ctx.Params.String("topic"))
+	}
+}
+
+func aliasAdd(ctx *Context) {
+	name, exp, err := parseAlias(ctx.Text())
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	cs := bot.commands.(*commandSet)
+	if cs.shadows(name) {
+		ctx.ReplyN("'%s' would hide an existing command.", name)
+		return
+	}
+	m := aliases.snapshot()
+	prior := m[name]
+	m[name] = exp
+	target, err := aliasTarget(m, name)
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	if r, _ := cs.match(target); r == nil {
+		ctx.ReplyN("'%s' doesn't start with a command I know.", target)
+		return
+	}
+	aliases.set(name, exp)
+	ctx.Audit("aliases", name, prior)
+	ctx.ReplyN("'%s' now runs '%s'.", name, exp)
+}
+
+func aliasDel(ctx *Context) {
+	name, _, err := splitQuoted(ctx.Text())
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	if name = strings.ToLower(name); name == "" {
+		ctx.ReplyN("Remove which alias?")
+		return
+	}
+	prior, ok := aliases.get(name)
+	if !ok {
+		ctx.ReplyN("'%s' isn't an alias.", name)
+		return
+	}
+	aliases.set(name, "")
+	ctx.Audit("aliases", name, prior)
+	ctx.ReplyN("'%s' no longer runs '%s'.", name, prior)
+}
+
+func aliasList(ctx *Context) {
+	l := aliases.list()
+	if len(l) == 0 {
+		ctx.ReplyN("No aliases are defined.")
+		return
+	}
+	ctx.ReplyPagedList("Aliases: ", l, "; ")
+}
+
+func listDrivers(ctx *Context) {
+	l := registry.list()
+	if len(l) == 0 {
+		ctx.ReplyN("No drivers are registered.")
+		return
+	}
+	ctx.ReplyPaged("Drivers:", l)
+}
+
+func listPollers(ctx *Context) {
+	l := bot.pollers.(*pollerSet).status()
+	if len(l) == 0 {
+		ctx.ReplyN("No pollers are registered.")
+		return
+	}
+	ctx.ReplyPaged("Pollers:", l)
+}
+
+func startPoller(ctx *Context) {
+	name := ctx.Params.String("name")
+	if !bot.pollers.(*pollerSet).setStopped(name, false) {
+		ctx.ReplyN("There's no poller called '%s'.", name)
+		return
+	}
+	ctx.ReplyN("Started poller '%s'.", name)
+}
+
+func stopPoller(ctx *Context) {
+	name := ctx.Params.String("name")
+	if !bot.pollers.(*pollerSet).setStopped(name, true) {
+		ctx.ReplyN("There's no poller called '%s'.", name)
+		return
+	}
+	ctx.ReplyN("Stopped poller '%s'.", name)
+}
+
+// schedule say <text> [in <#chan>] | run <command> every <when> [catch up]
+func schedule(ctx *Context) {
+	s, err := parseSchedule(ctx.Text(), datetime.ZoneOrLocal(conf.Zone(ctx.Nick)))
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	if s.Chan == "" {
+		s.Chan = ctx.Target()
+	}
+	s.Nick, s.Src, s.Server = ctx.Nick, ctx.Src, ctx.conn.Name()
+	s = schedules.New(*s)
+	if err := schedules.Put(ctx.Ctx(), s); err != nil {
+		ctx.ReplyN("Couldn't store schedule: %v", err)
+		return
+	}
+	sched.poke()
+	ctx.Audit(schedules.COLLECTION, s.Id().Hex(), "")
+	ctx.ReplyN("I'll %s.", s)
+}
+
+func scheduleList(ctx *Context) {
+	all, err := schedules.All(ctx.Ctx())
+	if err != nil {
+		ctx.ReplyN("Couldn't load schedules: %v", err)
+		return
+	}
+	if len(all) == 0 {
+		ctx.ReplyN("Nothing is scheduled.")
+		return
+	}
+	l := make([]string, len(all))
+	for i, s := range all {
+		l[i] = fmt.Sprintf("%d: %s", i+1, s)
+	}
+	ctx.ReplyPaged("Schedules:", l)
+}
+
+func scheduleDel(ctx *Context) {
+	all, err := schedules.All(ctx.Ctx())
+	if err != nil {
+		ctx.ReplyN("Couldn't load schedules: %v", err)
+		return
+	}
+	n := ctx.Params.Int("N")
+	if n <= 0 || n > len(all) {
+		ctx.ReplyN("There's no schedule %d; see 'schedule list'.", n)
+		return
+	}
+	s := all[n-1]
+	if err := schedules.Del(ctx.Ctx(), s); err != nil {
+		ctx.ReplyN("Couldn't delete schedule: %v", err)
 		return
 	}
-	conf.Ns(ignoreNs).Delete(nick)
-	ctx.ReplyN("No longer ignoring '%s'.", nick)
+	sched.poke()
+	ctx.Audit(schedules.COLLECTION, s.Id().Hex(), s.String())
+	ctx.ReplyN("I won't %s.", s.Describe())
 }
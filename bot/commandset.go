@@ -1,19 +1,50 @@
 package bot
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+var (
+	commandsRun = metrics.NewCounter("sp0rkle_commands_total",
+		"Commands run, by prefix.", "command")
+	commandSeconds = metrics.NewHistogram("sp0rkle_command_seconds",
+		"Time taken to run commands, by prefix.", metrics.DefBuckets, "command")
+	handlerSeconds = metrics.NewHistogram("sp0rkle_handler_seconds",
+		"Time taken by event handlers, by function.", metrics.DefBuckets, "handler")
 )
 
 type HandlerFunc func(*Context)
 
-func (hf HandlerFunc) Handle(conn *client.Conn, line *client.Line) {
-	if ctx := reqContext(conn, line); ctx != nil {
-		hf(ctx)
+// namedHandler is a HandlerFunc and the names policies know it by.
+type namedHandler struct {
+	fn      HandlerFunc
+	names   []string
+	replays bool
+}
+
+func (nh namedHandler) Handle(t Transport, line *Line) {
+	if line.Replayed && !nh.replays {
+		return
+	}
+	if ctx := reqContext(t, line); ctx != nil {
+		if !policies.Permits(ctx, nh.names) {
+			return
+		}
+		name := "bot"
+		if nh.names != nil {
+			name = nh.names[len(nh.names)-1]
+		}
+		defer handlerSeconds.Since(time.Now(), name)
+		defer ctx.withTimeout(timeout.Get())()
+		nh.fn(ctx)
 	}
 }
 
@@ -23,11 +54,49 @@ type Runner interface {
 }
 
 type command struct {
-	fn   HandlerFunc
-	help string
+	fn    HandlerFunc
+	help  string
+	role  Role
+	limit string
+	args  []Arg
+	pipes bool
+	// Defaults to --timeout if zero.
+	timeout time.Duration
+	names   []string
+}
+
+// A CommandOpt configures optional behaviour of a command when it is
+// registered with Command().
+type CommandOpt func(*command)
+
+// Timeout sets the deadline for the command's Context.Ctx().
+func Timeout(d time.Duration) CommandOpt {
+	return func(c *command) { c.timeout = d }
+}
+
+// Requires restricts a command to nicks holding at least role r.
+func Requires(r Role) CommandOpt {
+	return func(c *command) { c.role = r }
 }
 
 func (c *command) Run(ctx *Context) {
+	if c.role > User && ctx.Role() < c.role {
+		ctx.Fail("You need to be %s to do that.", c.role)
+		return
+	}
+	// Mistyped arguments get a usage message without using up the limit.
+	if c.args != nil && !c.parseParams(ctx) {
+		return
+	}
+	if c.limit != "" && ctx.Limited(c.limit) {
+		ctx.failed = true
+		return
+	}
+	d := c.timeout
+	if d == 0 {
+		d = timeout.Get()
+	}
+	defer ctx.withTimeout(d)()
 	c.fn(ctx)
 }
 
@@ -36,8 +105,9 @@ func (c *command) Help() string {
 }
 
 type CommandSet interface {
-	client.Handler
+	Handler
 	Add(command Runner, prefix string)
+	Dispatch(ctx *Context)
 }
 
 type commandSet struct {
@@ -66,13 +136,25 @@ func (cs *commandSet) Add(r Runner, prefix string) {
 	cs.set[prefix] = r
 }
 
+// shadows returns true if an alias called name would hide a command.
+func (cs *commandSet) shadows(name string) bool {
+	cs.RLock()
+	defer cs.RUnlock()
+	for prefix := range cs.set {
+		if prefix == name || strings.HasPrefix(prefix, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
 // commandSet.match() mostly gratuitously stolen from net/http ;-)
 func (cs *commandSet) match(txt string) (final Runner, prefixlen int) {
 	cs.RLock()
 	defer cs.RUnlock()
 
 	for prefix, r := range cs.set {
-		if !strings.HasPrefix(txt, prefix) {
+		if !strings.HasPrefix(txt, prefix) || !wordEnd(txt, len(prefix)) {
 			continue
 		}
 		if final == nil || len(prefix) > prefixlen {
@@ -83,16 +165,34 @@ func (cs *commandSet) match(txt string) (final Runner, prefixlen int) {
 	return
 }
 
-func (cs *commandSet) possible(txt string) []string {
-	cs.RLock()
-	defer cs.RUnlock()
+// wordEnd returns true if a prefix of txt that is n bytes long doesn't
+// end part way through a word, so that e.g. "part" doesn't match "party".
+// Prefixes ending in punctuation, like "quote #", may be followed by
+// anything.
+func wordEnd(txt string, n int) bool {
+	if n == 0 || n == len(txt) || txt[n] == ' ' {
+		return true
+	}
+	c := txt[n-1]
+	return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9')
+}
 
-	poss := []string{}
+// possible returns commands and aliases that txt might be a typo of,
+// followed by those containing any of its words.
+func (cs *commandSet) possible(txt string) []string {
+	names := cs.names()
+	poss := similar(txt, names)
+	seen := make(map[string]bool)
+	for _, p := range poss {
+		seen[p] = true
+	}
+	sort.Strings(names)
 	words := strings.Fields(txt)
-	for prefix := range cs.set {
+	for _, prefix := range names {
 		for _, w := range words {
-			if strings.Contains(prefix, w) {
+			if !seen[prefix] && strings.Contains(prefix, w) {
 				poss = append(poss, prefix)
+				seen[prefix] = true
 				break
 			}
 		}
@@ -103,32 +203,89 @@ func (cs *commandSet) possible(txt string) []string {
 	return poss
 }
 
-// Implement client.Handler so commandSet can Handle things directly.
-func (cs *commandSet) Handle(conn *client.Conn, line *client.Line) {
+// Implement Handler so commandSet can Handle things directly.
+func (cs *commandSet) Handle(t Transport, line *Line) {
+	if line.Replayed {
+		// Commands were answered, if at all, when they were first sent.
+		return
+	}
 	// This is a dirty hack to treat factoid additions as a special
 	// case, since they may begin with command string prefixes.
-	ctx := reqContext(conn, line)
+	ctx := reqContext(t, line)
 	if ctx == nil || util.IsFactoidAddition(line.Text()) {
 		return
 	}
-	if r, ln := cs.match(ctx.Text()); ctx.Addressed && r != nil {
-		// Cut command off, trim and compress spaces.
-		ctx.Args[1] = strings.Join(strings.Fields(ctx.Args[1][ln:]), " ")
-		r.Run(ctx)
+	cs.Dispatch(ctx)
+}
+
+// Dispatch runs the command matching ctx's text, if ctx is addressed.
+// Aliases are expanded first, so ignores and policies apply to the
+// commands they expand to. If the text is a pipeline of commands, they
+// are run in turn; see runPipe.
+func (cs *commandSet) Dispatch(ctx *Context) {
+	if !ctx.Addressed {
+		return
+	}
+	cmds, err := cs.pipeline(ctx.Text())
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	if len(cmds) > 1 {
+		cs.runPipe(ctx, cmds)
+		return
+	}
+	ctx.Args[1] = cmds[0]
+	cs.dispatch(ctx)
+}
+
+// dispatch runs the command matching ctx's text, returning false if there
+// isn't one or it was ignored or disabled.
+func (cs *commandSet) dispatch(ctx *Context) bool {
+	r, ln := cs.match(ctx.Text())
+	if r == nil {
+		return false
+	}
+	if ignores.Ignored(ctx.Src, ctx.channel(), ctx.Text()[:ln]) {
+		return false
 	}
+	if c, ok := r.(*command); ok && !policies.Permits(ctx, c.names) {
+		return false
+	}
+	prefix := ctx.Text()[:ln]
+	ctx.cmd = prefix
+	commandsRun.Inc(prefix)
+	defer commandSeconds.Since(time.Now(), prefix)
+	// Cut command off, trim and compress spaces.
+	ctx.Args[1] = strings.Join(strings.Fields(ctx.Args[1][ln:]), " ")
+	r.Run(ctx)
+	return true
 }
 
 func (cs *commandSet) Run(ctx *Context) {
-	if r, _ := cs.match(ctx.Text()); r != nil {
+	m := aliases.snapshot()
+	name, _, isAlias := matchAlias(m, ctx.Text())
+	r, _ := cs.match(ctx.Text())
+	switch {
+	case isAlias:
+		msg := fmt.Sprintf("'%s' is an alias for '%s'.", name, m[name])
+		if txt, err := resolveAlias(m, ctx.Text()); err != nil {
+			msg += " " + err.Error()
+		} else if r, _ := cs.match(txt); r != nil {
+			msg += " " + r.Help()
+		}
+		ctx.ReplyN("%s", msg)
+	case r != nil:
 		ctx.ReplyN("%s", r.Help())
-	} else if len(ctx.Text()) == 0 {
-		ctx.ReplyN("https://github.com/fluffle/sp0rkle/wiki " +
-			"-- pull requests welcome ;-)")
-	} else if poss := cs.possible(ctx.Text()); len(poss) > 0 {
-		ctx.ReplyN("Commands matching %q: \"%s\".", ctx.Text(),
-			strings.Join(poss, "\", \""))
-	} else {
-		ctx.ReplyN("Unrecognised command '%s'.", ctx.Text())
+	case cs.listCommands(ctx, strings.ToLower(ctx.Text())):
+		// Listed all commands, or those from one driver.
+	default:
+		if poss := cs.possible(ctx.Text()); len(poss) > 0 {
+			ctx.ReplyN("Commands matching %q: \"%s\".", ctx.Text(),
+				strings.Join(poss, "\", \""))
+		} else {
+			ctx.ReplyN("Unrecognised command '%s'.", ctx.Text())
+		}
 	}
 }
 
@@ -1,14 +1,18 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/fluffle/goirc/client"
-	"github.com/fluffle/sp0rkle/collections/conf"
 	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/config"
 )
 
+var timeout = config.Duration("timeout", 30*time.Second,
+	"Default deadline for I/O done by handlers and commands.")
+
 // Basic types representing the information we want to store about IRC things
 type Nick string
 
@@ -23,27 +27,78 @@ func (c Chan) Lower() string {
 }
 
 // context encapsulates the bot's stuff and provides an interface
-// for the rest of the bot to interact with IRC.
+// for the rest of the bot to interact with the chat network.
 type Context struct {
-	*client.Line
+	*Line
 	Addressed bool
+	// Params holds arguments parsed for commands registered with Takes().
+	Params Params
 
-	conn *client.Conn
+	// The prefix of the command being run, if any.
+	cmd  string
+	conn Transport
+	out  Output
 	rws  RewriteSet
+	ctx  context.Context
+
+	// Set by Fail, so that pipelines stop at commands that fail.
+	failed bool
+}
+
+// Output is where a Context sends what the bot says. It's normally the
+// connection's send queue, but tests can capture it instead; see bot/bottest.
+type Output interface {
+	// Send sends text to target. Cmd is PRIVMSG, ACTION or NOTICE.
+	Send(cmd, target, text string)
+	Topic(ch string, topic ...string)
+}
+
+// connOutput sends messages via conn's send queue.
+type connOutput struct {
+	conn Transport
+}
+
+func (o connOutput) Send(cmd, target, text string) {
+	queueFor(o.conn).send(cmd, target, text)
 }
 
-func reqContext(conn *client.Conn, line *client.Line) *Context {
-	ctx := &Context{conn: conn, Line: line.Copy(), rws: bot.rewriters}
+func (o connOutput) Topic(ch string, topic ...string) {
+	o.conn.Topic(ch, topic...)
+}
+
+// NewContext builds a Context for a line received on conn, as handlers
+// and commands would see it, but which sends output to out. It returns
+// nil if the line would be ignored. It's for tests; see bot/bottest.
+func NewContext(conn Transport, line *Line, out Output) *Context {
+	ctx := reqContext(conn, line)
+	if ctx != nil {
+		ctx.out = out
+	}
+	return ctx
+}
+
+func reqContext(conn Transport, line *Line) *Context {
+	var out Output = connOutput{conn}
+	if u, ok := conn.(Unpaced); ok {
+		out = u
+	}
+	ctx := &Context{conn: conn, Line: line.Copy(), out: out,
+		rws: bot.rewriters, ctx: bot.servers.Context(conn)}
 	// This is a bit of a dirty hack; context() returns nil to ignore a line.
-	// TODO(fluffle): Ignores based on masks (or more likely regex).
-	if ctx.Nick != "" && conf.Ns(ignoreNs).String(strings.ToLower(ctx.Nick)) != "" {
+	if ctx.Nick != "" && ignores.Ignored(ctx.Src, ctx.channel(), "") {
 		return nil
 	}
-	if ctx.Cmd != client.PRIVMSG {
+	if ctx.Cmd != PRIVMSG {
 		return ctx
 	}
 	ctx.Args[1], ctx.Addressed = util.RemovePrefixedNick(
 		strings.TrimSpace(ctx.Args[1]), ctx.Me())
+	// Lines starting with a trigger prefix like "!" are addressed too, except
+	// factoid additions, which might legitimately begin with one.
+	if !ctx.Addressed && !util.IsFactoidAddition(ctx.Args[1]) {
+		ctx.Args[1], ctx.Addressed = util.RemoveTrigger(
+			ctx.Args[1], triggers.For(ctx.channel()))
+	}
 	// If we're being talked to in private, line.Args[0] will contain our Nick.
 	// We should consider this as "addressing" us, and set Addressed = true
 	if ctx.Args[0] == ctx.Me() {
@@ -52,6 +107,42 @@ func reqContext(conn *client.Conn, line *client.Line) *Context {
 	return ctx
 }
 
+// Ctx returns a context.Context scoped to handling this line. It is
+// cancelled if the bot disconnects, and has a deadline while a handler
+// or command is running. Pass it to anything doing network I/O or
+// database operations.
+func (ctx *Context) Ctx() context.Context {
+	return ctx.ctx
+}
+
+// withTimeout gives ctx a deadline d from now. Call the returned
+// function when done, to release resources.
+func (ctx *Context) withTimeout(d time.Duration) context.CancelFunc {
+	c, cancel := context.WithTimeout(ctx.ctx, d)
+	ctx.ctx = c
+	return cancel
+}
+
+// Role returns the highest role held by the sender of the line.
+func (ctx *Context) Role() Role {
+	return RoleFor(ctx.Src)
+}
+
+// channel returns the channel a line was seen in, if any.
+func (ctx *Context) channel() string {
+	switch ctx.Cmd {
+	case PRIVMSG, ACTION, NOTICE, JOIN, PART, KICK, TOPIC:
+		if len(ctx.Args) > 0 && isChannel(ctx.Args[0]) {
+			return ctx.Args[0]
+		}
+	}
+	return ""
+}
+
+func isChannel(s string) bool {
+	return len(s) > 0 && strings.IndexByte("#&+!", s[0]) != -1
+}
+
 func (ctx *Context) Storable() (Nick, Chan) {
 	return Nick(ctx.Nick), Chan(ctx.Args[0])
 }
@@ -62,27 +153,37 @@ func (ctx *Context) ReplyN(fm string, args ...interface{}) {
 	ctx.Reply("%s: "+fm, args...)
 }
 
+// Fail replies like ReplyN, saying why a command failed. If the command
+// is in a pipeline, it stops there rather than passing the reply on.
+func (ctx *Context) Fail(fm string, args ...interface{}) {
+	ctx.failed = true
+	ctx.ReplyN(fm, args...)
+}
+
 // whereas Reply() does not.
 func (ctx *Context) Reply(fm string, args ...interface{}) {
-	ctx.conn.Privmsg(ctx.Target(),
-		ctx.rws.Rewrite(fmt.Sprintf(fm, args...), ctx))
+	ctx.Privmsg(ctx.Target(), ctx.rws.Rewrite(fmt.Sprintf(fm, args...), ctx))
 }
 
 func (ctx *Context) Do(fm string, args ...interface{}) {
-	ctx.conn.Action(ctx.Target(),
-		ctx.rws.Rewrite(fmt.Sprintf(fm, args...), ctx))
+	ctx.Action(ctx.Target(), ctx.rws.Rewrite(fmt.Sprintf(fm, args...), ctx))
 }
 
+// Messages are sent via a queue that splits long lines and paces output.
 func (ctx *Context) Privmsg(ch, text string) {
-	ctx.conn.Privmsg(ch, text)
+	ctx.out.Send(PRIVMSG, ch, text)
 }
 
 func (ctx *Context) Action(ch, text string) {
-	ctx.conn.Action(ch, text)
+	ctx.out.Send(ACTION, ch, text)
+}
+
+func (ctx *Context) Notice(ch, text string) {
+	ctx.out.Send(NOTICE, ch, text)
 }
 
 func (ctx *Context) Topic(ch string, topic ...string) {
-	ctx.conn.Topic(ch, topic...)
+	ctx.out.Topic(ch, topic...)
 }
 
 func (ctx *Context) Me() string {
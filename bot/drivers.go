@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fluffle/golog/logging"
+)
+
+var (
+	enableDrivers = flag.String("drivers", "",
+		"Comma-separated list of drivers to load; all of them if empty.")
+	disableDrivers = flag.String("disable_drivers", "",
+		"Comma-separated list of drivers not to load.")
+)
+
+// A Driver is a self-contained feature. Drivers register themselves from
+// an init() function in their package, and are loaded by LoadDrivers.
+type Driver struct {
+	// Name should match the driver's package name, since that is the
+	// name policies and help use for its commands and handlers.
+	Name, Description string
+	// Requires names drivers that must be loaded before this one.
+	Requires []string
+	// Init registers the driver's commands, handlers and so on.
+	Init func()
+	// Shutdown, if non-nil, cleans up when the bot shuts down.
+	Shutdown func()
+}
+
+type driverSet struct {
+	sync.Mutex
+	set map[string]*Driver
+	// Drivers that have been loaded, in the order they were loaded.
+	loaded []*Driver
+}
+
+var registry = &driverSet{set: make(map[string]*Driver)}
+
+// Register makes a driver available to LoadDrivers.
+func Register(d *Driver) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.set[d.Name]; ok {
+		panic("bot: driver " + d.Name + " registered twice")
+	}
+	registry.set[d.Name] = d
+}
+
+// splitNames splits a comma-separated list, dropping empty names.
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// enabled returns the names of the drivers to load, sorted.
+// It must be called with the lock held.
+func (ds *driverSet) enabled(enable, disable []string) ([]string, error) {
+	on := make(map[string]bool)
+	if len(enable) == 0 {
+		for name := range ds.set {
+			on[name] = true
+		}
+	}
+	for _, name := range enable {
+		if ds.set[name] == nil {
+			return nil, fmt.Errorf("can't enable unknown driver %q", name)
+		}
+		on[name] = true
+	}
+	for _, name := range disable {
+		if ds.set[name] == nil {
+			return nil, fmt.Errorf("can't disable unknown driver %q", name)
+		}
+		delete(on, name)
+	}
+	names := make([]string, 0, len(on))
+	for name := range on {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// order sorts the named drivers so each comes after the drivers it
+// requires, and otherwise by name. It must be called with the lock held.
+func (ds *driverSet) order(names []string) ([]*Driver, error) {
+	on := make(map[string]bool, len(names))
+	for _, name := range names {
+		on[name] = true
+	}
+	var out []*Driver
+	done := make(map[string]bool, len(names))
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		if done[name] {
+			return nil
+		}
+		chain = append(chain, name)
+		for _, seen := range chain[:len(chain)-1] {
+			if seen == name {
+				return fmt.Errorf("drivers require each other: %s",
+					strings.Join(chain, " -> "))
+			}
+		}
+		d := ds.set[name]
+		for _, req := range d.Requires {
+			if ds.set[req] == nil {
+				return fmt.Errorf("driver %s requires unknown driver %s", name, req)
+			}
+			if !on[req] {
+				return fmt.Errorf("driver %s requires %s, which is disabled", name, req)
+			}
+			if err := visit(req, chain); err != nil {
+				return err
+			}
+		}
+		done[name] = true
+		out = append(out, d)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// LoadDrivers initialises the registered drivers selected by the
+// --drivers and --disable_drivers flags, dependencies first.
+// Call it once, after Init() and connecting to the databases.
+func LoadDrivers() error {
+	registry.Lock()
+	defer registry.Unlock()
+	names, err := registry.enabled(splitNames(*enableDrivers), splitNames(*disableDrivers))
+	if err != nil {
+		return err
+	}
+	drivers, err := registry.order(names)
+	if err != nil {
+		return err
+	}
+	for _, d := range drivers {
+		logging.Info("Loading driver %s.", d.Name)
+		d.Init()
+		registry.loaded = append(registry.loaded, d)
+	}
+	return nil
+}
+
+// UnloadDrivers calls the Shutdown hooks of loaded drivers, in the
+// reverse of the order they were loaded.
+func UnloadDrivers() {
+	registry.Lock()
+	defer registry.Unlock()
+	for i := len(registry.loaded) - 1; i >= 0; i-- {
+		if d := registry.loaded[i]; d.Shutdown != nil {
+			logging.Info("Shutting down driver %s.", d.Name)
+			d.Shutdown()
+		}
+	}
+	registry.loaded = nil
+}
+
+// list returns "name: description" for every registered driver, sorted,
+// noting those that aren't loaded.
+func (ds *driverSet) list() []string {
+	ds.Lock()
+	defer ds.Unlock()
+	loaded := make(map[string]bool, len(ds.loaded))
+	for _, d := range ds.loaded {
+		loaded[d.Name] = true
+	}
+	l := make([]string, 0, len(ds.set))
+	for name, d := range ds.set {
+		s := name + ": " + d.Description
+		if !loaded[name] {
+			s += " (not loaded)"
+		}
+		l = append(l, s)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// describe returns the description of the named driver.
+func (ds *driverSet) describe(name string) string {
+	ds.Lock()
+	defer ds.Unlock()
+	if d, ok := ds.set[name]; ok {
+		return d.Description
+	}
+	return ""
+}
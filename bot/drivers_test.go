@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/golog/logging"
+)
+
+func TestLoadDrivers(t *testing.T) {
+	logging.InitFromFlags()
+	var log []string
+	driver := func(name string, requires ...string) *Driver {
+		return &Driver{
+			Name:     name,
+			Requires: requires,
+			Init:     func() { log = append(log, "init "+name) },
+			Shutdown: func() { log = append(log, "shutdown "+name) },
+		}
+	}
+	tests := []struct {
+		drivers         []*Driver
+		enable, disable string
+		exp             []string
+		err             bool
+	}{
+		{[]*Driver{driver("b"), driver("a")}, "", "",
+			[]string{"init a", "init b", "shutdown b", "shutdown a"}, false},
+		{[]*Driver{driver("a", "c"), driver("b"), driver("c", "b")}, "", "",
+			[]string{"init b", "init c", "init a",
+				"shutdown a", "shutdown c", "shutdown b"}, false},
+		{[]*Driver{driver("a"), driver("b"), driver("c")}, "c, a", "",
+			[]string{"init a", "init c", "shutdown c", "shutdown a"}, false},
+		{[]*Driver{driver("a"), driver("b"), driver("c")}, "", "b",
+			[]string{"init a", "init c", "shutdown c", "shutdown a"}, false},
+		{[]*Driver{driver("a"), {Name: "b", Init: func() {}}}, "", "",
+			[]string{"init a", "shutdown a"}, false},
+		{[]*Driver{driver("a")}, "x", "", nil, true},
+		{[]*Driver{driver("a")}, "", "x", nil, true},
+		{[]*Driver{driver("a", "b"), driver("b")}, "", "b", nil, true},
+		{[]*Driver{driver("a", "x")}, "", "", nil, true},
+		{[]*Driver{driver("a", "b"), driver("b", "a")}, "", "", nil, true},
+	}
+	defer func(r *driverSet, e, d string) {
+		registry, *enableDrivers, *disableDrivers = r, e, d
+	}(registry, *enableDrivers, *disableDrivers)
+	for i, test := range tests {
+		registry = &driverSet{set: make(map[string]*Driver)}
+		for _, d := range test.drivers {
+			Register(d)
+		}
+		*enableDrivers, *disableDrivers = test.enable, test.disable
+		log = nil
+		err := LoadDrivers()
+		if (err != nil) != test.err {
+			t.Errorf("LoadDrivers(%d): unexpected error state %v", i, err)
+			continue
+		}
+		UnloadDrivers()
+		if !reflect.DeepEqual(log, test.exp) {
+			t.Errorf("LoadDrivers(%d): exp %q got %q", i, test.exp, log)
+		}
+	}
+}
+
+func TestListDrivers(t *testing.T) {
+	ds := &driverSet{set: map[string]*Driver{
+		"b": {Name: "b", Description: "Does b."},
+		"a": {Name: "a", Description: "Does a."},
+	}}
+	ds.loaded = []*Driver{ds.set["b"]}
+	exp := []string{"a: Does a. (not loaded)", "b: Does b."}
+	if l := ds.list(); !reflect.DeepEqual(l, exp) {
+		t.Errorf("list: exp %q got %q", exp, l)
+	}
+	if d := ds.describe("a"); d != "Does a." {
+		t.Errorf("describe: got %q", d)
+	}
+}
@@ -8,55 +8,44 @@ import (
 
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/db"
+	"github.com/fluffle/sp0rkle/util/config"
 )
 
 var (
-	channels *string = flag.String("channels", "#sp0rklf",
+	channels = config.String("channels", "#sp0rklf",
 		"Comma-separated list of channels to join.")
 	rebuilder *string = flag.String("rebuilder", "",
-		"Nick[:password] to accept rebuild command from.")
+		"Deprecated, use --owner. Nick:password to accept rebuild, shutdown "+
+			"and migrate from, or $ENV_VAR or <file_path to secret.")
 	oper *string = flag.String("oper", "",
 		"user:password for server OPER command on connect, or $ENV_VAR or <file_path to secret.")
 	vhost *string = flag.String("vhost", "",
 		"user:password for server VHOST command on connect, or $ENV_VAR or <file_path to secret.")
 )
 
+// IRC-specific things happen on connecting too; see irctransport.go.
 func connected(ctx *Context) {
-	// Set bot mode to keep people informed.
-	ctx.conn.Mode(ctx.Me(), "+B")
-	if GetSecret(*oper) != "" {
-		up := strings.SplitN(*oper, ":", 2)
-		if len(up) == 2 {
-			ctx.conn.Oper(up[0], up[1])
-		}
-	}
-	if GetSecret(*vhost) != "" {
-		up := strings.SplitN(*vhost, ":", 2)
-		if len(up) == 2 {
-			ctx.conn.VHost(up[0], up[1])
-		}
-	}
-	for _, c := range strings.Split(*channels, ",") {
+	for _, c := range channelsFor(ctx.conn) {
 		logging.Info("Joining %s on startup.\n", c)
 		ctx.conn.Join(c)
 	}
 }
 
 func rebuild(ctx *Context) {
-	if !check_rebuilder("rebuild", ctx) {
+	if !checkOwner("rebuild", ctx) {
 		return
 	}
 
 	// Ok, we should be good to rebuild now.
 	logging.Info("Beginning rebuild")
-	ctx.conn.Notice(ctx.Nick, "Beginning rebuild")
+	ctx.Notice(ctx.Nick, "Beginning rebuild")
 	cmd := exec.Command("go", "get", "-u", "github.com/fluffle/sp0rkle")
 	out, err := cmd.CombinedOutput()
 	logging.Info("Output from go get:\n%s", out)
 	if err != nil {
-		ctx.conn.Notice(ctx.Nick, fmt.Sprintf("Rebuild failed: %s", err))
+		ctx.Notice(ctx.Nick, fmt.Sprintf("Rebuild failed: %s", err))
 		for _, l := range strings.Split(string(out), "\n") {
-			ctx.conn.Notice(ctx.Nick, l)
+			ctx.Notice(ctx.Nick, l)
 		}
 		return
 	}
@@ -64,20 +53,26 @@ func rebuild(ctx *Context) {
 }
 
 func shutdown(ctx *Context) {
-	if check_rebuilder("shutdown", ctx) {
+	if checkOwner("shutdown", ctx) {
 		bot.servers.Shutdown(false)
 	}
 }
 
 func migrate(ctx *Context) {
-	if !check_rebuilder("migrate", ctx) {
+	if !checkOwner("migrate", ctx) {
+		return
+	}
+	fields := strings.Fields(ctx.Text())
+	if len(fields) < 2 {
+		ctx.ReplyN("Usage: migrate <state>")
 		return
 	}
-	newState := db.StateForName(strings.Fields(ctx.Text())[1])
+	newState := db.StateForName(fields[1])
 	if !newState.Valid() {
 		ctx.ReplyN("unrecognised migration state: %q", ctx.Text())
 		return
 	}
+	ctx.Audit("migrations", newState.String(), "")
 	if err := db.MigrateTo(newState); err != nil {
 		ctx.ReplyN("migrate failed: %v", err)
 		return
@@ -85,14 +80,28 @@ func migrate(ctx *Context) {
 	ctx.ReplyN("Migrated!")
 }
 
-func check_rebuilder(cmd string, ctx *Context) bool {
-	s := strings.Split(GetSecret(*rebuilder), ":")
-	if s[0] == "" || s[0] != ctx.Nick || !strings.HasPrefix(ctx.Text(), cmd) {
+// NOTICE-driven commands are only accepted from the bot's owners, or
+// from the --rebuilder nick with its password.
+func checkOwner(cmd string, ctx *Context) bool {
+	if !strings.HasPrefix(ctx.Text(), cmd) {
 		return false
 	}
-	fields := strings.Fields(ctx.Text())
-	if len(s) > 1 && fields[len(fields)-1] != s[1] {
+	return ctx.Role() == Owner || checkRebuilder(ctx)
+}
+
+// checkRebuilder checks for the password set with --rebuilder as the last
+// word of ctx's text. Unlike owners, the rebuilder is only known by nick,
+// so without a password it isn't trusted at all.
+func checkRebuilder(ctx *Context) bool {
+	nick, pass, _ := strings.Cut(GetSecret(*rebuilder), ":")
+	if nick == "" || nick != ctx.Nick {
 		return false
 	}
-	return true
+	if pass == "" {
+		logging.Warn("Ignoring '%s' from --rebuilder %s, which has no "+
+			"password.", ctx.Text(), nick)
+		return false
+	}
+	fields := strings.Fields(ctx.Text())
+	return fields[len(fields)-1] == pass
 }
@@ -0,0 +1,247 @@
+package bot
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fluffle/golog/logging"
+)
+
+// Commands registered by package bot itself are grouped under this name.
+const coreDriver = "bot"
+
+// helpPath is where the HTML help page is served.
+const helpPath = "/help"
+
+// At most this many "did you mean" suggestions are offered.
+const maxSuggestions = 3
+
+// driverOf returns the name of the driver that registered r.
+func driverOf(r Runner) string {
+	if c, ok := r.(*command); ok && c.names != nil {
+		return c.names[0]
+	}
+	return coreDriver
+}
+
+type helpCommand struct {
+	Prefix, Help string
+}
+
+type helpDriver struct {
+	Name, Description string
+	Commands          []helpCommand
+}
+
+// drivers returns every command, grouped by driver and sorted by name.
+func (cs *commandSet) drivers() []helpDriver {
+	cs.RLock()
+	byName := make(map[string][]helpCommand)
+	for prefix, r := range cs.set {
+		d := driverOf(r)
+		byName[d] = append(byName[d], helpCommand{prefix, r.Help()})
+	}
+	cs.RUnlock()
+	drivers := make([]helpDriver, 0, len(byName))
+	for name, cmds := range byName {
+		sort.Slice(cmds, func(i, j int) bool { return cmds[i].Prefix < cmds[j].Prefix })
+		drivers = append(drivers, helpDriver{name, registry.describe(name), cmds})
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i].Name < drivers[j].Name })
+	return drivers
+}
+
+// listCommands replies with the commands from driver, or from all drivers
+// if it is empty. It returns false if there is no such driver.
+func (cs *commandSet) listCommands(ctx *Context, driver string) bool {
+	var lines []string
+	for _, d := range cs.drivers() {
+		prefixes := make([]string, len(d.Commands))
+		for i, c := range d.Commands {
+			prefixes[i] = c.Prefix
+		}
+		if d.Name == driver {
+			ctx.ReplyPagedList(fmt.Sprintf("Commands from %s: ", d.Name), prefixes, ", ")
+			return true
+		}
+		lines = append(lines, d.Name+": "+strings.Join(prefixes, ", "))
+	}
+	if driver != "" {
+		return false
+	}
+	ctx.ReplyPaged(fmt.Sprintf("Commands by driver; 'help <command>' explains "+
+		"one, and %s%s describes them all:", *httpHost, helpPath), lines)
+	return true
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// closeEnough returns how many edits a typo of name may have. Short
+// names are one edit away from too many words people say to the bot,
+// e.g. "nice" from "nick", so typos of them aren't suggested at all.
+func closeEnough(name string) int {
+	switch n := len(name); {
+	case n < 5:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// similar returns the names that txt starts with a likely typo of,
+// closest first.
+func similar(txt string, names []string) []string {
+	words := strings.Fields(strings.ToLower(txt))
+	type match struct {
+		name string
+		dist int
+	}
+	var matches []match
+	for _, name := range names {
+		n := len(strings.Fields(name))
+		if n == 0 || n > len(words) {
+			continue
+		}
+		d := editDistance(strings.Join(words[:n], " "), name)
+		if d > 0 && d <= closeEnough(name) {
+			matches = append(matches, match{name, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.name
+	}
+	return out
+}
+
+// names returns all command prefixes and alias names.
+func (cs *commandSet) names() []string {
+	cs.RLock()
+	names := make([]string, 0, len(cs.set))
+	for prefix := range cs.set {
+		names = append(names, prefix)
+	}
+	cs.RUnlock()
+	for name := range aliases.snapshot() {
+		names = append(names, name)
+	}
+	return names
+}
+
+// suggest replies with commands ctx's text might be a typo of, returning
+// false if it doesn't look like one or is a command already.
+func (cs *commandSet) suggest(ctx *Context) bool {
+	txt, err := aliases.resolve(ctx.Text())
+	if err != nil {
+		return false
+	}
+	if r, _ := cs.match(txt); r != nil {
+		return false
+	}
+	sims := similar(txt, cs.names())
+	if len(sims) == 0 {
+		return false
+	}
+	if len(sims) > maxSuggestions {
+		sims = sims[:maxSuggestions]
+	}
+	ctx.ReplyN("Did you mean '%s'?", strings.Join(sims, "', '"))
+	return true
+}
+
+// Suggest offers "did you mean" suggestions if ctx is addressed and looks
+// like a mistyped command. Drivers that handle addressed lines which match
+// no command, like factoid lookups, call it when they have nothing to say.
+// It returns true if it replied.
+func Suggest(ctx *Context) bool {
+	if !ctx.Addressed {
+		return false
+	}
+	return bot.commands.(*commandSet).suggest(ctx)
+}
+
+type helpPage struct {
+	Drivers []helpDriver
+	Aliases []string
+}
+
+// HelpHandler serves a page describing every registered command.
+func HelpHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		p := &helpPage{
+			Drivers: bot.commands.(*commandSet).drivers(),
+			Aliases: aliases.list(),
+		}
+		if err := helpTmpl.Execute(rw, p); err != nil {
+			logging.Error("Rendering help page: %v", err)
+		}
+	})
+}
+
+var helpTmpl = template.Must(template.New("help").Parse(`<html>
+<head>
+  <title>sp0rkle help</title>
+</head>
+<body>
+  <h1>sp0rkle help</h1>
+  <p>Address commands to sp0rkle by nick, e.g. "sp0rkle: help", or in
+  private. Arguments in &lt;angle brackets&gt; are required and those in
+  [square brackets] are optional.</p>
+  <p>Commands that reply with a value, like calc, can be joined with " | "
+  to pass what they say to the next, e.g. "calc 2**20 | base 10to16".</p>
+  <ul>
+{{ range .Drivers }}
+    <li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{ end }}
+  </ul>
+{{ range .Drivers }}
+  <h2 id="{{.Name}}">{{.Name}}</h2>
+{{ if .Description }}
+  <p>{{.Description}}</p>
+{{ end }}
+  <dl>
+{{ range .Commands }}
+    <dt><b>{{.Prefix}}</b></dt><dd>{{.Help}}</dd>
+{{ end }}
+  </dl>
+{{ end }}
+{{ if .Aliases }}
+  <h2 id="aliases">Aliases</h2>
+  <ul>
+{{ range .Aliases }}
+    <li>{{.}}</li>
+{{ end }}
+  </ul>
+{{ end }}
+</body>
+</html>`))
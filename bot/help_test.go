@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"calc", "calc", 0},
+		{"calc", "", 4},
+		{"calx", "calc", 1},
+		{"clac", "calc", 2},
+		{"remind", "remnid", 2},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, test := range tests {
+		if d := editDistance(test.a, test.b); d != test.d {
+			t.Errorf("editDistance(%q, %q): exp %d got %d", test.a, test.b, test.d, d)
+		}
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	names := []string{"calc", "chr", "ord", "remind", "remind list",
+		"quote", "qadd", "url find", "help", "tz", "say", "nick"}
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"calc 1 + 2", []string{}},
+		{"remnd me", []string{"remind"}},
+		{"remidn me", []string{}},
+		{"remind lsit", []string{"remind list"}},
+		{"qute foo", []string{"quote"}},
+		{"qoute foo", []string{}},
+		{"url fnd x", []string{"url find"}},
+		// Short names must match exactly.
+		{"calx 1 + 2", []string{}},
+		{"chx 65", []string{}},
+		{"ts utc", []string{}},
+		{"sat down", []string{}},
+		{"nice", []string{}},
+		{"hello there", []string{}},
+		{"", []string{}},
+	}
+	for i, test := range tests {
+		if out := similar(test.in, names); !reflect.DeepEqual(out, test.out) {
+			t.Errorf("similar(%d) %q: exp %q got %q", i, test.in, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/util"
+)
+
+// Conf namespace for ignore rules. Rules are stored JSON-encoded under
+// a key derived from the rule. Older entries map a lowercase nick to
+// the string "ignore", and are treated as a nick!*@* mask.
+const ignoreNs = "ignore"
+
+// An ignoreRule matches nick!ident@host sources against either a mask
+// or a regex (written /like this/), optionally only in a channel or
+// only for some commands, until an optional expiry time.
+type ignoreRule struct {
+	Pattern string
+	Chan    string    `json:",omitempty"`
+	Cmds    []string  `json:",omitempty"`
+	Expires time.Time `json:",omitempty"`
+	By      string    `json:",omitempty"`
+
+	rx *regexp.Regexp
+}
+
+func newIgnoreRule(pattern string) (*ignoreRule, error) {
+	ir := &ignoreRule{Pattern: pattern}
+	if err := ir.compile(); err != nil {
+		return nil, err
+	}
+	return ir, nil
+}
+
+func (ir *ignoreRule) compile() (err error) {
+	if isRxPattern(ir.Pattern) {
+		ir.rx, err = regexp.Compile("(?i)" + ir.Pattern[1:len(ir.Pattern)-1])
+	} else {
+		ir.Pattern = NormalizeMask(ir.Pattern)
+		ir.rx, err = maskRx(ir.Pattern)
+	}
+	return err
+}
+
+func isRxPattern(p string) bool {
+	return len(p) > 2 && p[0] == '/' && p[len(p)-1] == '/'
+}
+
+// key is unique per pattern and scope, so e.g. a nick can be
+// ignored in one channel for a while and in another forever.
+func (ir *ignoreRule) key() string {
+	key := strings.ToLower(ir.Pattern)
+	if ir.Chan != "" {
+		key += " in " + strings.ToLower(ir.Chan)
+	}
+	if len(ir.Cmds) > 0 {
+		key += " commands " + strings.Join(ir.Cmds, ", ")
+	}
+	return key
+}
+
+func (ir *ignoreRule) expired(now time.Time) bool {
+	return !ir.Expires.IsZero() && now.After(ir.Expires)
+}
+
+// match checks src against the rule. If cmd is empty, only rules that
+// apply to every line match; if it is not, only command rules do.
+func (ir *ignoreRule) match(src, ch, cmd string) bool {
+	if ir.Chan != "" && !strings.EqualFold(ir.Chan, ch) {
+		return false
+	}
+	if (cmd == "") != (len(ir.Cmds) == 0) {
+		return false
+	}
+	if cmd != "" {
+		found := false
+		for _, c := range ir.Cmds {
+			if strings.EqualFold(c, cmd) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return ir.rx.MatchString(src)
+}
+
+func (ir *ignoreRule) String() string {
+	s := ir.key()
+	if !ir.Expires.IsZero() {
+		s += fmt.Sprintf(" for %s", util.TimeSince(
+			time.Now().Add(-time.Until(ir.Expires))))
+	}
+	return s
+}
+
+// ignoreSet caches compiled rules in memory, because they're
+// checked against every line the bot sees.
+type ignoreSet struct {
+	sync.RWMutex
+	loaded bool
+	rules  map[string]*ignoreRule
+}
+
+var ignores = &ignoreSet{}
+
+// load must be called with the write lock held.
+func (is *ignoreSet) load() {
+	if is.loaded {
+		return
+	}
+	is.rules = make(map[string]*ignoreRule)
+	now := time.Now()
+	ns := conf.Ns(ignoreNs)
+	for _, e := range ns.All() {
+		val, _ := e.Value.(string)
+		ir := &ignoreRule{}
+		if val == "ignore" {
+			ir.Pattern = e.Key
+		} else if err := json.Unmarshal([]byte(val), ir); err != nil {
+			logging.Error("Bad ignore rule %q: %v", e.Key, err)
+			continue
+		}
+		if err := ir.compile(); err != nil {
+			logging.Error("Bad ignore rule %q: %v", e.Key, err)
+			continue
+		}
+		if ir.expired(now) {
+			ns.Delete(e.Key)
+			continue
+		}
+		is.rules[e.Key] = ir
+	}
+	is.loaded = true
+}
+
+func (is *ignoreSet) add(ir *ignoreRule) error {
+	data, err := json.Marshal(ir)
+	if err != nil {
+		return err
+	}
+	is.Lock()
+	defer is.Unlock()
+	is.load()
+	key := ir.key()
+	conf.Ns(ignoreNs).String(key, string(data))
+	is.rules[key] = ir
+	return nil
+}
+
+// remove deletes all rules for pattern, returning them.
+func (is *ignoreSet) remove(pattern string) []*ignoreRule {
+	if !isRxPattern(pattern) {
+		pattern = NormalizeMask(pattern)
+	}
+	is.Lock()
+	defer is.Unlock()
+	is.load()
+	var removed []*ignoreRule
+	for key, ir := range is.rules {
+		if strings.EqualFold(ir.Pattern, pattern) {
+			conf.Ns(ignoreNs).Delete(key)
+			delete(is.rules, key)
+			removed = append(removed, ir)
+		}
+	}
+	return removed
+}
+
+// active returns unexpired rules, pruning expired ones as it goes.
+func (is *ignoreSet) active() []*ignoreRule {
+	is.Lock()
+	defer is.Unlock()
+	is.load()
+	now := time.Now()
+	rules := make([]*ignoreRule, 0, len(is.rules))
+	for key, ir := range is.rules {
+		if ir.expired(now) {
+			conf.Ns(ignoreNs).Delete(key)
+			delete(is.rules, key)
+			continue
+		}
+		rules = append(rules, ir)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].key() < rules[j].key() })
+	return rules
+}
+
+// Ignored returns true if lines from src in ch should be ignored. If cmd
+// is non-empty, only rules scoped to that command are considered.
+func (is *ignoreSet) Ignored(src, ch, cmd string) bool {
+	is.RLock()
+	if !is.loaded {
+		is.RUnlock()
+		is.Lock()
+		is.load()
+		is.Unlock()
+		is.RLock()
+	}
+	defer is.RUnlock()
+	now := time.Now()
+	for _, ir := range is.rules {
+		if !ir.expired(now) && ir.match(src, ch, cmd) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIgnore(t *testing.T) {
+	tests := []struct {
+		in   string
+		key  string
+		cmds []string
+		exp  bool
+		err  bool
+	}{
+		{"", "", nil, false, true},
+		{"bob", "bob!*@*", nil, false, false},
+		{"*!*@spam.example for 2h", "*!*@spam.example", nil, true, false},
+		{"bob in #chan", "bob!*@* in #chan", nil, false, false},
+		{"bob in chan", "", nil, false, true},
+		{"bob for ages", "", nil, false, true},
+		{"/^b.b!/ commands quote, insult", "/^b.b!/ commands insult, quote",
+			[]string{"insult", "quote"}, false, false},
+		{"/a b/ in #x for 1d", "/a b/ in #x", nil, true, false},
+		{"/a(b/", "", nil, false, true},
+		{"bob with cheese", "", nil, false, true},
+	}
+	for i, test := range tests {
+		ir, err := parseIgnore(test.in)
+		if (err != nil) != test.err {
+			t.Errorf("parseIgnore(%d) %q: unexpected error state %v", i, test.in, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if ir.key() != test.key || !reflect.DeepEqual(ir.Cmds, test.cmds) ||
+			ir.Expires.IsZero() == test.exp {
+			t.Errorf("parseIgnore(%d) %q: exp %q %v %t got %q %v %t", i, test.in,
+				test.key, test.cmds, test.exp, ir.key(), ir.Cmds, !ir.Expires.IsZero())
+		}
+	}
+}
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	ir, _ := parseIgnore("*!*@spam.example in #chan commands quote")
+	tests := []struct {
+		src, ch, cmd string
+		match        bool
+	}{
+		{"bob!b@spam.example", "#chan", "quote", true},
+		{"bob!b@spam.example", "#CHAN", "quote", true},
+		{"bob!b@spam.example", "#chan", "Quote", true},
+		{"bob!b@spam.example", "#other", "quote", false},
+		{"bob!b@spam.example", "#chan", "", false},
+		{"bob!b@spam.example", "#chan", "insult", false},
+		{"bob!b@ham.example", "#chan", "quote", false},
+	}
+	for i, test := range tests {
+		if m := ir.match(test.src, test.ch, test.cmd); m != test.match {
+			t.Errorf("match(%d) %q %q %q: exp %t got %t",
+				i, test.src, test.ch, test.cmd, test.match, m)
+		}
+	}
+}
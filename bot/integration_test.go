@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot/bottest/ircd"
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/collections/schedules"
+)
+
+type testPoller struct {
+	started, stopped chan bool
+}
+
+func (p *testPoller) Poll([]*Context) error { return nil }
+func (p *testPoller) Start()                { p.started <- true }
+func (p *testPoller) Stop()                 { p.stopped <- true }
+func (p *testPoller) Tick() time.Duration   { return time.Hour }
+
+func wait(t *testing.T, c chan bool, what string) bool {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(ircd.Timeout):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+	return false
+}
+
+// TestIntegration runs the whole bot against an in-process IRC server.
+func TestIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	if bot != nil {
+		// e.g. with -count > 1, since the bot can only be initialised once.
+		t.Skip("bot already initialised")
+	}
+	s, err := ircd.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	logging.InitFromFlags()
+	conf.UseInMem()
+	schedules.UseInMem()
+	flag.Set("servers", s.Addr())
+	flag.Set("nick", "sp0rkle")
+	flag.Set("channels", "#test")
+	flag.Set("pause", "50ms")
+	Init(context.Background())
+	p := &testPoller{make(chan bool, 1), make(chan bool, 1)}
+	Poll("test", p)
+	Command(func(ctx *Context) { ctx.ReplyN("pong") }, "ping", "ping  -- pong.")
+	Command(func(ctx *Context) {
+		ctx.ReplyN("%s %s %s", ctx.Time.UTC().Format(time.RFC3339Nano),
+			ctx.Account, ctx.Tags["+example"])
+	}, "tags", "tags  -- show the line's time, account and tags.")
+
+	alice, err := s.Connect("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+	alice.Join("#test")
+	expect := func(substr string) {
+		t.Helper()
+		if _, err := alice.Expect(substr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := Connect()
+	expect(":sp0rkle!boing@localhost JOIN #test")
+	wait(t, p.started, "poller to start")
+
+	alice.Privmsg("#test", "sp0rkle: ping")
+	expect(":sp0rkle!boing@localhost PRIVMSG #test :alice: pong")
+	alice.Privmsg("sp0rkle", "ping")
+	expect(":sp0rkle!boing@localhost PRIVMSG alice :alice: pong")
+
+	// The bot negotiated IRCv3 capabilities, so sees the server's time
+	// for lines, as if delayed by a bouncer, and alice's account.
+	s.Login("alice", "alice_acct")
+	sent := time.Now().Add(-replayAge / 2).UTC().Truncate(time.Millisecond)
+	s.SetTime(sent)
+	alice.Send("@+example=yes PRIVMSG #test :sp0rkle: tags")
+	expect(":sp0rkle!boing@localhost PRIVMSG #test :alice: " +
+		sent.Format(time.RFC3339Nano) + " alice_acct yes")
+
+	// Lines from well before the bot connected were replayed, so
+	// the commands in them were dealt with the first time around.
+	s.SetTime(time.Date(2020, 2, 29, 12, 34, 56, 789e6, time.UTC))
+	alice.Privmsg("#test", "sp0rkle: tags")
+	// The server has relayed the PRIVMSG once it answers the PING.
+	alice.Send("PING :replayed")
+	expect("PONG")
+	s.SetTime(time.Time{})
+	alice.Privmsg("#test", "sp0rkle: ping")
+	if l, err := alice.Expect("PRIVMSG #test :alice: "); err != nil || !strings.HasSuffix(l, "pong") {
+		t.Errorf("replayed command: exp pong got %q, %v", l, err)
+	}
+
+	// The bot should notice a dropped connection and come back.
+	s.Disconnect("sp0rkle")
+	expect(":sp0rkle!boing@localhost QUIT")
+	wait(t, p.stopped, "poller to stop")
+	expect(":sp0rkle!boing@localhost JOIN #test")
+	wait(t, p.started, "poller to restart")
+
+	go Shutdown()
+	expect(":sp0rkle!boing@localhost QUIT :Quit: Shutting down.")
+	if rebuild := wait(t, done, "shutdown"); rebuild {
+		t.Errorf("Shutdown: expected no rebuild")
+	}
+	wait(t, p.stopped, "poller to stop after shutdown")
+}
@@ -0,0 +1,428 @@
+package bot
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+// IRC lines are at most 512 bytes, including the trailing CRLF.
+const maxLineLen = 512
+
+// Servers prefix lines they relay with our nick!ident@host. Until we know
+// our ident and host, assume they're as long as they reasonably can be.
+const maxIdentHost = 10 + 1 + 63
+
+// IRCv3 capabilities requested from servers that offer them. server-time
+// tells us when lines were sent, which matters when a bouncer replays
+// them long after; the others tell us nicks' services accounts and pass
+// on clients' message tags.
+var wantCaps = []string{"server-time", "account-tag", "account-notify",
+	"extended-join", "message-tags"}
+
+// How long to wait for capability negotiation before giving up on it.
+var capTimeout = 10 * time.Second
+
+// Lines sent this long before we connected were replayed, e.g. from a
+// bouncer's backlog.
+const replayAge = time.Minute
+
+// ircTransport connects to an IRC server with goirc.
+type ircTransport struct {
+	conn *client.Conn
+
+	mu sync.Mutex
+	// Capabilities the server offered and enabled on this connection.
+	offered, caps map[string]bool
+	// The bot's CONNECTED handlers are run once the server has both
+	// welcomed us and finished negotiating capabilities, so everything
+	// the bot does on connecting is done with them enabled.
+	welcomed, negotiated bool
+	onConnect            []bgHandler
+	// Incremented for each connection, so that a negotiation timeout
+	// from an earlier one is ignored.
+	gen int
+	// Services accounts of nicks, by lowercase nick, for servers that
+	// tell us them with extended-join and account-notify, and the nick
+	// and account of whoever quit last.
+	accounts map[string]string
+	quit     struct{ nick, account string }
+
+	// When the current connection started registering.
+	since time.Time
+}
+
+func newIRCTransport(sc *ServerConfig) Transport {
+	t := &ircTransport{conn: client.Client(sc.clientConfig())}
+	t.reset()
+	t.conn.HandleFunc(client.REGISTER, t.register)
+	t.conn.HandleFunc(client.CAP, t.capability)
+	t.conn.HandleFunc("410", t.capFailed)
+	t.conn.HandleFunc("421", t.capFailed)
+	t.conn.HandleFunc(client.CONNECTED, t.connected)
+	t.conn.HandleFunc(client.DISCONNECTED, t.disconnected)
+	for _, cmd := range []string{client.JOIN, "ACCOUNT", client.NICK, client.QUIT} {
+		t.conn.HandleFunc(cmd, t.track)
+	}
+	return t
+}
+
+// reset forgets everything learned on the last connection, returning the
+// new connection's generation. It must be called with t.mu held.
+func (t *ircTransport) reset() int {
+	t.gen++
+	t.offered, t.caps = make(map[string]bool), make(map[string]bool)
+	t.welcomed, t.negotiated = false, false
+	t.accounts = make(map[string]string)
+	t.since = time.Now()
+	return t.gen
+}
+
+// register starts capability negotiation. Goirc has already sent NICK
+// and USER by now, so the server may welcome us before negotiation is
+// done, but either way CONNECTED waits for both.
+func (t *ircTransport) register(conn *client.Conn, line *client.Line) {
+	t.mu.Lock()
+	gen := t.reset()
+	t.mu.Unlock()
+	conn.Raw(client.CAP + " LS 302")
+	time.AfterFunc(capTimeout, func() { t.settle(gen, true) })
+}
+
+// capability handles the server's replies to CAP LS and CAP REQ, which
+// look like ":server CAP <nick> <subcommand> [*] :<caps>".
+func (t *ircTransport) capability(conn *client.Conn, line *client.Line) {
+	if len(line.Args) < 3 {
+		return
+	}
+	caps := strings.Fields(line.Text())
+	var req []string
+	end := false
+	t.mu.Lock()
+	gen := t.gen
+	switch strings.ToUpper(line.Args[1]) {
+	case "LS":
+		for _, c := range caps {
+			// With CAP LS 302, capabilities may have values, e.g. sasl=PLAIN.
+			t.offered[strings.SplitN(c, "=", 2)[0]] = true
+		}
+		if len(line.Args) > 3 && line.Args[2] == "*" {
+			// More capabilities are on their way.
+			break
+		}
+		for _, c := range wantCaps {
+			if t.offered[c] {
+				req = append(req, c)
+			}
+		}
+		end = len(req) == 0
+	case "ACK":
+		for _, c := range caps {
+			if strings.HasPrefix(c, "-") {
+				delete(t.caps, c[1:])
+			} else {
+				t.caps[c] = true
+			}
+		}
+		end = true
+	case "NAK":
+		logging.Warn("%s refused capabilities %q.", t.Name(), caps)
+		end = true
+	}
+	t.mu.Unlock()
+	if len(req) > 0 {
+		conn.Cap("REQ", req...)
+	}
+	if end {
+		t.settle(gen, true)
+	}
+}
+
+// capFailed gives up on negotiation if the server doesn't understand
+// CAP (421) or our use of it (410).
+func (t *ircTransport) capFailed(conn *client.Conn, line *client.Line) {
+	if len(line.Args) < 2 {
+		return
+	}
+	if line.Cmd == "421" && !strings.EqualFold(line.Args[1], client.CAP) {
+		return
+	}
+	t.mu.Lock()
+	gen := t.gen
+	t.mu.Unlock()
+	t.settle(gen, line.Cmd == "410")
+}
+
+// settle finishes capability negotiation on connection gen, unless it
+// is already finished, sending CAP END if end is set.
+func (t *ircTransport) settle(gen int, end bool) {
+	t.mu.Lock()
+	if gen != t.gen || t.negotiated {
+		t.mu.Unlock()
+		return
+	}
+	t.negotiated = true
+	ready := t.welcomed
+	t.mu.Unlock()
+	if end {
+		t.conn.Cap("END")
+	}
+	if ready {
+		t.connect()
+	}
+}
+
+// connected does the IRC-specific things needed on connecting.
+func (t *ircTransport) connected(conn *client.Conn, line *client.Line) {
+	// Set bot mode to keep people informed.
+	conn.Mode(conn.Me().Nick, "+B")
+	sc := bot.servers.Config(t)
+	if user, pass, ok := userPass(sc.Oper); ok {
+		conn.Oper(user, pass)
+	}
+	if user, pass, ok := userPass(sc.VHost); ok {
+		conn.VHost(user, pass)
+	}
+	t.mu.Lock()
+	t.welcomed = true
+	ready := t.negotiated
+	t.mu.Unlock()
+	if ready {
+		t.connect()
+	}
+}
+
+// connect runs the bot's CONNECTED handlers.
+func (t *ircTransport) connect() {
+	t.mu.Lock()
+	hs := t.onConnect
+	t.mu.Unlock()
+	line := &Line{Cmd: CONNECTED, Time: time.Now()}
+	for _, h := range hs {
+		h.run(t, line)
+	}
+}
+
+func (t *ircTransport) disconnected(conn *client.Conn, line *client.Line) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+}
+
+// track follows nicks' services accounts. With extended-join, JOINs look
+// like ":nick!ident@host JOIN #chan <account> :<realname>", and with
+// account-notify, ":nick!ident@host ACCOUNT <account>" says nick logged
+// in or out. An account of "*" means not logged in.
+//
+// Goirc runs all the handlers for a line at once, so the bot's handlers
+// may see a line before or after track does; account copes with both.
+func (t *ircTransport) track(conn *client.Conn, line *client.Line) {
+	nick := strings.ToLower(line.Nick)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch line.Cmd {
+	case client.JOIN:
+		if len(line.Args) == 3 {
+			t.setAccount(nick, line.Args[1])
+		}
+	case "ACCOUNT":
+		if len(line.Args) == 1 {
+			t.setAccount(nick, line.Args[0])
+		}
+	case client.NICK:
+		t.setAccount(strings.ToLower(line.Text()), t.accounts[nick])
+		delete(t.accounts, nick)
+	case client.QUIT:
+		t.quit.nick, t.quit.account = nick, t.accounts[nick]
+		delete(t.accounts, nick)
+	}
+}
+
+// setAccount must be called with t.mu held.
+func (t *ircTransport) setAccount(nick, account string) {
+	if account = accountName(account); account == "" {
+		delete(t.accounts, nick)
+		return
+	}
+	t.accounts[nick] = account
+}
+
+func accountName(account string) string {
+	if account == "*" {
+		return ""
+	}
+	return account
+}
+
+// account returns the services account line was sent from, if known.
+func (t *ircTransport) account(line *client.Line) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.caps["account-tag"] {
+		// Lines from nicks that are logged in are always tagged.
+		return line.Tags["account"]
+	}
+	switch line.Cmd {
+	case client.JOIN:
+		if len(line.Args) == 3 {
+			return accountName(line.Args[1])
+		}
+	case "ACCOUNT":
+		if len(line.Args) == 1 {
+			return accountName(line.Args[0])
+		}
+	}
+	nick := strings.ToLower(line.Nick)
+	if acct, ok := t.accounts[nick]; ok {
+		return acct
+	}
+	// In case track has already seen the line.
+	switch line.Cmd {
+	case client.NICK:
+		return t.accounts[strings.ToLower(line.Text())]
+	case client.QUIT:
+		if t.quit.nick == nick {
+			return t.quit.account
+		}
+	}
+	return ""
+}
+
+func (t *ircTransport) Name() string {
+	return t.conn.Config().Server
+}
+
+func (t *ircTransport) Connect() error {
+	return t.conn.Connect()
+}
+
+func (t *ircTransport) Connected() bool {
+	return t.conn.Connected()
+}
+
+func (t *ircTransport) Me() Identity {
+	me := t.conn.Me()
+	return Identity{Nick: me.Nick, Ident: me.Ident, Host: me.Host}
+}
+
+func (t *ircTransport) Send(cmd, target, text string) {
+	switch cmd {
+	case ACTION:
+		t.conn.Action(target, text)
+	case NOTICE:
+		t.conn.Notice(target, text)
+	default:
+		t.conn.Privmsg(target, text)
+	}
+}
+
+func (t *ircTransport) Topic(ch string, topic ...string) {
+	t.conn.Topic(ch, topic...)
+}
+
+func (t *ircTransport) Room(cmd, target string) int {
+	prefix := maxIdentHost
+	me := t.conn.Me()
+	if me.Ident != "" && me.Host != "" {
+		prefix = len(me.Ident) + 1 + len(me.Host)
+	}
+	// ":nick!ident@host CMD target :text\r\n"
+	if cmd == ACTION {
+		cmd = PRIVMSG + " \001ACTION\001"
+	}
+	return maxLineLen - len(":! :\r\n  ") - len(me.Nick) - prefix -
+		len(cmd) - len(target)
+}
+
+func (t *ircTransport) Join(ch string) {
+	t.conn.Join(ch)
+}
+
+func (t *ircTransport) Part(ch, msg string) {
+	t.conn.Part(ch, msg)
+}
+
+func (t *ircTransport) SetNick(nick string) {
+	t.conn.Nick(nick)
+}
+
+func (t *ircTransport) Quit(msg string) {
+	t.conn.Quit(msg)
+}
+
+func (t *ircTransport) Handle(event string, h Handler) {
+	t.handle(event, h, false)
+}
+
+func (t *ircTransport) HandleBG(event string, h Handler) {
+	t.handle(event, h, true)
+}
+
+func (t *ircTransport) handle(event string, h Handler, bg bool) {
+	switch {
+	case event == CONNECTED:
+		t.mu.Lock()
+		t.onConnect = append(t.onConnect, bgHandler{h, bg})
+		t.mu.Unlock()
+	case bg:
+		t.conn.HandleBG(event, t.adapt(h))
+	default:
+		t.conn.Handle(event, t.adapt(h))
+	}
+}
+
+// adapt turns h into a goirc handler. Goirc delivers DISCONNECTED from
+// the goroutine reading from the server, as Handle requires.
+func (t *ircTransport) adapt(h Handler) client.Handler {
+	return client.HandlerFunc(func(conn *client.Conn, line *client.Line) {
+		h.Handle(t, t.line(line))
+	})
+}
+
+// line converts a goirc Line, using the server's time for it if we
+// have one, and marks lines sent well before we connected as replayed.
+func (t *ircTransport) line(line *client.Line) *Line {
+	l := &Line{
+		Tags: line.Tags, Nick: line.Nick, Ident: line.Ident,
+		Host: line.Host, Src: line.Src, Cmd: line.Cmd, Raw: line.Raw,
+		Args: line.Args, Time: line.Time, Account: t.account(line),
+	}
+	if ts, ok := line.Tags["time"]; ok {
+		if tm, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			l.Time = tm.Local()
+			t.mu.Lock()
+			l.Replayed = l.Time.Before(t.since.Add(-replayAge))
+			t.mu.Unlock()
+		} else {
+			logging.Warn("Bad server-time %q from %s: %v", ts, t.Name(), err)
+		}
+	}
+	return l
+}
+
+func (sc *ServerConfig) clientConfig() *client.Config {
+	cfg := client.NewConfig(sc.Nick, "boing", "slowly becoming sp0rkle")
+	cfg.Server = sc.Server
+	cfg.Pass = GetSecret(sc.Pass)
+	cfg.NewNick = sc.newNick
+	if sc.SSL {
+		cfg.SSL = true
+		name := sc.SSLServerName
+		if name == "" {
+			name = strings.Split(sc.Server, ":")[0]
+		}
+		cfg.SSLConfig = &tls.Config{
+			ServerName:         name,
+			InsecureSkipVerify: sc.SSLInsecure,
+		}
+	}
+	// Output is paced, per target and for the whole connection, and split
+	// to fit in 512 bytes by the send queue.
+	cfg.Flood = true
+	cfg.SplitLen = maxLineLen
+	return cfg
+}
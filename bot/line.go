@@ -0,0 +1,93 @@
+package bot
+
+import "time"
+
+// Events that handlers can be registered for with Handle(). Transports
+// deliver what they receive as Lines with one of these as Cmd, along with
+// CONNECTED and DISCONNECTED when they connect and disconnect.
+const (
+	CONNECTED    = "CONNECTED"
+	DISCONNECTED = "DISCONNECTED"
+	PRIVMSG      = "PRIVMSG"
+	ACTION       = "ACTION"
+	NOTICE       = "NOTICE"
+	JOIN         = "JOIN"
+	PART         = "PART"
+	KICK         = "KICK"
+	QUIT         = "QUIT"
+	NICK         = "NICK"
+	TOPIC        = "TOPIC"
+)
+
+// A Line is something received from a Transport. It follows IRC's
+// conventions whatever the transport, so handlers and commands don't
+// need to care where it came from:
+//
+//	Src == "nick!ident@host"
+//	Cmd == e.g. PRIVMSG
+//	Args == e.g. []string{"#chan", "text"}
+//
+// Time is when the line was sent, which may be well before it was
+// received if it was replayed by a bouncer. Tags holds any IRCv3 message
+// tags the line came with, and Account is the services account of the
+// sender, if they are logged in to one and the transport knows it.
+// Replayed is set for lines sent well before the bot connected; commands
+// and handlers ignore them, unless registered with HandleReplays.
+type Line struct {
+	Tags                   map[string]string
+	Nick, Ident, Host, Src string
+	Account                string
+	Cmd, Raw               string
+	Args                   []string
+	Time                   time.Time
+
+	Replayed bool
+}
+
+// Copy returns a deep copy of the Line.
+func (l *Line) Copy() *Line {
+	nl := *l
+	nl.Args = make([]string, len(l.Args))
+	copy(nl.Args, l.Args)
+	if l.Tags != nil {
+		nl.Tags = make(map[string]string, len(l.Tags))
+		for k, v := range l.Tags {
+			nl.Tags[k] = v
+		}
+	}
+	return &nl
+}
+
+// Text returns the last argument of the line, which for messages is the
+// text that was said.
+func (l *Line) Text() string {
+	if len(l.Args) > 0 {
+		return l.Args[len(l.Args)-1]
+	}
+	return ""
+}
+
+// Target returns where replies to the line should go: the channel for
+// messages to a channel, or the sender for private messages.
+func (l *Line) Target() string {
+	switch l.Cmd {
+	case PRIVMSG, NOTICE, ACTION:
+		if !l.Public() {
+			return l.Nick
+		}
+	}
+	if len(l.Args) > 0 {
+		return l.Args[0]
+	}
+	return ""
+}
+
+// Public returns true if the line is a message to a channel, rather than
+// directly to the bot.
+func (l *Line) Public() bool {
+	switch l.Cmd {
+	case PRIVMSG, NOTICE, ACTION:
+		return len(l.Args) > 0 && isChannel(l.Args[0])
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package bot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+)
+
+const (
+	// Server name that makes a line transport use stdin and stdout.
+	stdio = "stdio"
+	// Lines sent by a line transport are split to fit in this many bytes.
+	lineMaxLen = 4096
+	// Sources of lines received look like nick!line@host.
+	lineIdent = "line"
+)
+
+// lineTransport talks a simple line-based protocol over TCP, or over
+// stdin and stdout if the server is "stdio". It's for bridging the bot
+// to other chat networks, and for trying it out locally. Each line
+// received looks like
+//
+//	<nick> <target> <text>
+//
+// meaning nick said text to target, a channel or the bot's nick; text
+// starting with "/me " is an action. Each line the bot sends looks like
+//
+//	PRIVMSG <target> :<text>
+//
+// with ACTION, NOTICE, TOPIC, JOIN, PART, NICK or QUIT instead of PRIVMSG
+// as appropriate.
+type lineTransport struct {
+	name, host string
+
+	mu   sync.Mutex
+	nick string
+	// Where lines are written, and what to close to disconnect;
+	// w is nil while disconnected.
+	w      io.Writer
+	closer io.Closer
+	// Closed when the current connection is closed.
+	done chan struct{}
+	// Lines read from stdin; it can only be read once, so this lasts
+	// across connections and is closed when stdin is.
+	stdin <-chan string
+	eof   bool
+
+	handlers map[string][]bgHandler
+}
+
+func newLineTransport(sc *ServerConfig) Transport {
+	host := sc.Server
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return &lineTransport{name: sc.Server, host: host, nick: sc.Nick,
+		handlers: make(map[string][]bgHandler)}
+}
+
+func (t *lineTransport) Name() string {
+	return t.name
+}
+
+func (t *lineTransport) Connect() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w != nil {
+		return errors.New("already connected")
+	}
+	done := make(chan struct{})
+	var in <-chan string
+	if t.name == stdio {
+		if t.eof {
+			return errors.New("stdin is closed")
+		}
+		if t.stdin == nil {
+			t.stdin = readLines(os.Stdin, nil)
+		}
+		in, t.w, t.closer = t.stdin, os.Stdout, nil
+	} else {
+		c, err := net.DialTimeout("tcp", t.name, timeout.Get())
+		if err != nil {
+			return err
+		}
+		in, t.w, t.closer = readLines(c, done), c, c
+	}
+	t.done = done
+	go t.run(in, done)
+	return nil
+}
+
+// readLines sends lines read from r until it fails or done is closed.
+func readLines(r io.Reader, done <-chan struct{}) <-chan string {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			select {
+			case in <- s.Text():
+			case <-done:
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			logging.Error("Reading lines: %v", err)
+		}
+	}()
+	return in
+}
+
+// run delivers the lines received on a connection until it is closed.
+func (t *lineTransport) run(in <-chan string, done chan struct{}) {
+	t.dispatch(&Line{Cmd: CONNECTED, Time: time.Now()})
+	for {
+		select {
+		case s, ok := <-in:
+			if !ok {
+				t.mu.Lock()
+				t.eof = t.name == stdio
+				t.mu.Unlock()
+				t.disconnect(done)
+				return
+			}
+			if line := t.parse(s); line != nil {
+				t.dispatch(line)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// disconnect closes the connection done belongs to, if it's still open.
+func (t *lineTransport) disconnect(done chan struct{}) {
+	t.mu.Lock()
+	if t.done != done {
+		t.mu.Unlock()
+		return
+	}
+	close(done)
+	if t.closer != nil {
+		t.closer.Close()
+	}
+	t.w, t.closer, t.done = nil, nil, nil
+	t.mu.Unlock()
+	t.dispatch(&Line{Cmd: DISCONNECTED, Time: time.Now()})
+}
+
+// parse turns "<nick> <target> <text>" into a Line.
+func (t *lineTransport) parse(s string) *Line {
+	f := strings.SplitN(strings.TrimSpace(s), " ", 3)
+	if len(f) != 3 {
+		logging.Warn("Ignoring malformed line %q from %s.", s, t.name)
+		return nil
+	}
+	nick, target, text := f[0], f[1], f[2]
+	cmd := PRIVMSG
+	if strings.HasPrefix(text, "/me ") {
+		cmd, text = ACTION, text[len("/me "):]
+	}
+	return &Line{
+		Nick: nick, Ident: lineIdent, Host: t.host,
+		Src: nick + "!" + lineIdent + "@" + t.host,
+		Cmd: cmd, Raw: s, Args: []string{target, text}, Time: time.Now(),
+	}
+}
+
+func (t *lineTransport) dispatch(line *Line) {
+	t.mu.Lock()
+	hs := t.handlers[line.Cmd]
+	t.mu.Unlock()
+	for _, h := range hs {
+		h.run(t, line)
+	}
+}
+
+func (t *lineTransport) write(format string, args ...interface{}) {
+	s := strings.ReplaceAll(fmt.Sprintf(format, args...), "\n", " ")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w == nil {
+		return
+	}
+	if _, err := io.WriteString(t.w, s+"\n"); err != nil {
+		logging.Error("Writing to %s: %v", t.name, err)
+	}
+}
+
+func (t *lineTransport) Connected() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w != nil
+}
+
+func (t *lineTransport) Me() Identity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Identity{Nick: t.nick, Ident: lineIdent, Host: t.host}
+}
+
+func (t *lineTransport) Send(cmd, target, text string) {
+	t.write("%s %s :%s", cmd, target, text)
+}
+
+func (t *lineTransport) Topic(ch string, topic ...string) {
+	if len(topic) == 0 {
+		t.write("%s %s", TOPIC, ch)
+		return
+	}
+	t.write("%s %s :%s", TOPIC, ch, strings.Join(topic, " "))
+}
+
+func (t *lineTransport) Room(cmd, target string) int {
+	// "CMD target :text\n"
+	return lineMaxLen - len(cmd) - len(target) - len("  :\n")
+}
+
+func (t *lineTransport) Join(ch string) {
+	t.write("%s %s", JOIN, ch)
+}
+
+func (t *lineTransport) Part(ch, msg string) {
+	if msg == "" {
+		t.write("%s %s", PART, ch)
+		return
+	}
+	t.write("%s %s :%s", PART, ch, msg)
+}
+
+func (t *lineTransport) SetNick(nick string) {
+	t.mu.Lock()
+	t.nick = nick
+	t.mu.Unlock()
+	t.write("%s %s", NICK, nick)
+}
+
+// Quit disconnects. Since it may be called by a handler, DISCONNECTED is
+// delivered from another goroutine.
+func (t *lineTransport) Quit(msg string) {
+	t.write("%s :%s", QUIT, msg)
+	t.mu.Lock()
+	done := t.done
+	t.mu.Unlock()
+	if done != nil {
+		go t.disconnect(done)
+	}
+}
+
+func (t *lineTransport) Handle(event string, h Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[event] = append(t.handlers[event], bgHandler{h, false})
+}
+
+func (t *lineTransport) HandleBG(event string, h Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[event] = append(t.handlers[event], bgHandler{h, true})
+}
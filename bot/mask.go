@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Masks are IRC-style nick!ident@host globs, where '*' matches any
+// run of characters and '?' matches exactly one. Matching is case
+// insensitive, as IRC nicks and hostnames are.
+
+// NormalizeMask turns partial masks into complete nick!ident@host ones,
+// so that "fluffle" becomes "fluffle!*@*" and "*@host" becomes "*!*@host".
+func NormalizeMask(mask string) string {
+	mask = strings.ToLower(strings.TrimSpace(mask))
+	if mask == "" {
+		return ""
+	}
+	bang, at := strings.Index(mask, "!"), strings.Index(mask, "@")
+	switch {
+	case bang == -1 && at == -1:
+		return mask + "!*@*"
+	case bang == -1:
+		return "*!" + mask
+	case at == -1:
+		return mask + "@*"
+	}
+	return mask
+}
+
+// AnyHost returns true if mask matches any ident and host, so that anyone
+// using the right nick matches it.
+func AnyHost(mask string) bool {
+	mask = NormalizeMask(mask)
+	bang := strings.Index(mask, "!")
+	return bang != -1 && strings.Trim(mask[bang+1:], "*@") == ""
+}
+
+// maskRx compiles a (normalized) mask into an anchored regular expression.
+func maskRx(mask string) (*regexp.Regexp, error) {
+	rx := make([]string, 0, len(mask))
+	for _, r := range mask {
+		switch r {
+		case '*':
+			rx = append(rx, ".*")
+		case '?':
+			rx = append(rx, ".")
+		default:
+			rx = append(rx, regexp.QuoteMeta(string(r)))
+		}
+	}
+	return regexp.Compile("(?i)^" + strings.Join(rx, "") + "$")
+}
+
+// MatchMask returns true if src (a nick!ident@host) matches mask.
+func MatchMask(mask, src string) bool {
+	rx, err := maskRx(NormalizeMask(mask))
+	if err != nil {
+		return false
+	}
+	return rx.MatchString(src)
+}
@@ -0,0 +1,64 @@
+package bot
+
+import "testing"
+
+func TestNormalizeMask(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"fluffle", "fluffle!*@*"},
+		{"Fluffle!Boing", "fluffle!boing@*"},
+		{"*@spam.example", "*!*@spam.example"},
+		{"a!b@c", "a!b@c"},
+	}
+	for i, test := range tests {
+		if o := NormalizeMask(test.in); o != test.out {
+			t.Errorf("NormalizeMask(%d) %q: exp %q got %q", i, test.in, test.out, o)
+		}
+	}
+}
+
+func TestAnyHost(t *testing.T) {
+	tests := []struct {
+		mask string
+		any  bool
+	}{
+		{"", false},
+		{"fluffle", true},
+		{"fluffle!*@*", true},
+		{"fluffle!**@*", true},
+		{"fluffle!boing", false},
+		{"*@pl0rt.org", false},
+		{"fluffle!*@*.pl0rt.org", false},
+	}
+	for i, test := range tests {
+		if a := AnyHost(test.mask); a != test.any {
+			t.Errorf("AnyHost(%d) %q: exp %t got %t", i, test.mask, test.any, a)
+		}
+	}
+}
+
+func TestMatchMask(t *testing.T) {
+	tests := []struct {
+		mask, src string
+		match     bool
+	}{
+		{"fluffle", "fluffle!boing@pl0rt.org", true},
+		{"fluffle", "FLUFFLE!boing@pl0rt.org", true},
+		{"fluffle", "fluffle_!boing@pl0rt.org", false},
+		{"*!*@*.pl0rt.org", "bob!bob@s.pl0rt.org", true},
+		{"*!*@*.pl0rt.org", "bob!bob@pl0rt.org", false},
+		{"b?b", "bob!x@y", true},
+		{"b?b", "boob!x@y", false},
+		{"[nick]", "[nick]!x@y", true},
+		{"[nick]", "n!x@y", false},
+		{"a.b", "axb!x@y", false},
+	}
+	for i, test := range tests {
+		if m := MatchMask(test.mask, test.src); m != test.match {
+			t.Errorf("MatchMask(%d) %q ~ %q: exp %t got %t",
+				i, test.mask, test.src, test.match, m)
+		}
+	}
+}
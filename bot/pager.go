@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/sp0rkle/util/config"
+)
+
+var pageExpiry = config.Duration("page_expiry", 10*time.Minute,
+	"How long the bot remembers the rest of a long reply for 'more'.")
+
+const (
+	// Paged replies show this many lines at a time,
+	pageLines = 5
+	// or this many items of a list joined into one line.
+	pageItems = 10
+)
+
+// A pager holds the remainder of a long reply.
+type pager struct {
+	items   []string
+	join    string // If empty, each item is sent as a separate line.
+	all     bool   // Send everything at once, e.g. if the reply is piped.
+	literal bool   // Send lines without rewriting them.
+	expires time.Time
+}
+
+// next returns the next page of lines and how many items remain after it.
+func (p *pager) next() ([]string, int) {
+	n := pageLines
+	if p.join != "" {
+		n = pageItems
+	}
+	if p.all || n > len(p.items) {
+		n = len(p.items)
+	}
+	page := p.items[:n]
+	p.items = p.items[n:]
+	if p.join != "" {
+		page = []string{strings.Join(page, p.join)}
+	}
+	return page, len(p.items)
+}
+
+// pagerSet keeps a cursor into a long reply for each nick and target.
+type pagerSet struct {
+	sync.Mutex
+	set map[string]*pager
+}
+
+var pagers = &pagerSet{set: make(map[string]*pager)}
+
+func pagerKey(ctx *Context) string {
+	return strings.ToLower(ctx.Nick + " " + ctx.Target())
+}
+
+// take removes and returns ctx's cursor, if it has an unexpired one.
+func (ps *pagerSet) take(ctx *Context) *pager {
+	ps.Lock()
+	defer ps.Unlock()
+	key := pagerKey(ctx)
+	p, ok := ps.set[key]
+	if !ok {
+		return nil
+	}
+	delete(ps.set, key)
+	if time.Now().After(p.expires) {
+		return nil
+	}
+	return p
+}
+
+// put stores p as ctx's cursor, or removes the cursor if p is finished.
+func (ps *pagerSet) put(ctx *Context, p *pager) {
+	ps.Lock()
+	defer ps.Unlock()
+	key := pagerKey(ctx)
+	if len(p.items) == 0 {
+		delete(ps.set, key)
+		return
+	}
+	now := time.Now()
+	for k, old := range ps.set {
+		if now.After(old.expires) {
+			delete(ps.set, k)
+		}
+	}
+	p.expires = now.Add(pageExpiry.Get())
+	ps.set[key] = p
+}
+
+// showPage sends the next page of p, prefixing the first line with intro.
+func (ctx *Context) showPage(p *pager, intro string) {
+	page, left := p.next()
+	more := ""
+	if left > 0 {
+		more = fmt.Sprintf("(%d more, say 'more')", left)
+	}
+	if p.join != "" {
+		if more != "" {
+			more = " " + more
+		}
+		ctx.ReplyN("%s%s%s", intro, page[0], more)
+	} else {
+		if intro != "" {
+			ctx.ReplyN("%s", intro)
+		}
+		for _, l := range page {
+			if p.literal {
+				ctx.Privmsg(ctx.Target(), l)
+			} else {
+				ctx.Reply("%s", l)
+			}
+		}
+		if more != "" {
+			ctx.ReplyN("%s", more)
+		}
+	}
+	pagers.put(ctx, p)
+}
+
+// ReplyPaged replies with lines a page at a time, after an optional intro.
+// The rest can be seen with the "more" command.
+func (ctx *Context) ReplyPaged(intro string, lines []string) {
+	ctx.showPage(&pager{items: lines, all: ctx.Piped()}, intro)
+}
+
+// ReplyPagedLiteral is ReplyPaged for lines of literal data, which
+// rewriters mustn't change, like factoid values.
+func (ctx *Context) ReplyPagedLiteral(intro string, lines []string) {
+	ctx.showPage(&pager{items: lines, all: ctx.Piped(), literal: true}, intro)
+}
+
+// ReplyPagedList replies with items joined by sep on one line after intro,
+// a page at a time. The rest can be seen with the "more" command.
+func (ctx *Context) ReplyPagedList(intro string, items []string, sep string) {
+	ctx.showPage(&pager{items: items, join: sep, all: ctx.Piped()}, intro)
+}
+
+func more(ctx *Context) {
+	p := pagers.take(ctx)
+	if p == nil {
+		ctx.ReplyN("There's nothing more to see here.")
+		return
+	}
+	ctx.showPage(p, "")
+}
@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPagerNext(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f", "g"}
+	p := &pager{items: items}
+	if page, left := p.next(); !reflect.DeepEqual(page, items[:pageLines]) || left != 2 {
+		t.Errorf("lines page 1: got %q, %d left", page, left)
+	}
+	if page, left := p.next(); !reflect.DeepEqual(page, items[pageLines:]) || left != 0 {
+		t.Errorf("lines page 2: got %q, %d left", page, left)
+	}
+
+	items = make([]string, pageItems+3)
+	for i := range items {
+		items[i] = "x"
+	}
+	p = &pager{items: items, join: ","}
+	if page, left := p.next(); len(page) != 1 || len(page[0]) != 2*pageItems-1 || left != 3 {
+		t.Errorf("list page 1: got %q, %d left", page, left)
+	}
+	if page, left := p.next(); !reflect.DeepEqual(page, []string{"x,x,x"}) || left != 0 {
+		t.Errorf("list page 2: got %q, %d left", page, left)
+	}
+}
@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pipeSep separates the commands in a pipeline, e.g.
+// "calc 2**20 | base 10to16". What each command replies is appended
+// to the text of the next one instead of being sent.
+const pipeSep = " | "
+
+const (
+	// Pipelines may have at most this many commands,
+	maxPipeCommands = 5
+	// and each may pass at most this many bytes to the next.
+	maxPipeOutput = 1024
+)
+
+// pipeOutput captures replies from a command in a pipeline. Anything the
+// command sends elsewhere, e.g. with "say", is passed through to out.
+type pipeOutput struct {
+	out          Output
+	nick, target string
+	// What was said, as sent and with the nick prefix trimmed.
+	sent  [][2]string
+	lines []string
+	size  int
+}
+
+func (po *pipeOutput) Send(cmd, target, text string) {
+	if target != po.target {
+		po.out.Send(cmd, target, text)
+		return
+	}
+	po.sent = append(po.sent, [2]string{cmd, text})
+	text = strings.TrimPrefix(text, po.nick+": ")
+	po.lines = append(po.lines, text)
+	po.size += len(text)
+}
+
+func (po *pipeOutput) Topic(ch string, topic ...string) {
+	po.out.Topic(ch, topic...)
+}
+
+func (po *pipeOutput) String() string {
+	return strings.Join(po.lines, " ")
+}
+
+// flush sends what was captured on to out, e.g. when the command failed.
+func (po *pipeOutput) flush() {
+	for _, s := range po.sent {
+		po.out.Send(s[0], po.target, s[1])
+	}
+}
+
+// Pipes lets a command's replies be piped into another command with
+// " | ". Only commands that reply with a value worth passing on should
+// use it, since for any others " | " is left in their text.
+func Pipes() CommandOpt {
+	return func(c *command) { c.pipes = true }
+}
+
+// pipes returns true if the command txt starts with may be piped.
+func (cs *commandSet) pipes(txt string) bool {
+	r, _ := cs.match(txt)
+	c, ok := r.(*command)
+	return ok && c.pipes
+}
+
+// Piped returns true if ctx's replies will be passed to another command.
+// Commands that produce a value may reply with only that, to make them
+// more useful in pipelines.
+func (ctx *Context) Piped() bool {
+	_, ok := ctx.out.(*pipeOutput)
+	return ok
+}
+
+// splitPipe splits txt before the first pipeSep that is followed by a
+// command and isn't inside double quotes, if txt starts with a command
+// that may be piped. Commands taking free text don't split at all, so
+// "remind me to call mum | ask her..." is one reminder; quoting lets
+// other commands contain " | " too.
+func (cs *commandSet) splitPipe(txt string) (string, string) {
+	if !cs.pipes(txt) {
+		return txt, ""
+	}
+	for i := 0; ; {
+		j := strings.Index(txt[i:], pipeSep)
+		if j == -1 {
+			return txt, ""
+		}
+		i += j
+		rest := txt[i+len(pipeSep):]
+		if strings.Count(txt[:i], `"`)%2 == 1 {
+			i += len(pipeSep)
+			continue
+		}
+		if next, err := aliases.resolve(rest); err != nil || next != rest {
+			// Either way, rest starts with an alias.
+			return txt[:i], rest
+		}
+		if r, _ := cs.match(rest); r != nil {
+			return txt[:i], rest
+		}
+		i += len(pipeSep)
+	}
+}
+
+// pipeline expands aliases in txt and splits it into commands.
+func (cs *commandSet) pipeline(txt string) ([]string, error) {
+	var cmds []string
+	for txt != "" {
+		var err error
+		if txt, err = aliases.resolve(txt); err != nil {
+			return nil, err
+		}
+		var cmd string
+		cmd, txt = cs.splitPipe(txt)
+		cmds = append(cmds, cmd)
+	}
+	if len(cmds) > maxPipeCommands {
+		return nil, fmt.Errorf("Pipelines can have at most %d commands.",
+			maxPipeCommands)
+	}
+	return cmds, nil
+}
+
+// runPipe runs each command in cmds with the output of the previous one
+// appended to its text, sending what the last one says as usual. If a
+// command fails, what it said is sent instead, and the rest aren't run.
+func (cs *commandSet) runPipe(ctx *Context, cmds []string) {
+	var input string
+	for i, cmd := range cmds {
+		c := *ctx
+		c.Line = ctx.Line.Copy()
+		c.Args[1] = strings.TrimSpace(cmd + " " + input)
+		if i == len(cmds)-1 {
+			cs.dispatch(&c)
+			return
+		}
+		po := &pipeOutput{out: ctx.out, nick: ctx.Nick, target: ctx.Target()}
+		c.out = po
+		if !cs.dispatch(&c) {
+			return
+		}
+		switch {
+		case c.failed:
+			po.flush()
+			return
+		case po.size > maxPipeOutput:
+			ctx.ReplyN("'%s' said too much to pipe into another command.", c.cmd)
+			return
+		case len(po.lines) == 0:
+			ctx.ReplyN("'%s' said nothing to pipe into another command.", c.cmd)
+			return
+		}
+		input = po.String()
+	}
+}
@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+// Conf namespace for per-channel policies, keyed by lowercase channel.
+// Values look like "deny factdriver.lookup karmadriver" or "allow calc".
+const policyNs = "policy"
+
+// Commands, handlers and rewriters are known by a set of names, any of
+// which can be used in a policy: the driver that registered them (e.g.
+// "factdriver"), the driver-qualified function (e.g. "factdriver.lookup")
+// and, for commands, the command prefix (e.g. "fact search"). Names are
+// worked out once, when things are registered.
+
+// funcNames works out the names of a function. Handlers and rewriters
+// defined in package bot are exempt from policy, so they return nil.
+func funcNames(fn interface{}) []string {
+	name := "unknown"
+	if v := reflect.ValueOf(fn); v.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			name = f.Name()
+		}
+	} else {
+		name = reflect.Indirect(v).Type().String()
+	}
+	return splitName(name)
+}
+
+// anonFunc matches the names the compiler makes up for closures and
+// method values, e.g. "Init.func1" or "(*mcStatus).Topic-fm". They change
+// whenever the code around them does, so they can't be used in policies.
+var anonFunc = regexp.MustCompile(`\.func\d+|-fm$`)
+
+// "github.com/fluffle/sp0rkle/drivers/factdriver.lookup" => "factdriver.lookup"
+// Closures are only known by their driver; give handlers that should be
+// named in policies their own top-level function.
+func splitName(name string) []string {
+	name = strings.ToLower(name[strings.LastIndex(name, "/")+1:])
+	driver := name
+	if idx := strings.Index(name, "."); idx != -1 {
+		driver = name[:idx]
+	}
+	if driver == "bot" {
+		return nil
+	}
+	if anonFunc.MatchString(name) {
+		return []string{driver}
+	}
+	return []string{driver, name}
+}
+
+// splitItems splits s into names, which are single words or, for
+// multi-word command prefixes, double-quoted strings.
+func splitItems(s string) ([]string, error) {
+	var items []string
+	for s = strings.TrimSpace(s); s != ""; {
+		item, rest, err := splitQuoted(s)
+		if err != nil {
+			return items, err
+		}
+		if item = strings.Join(strings.Fields(item), " "); item != "" {
+			items = append(items, item)
+		}
+		s = rest
+	}
+	return items, nil
+}
+
+// quoteItem quotes names with spaces in, so splitItems can read them.
+func quoteItem(item string) string {
+	if strings.Contains(item, " ") {
+		return `"` + item + `"`
+	}
+	return item
+}
+
+type policy struct {
+	allow bool
+	items map[string]bool
+}
+
+func parsePolicy(s string) *policy {
+	// A malformed policy can only come from editing conf by hand;
+	// keep whatever could be read.
+	f, _ := splitItems(s)
+	if len(f) == 0 {
+		return nil
+	}
+	p := &policy{allow: f[0] == "allow", items: map[string]bool{}}
+	for _, item := range f[1:] {
+		p.items[item] = true
+	}
+	return p
+}
+
+// copy returns an empty deny policy if p is nil. Cached policies are
+// read concurrently, so changes are made to a copy which is then set.
+func (p *policy) copy() *policy {
+	c := &policy{items: map[string]bool{}}
+	if p != nil {
+		c.allow = p.allow
+		for item := range p.items {
+			c.items[item] = true
+		}
+	}
+	return c
+}
+
+func (p *policy) permits(names []string) bool {
+	if p == nil || names == nil {
+		return true
+	}
+	for _, n := range names {
+		if p.items[n] {
+			return p.allow
+		}
+	}
+	return !p.allow
+}
+
+func (p *policy) list() []string {
+	items := make([]string, 0, len(p.items))
+	for item := range p.items {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items
+}
+
+func (p *policy) String() string {
+	if p == nil || (!p.allow && len(p.items) == 0) {
+		return ""
+	}
+	mode := "deny"
+	if p.allow {
+		mode = "allow"
+	}
+	items := []string{mode}
+	for _, item := range p.list() {
+		items = append(items, quoteItem(item))
+	}
+	return strings.Join(items, " ")
+}
+
+// policySet caches per-channel policies, since they're checked for every
+// handler and rewriter that runs. It also tracks the names of everything
+// registered with the bot, so policies can be checked for typos.
+type policySet struct {
+	sync.RWMutex
+	chans map[string]*policy
+	known map[string]bool
+}
+
+var policies = &policySet{
+	chans: make(map[string]*policy),
+	known: make(map[string]bool),
+}
+
+func (ps *policySet) register(names ...string) {
+	ps.Lock()
+	defer ps.Unlock()
+	for _, n := range names {
+		ps.known[strings.ToLower(n)] = true
+	}
+}
+
+func (ps *policySet) isKnown(name string) bool {
+	ps.RLock()
+	defer ps.RUnlock()
+	return ps.known[name]
+}
+
+func (ps *policySet) get(ch string) *policy {
+	ch = strings.ToLower(ch)
+	ps.RLock()
+	p, ok := ps.chans[ch]
+	ps.RUnlock()
+	if ok {
+		return p
+	}
+	p = parsePolicy(conf.Ns(policyNs).String(ch))
+	ps.Lock()
+	defer ps.Unlock()
+	ps.chans[ch] = p
+	return p
+}
+
+func (ps *policySet) set(ch string, p *policy) {
+	ch = strings.ToLower(ch)
+	ps.Lock()
+	defer ps.Unlock()
+	if s := p.String(); s != "" {
+		conf.Ns(policyNs).String(ch, s)
+	} else {
+		conf.Ns(policyNs).Delete(ch)
+		p = nil
+	}
+	ps.chans[ch] = p
+}
+
+// Permits returns true if something known by names may run in response
+// to ctx. Lines that didn't happen in a channel are always permitted.
+func (ps *policySet) Permits(ctx *Context, names []string) bool {
+	if names == nil {
+		return true
+	}
+	ch := ctx.channel()
+	if ch == "" {
+		return true
+	}
+	return ps.get(ch).permits(names)
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuncNames(t *testing.T) {
+	if n := funcNames(HandlerFunc(connected)); n != nil {
+		t.Errorf("funcNames(connected): exp nil got %v", n)
+	}
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"github.com/fluffle/sp0rkle/drivers/factdriver.lookup",
+			[]string{"factdriver", "factdriver.lookup"}},
+		{"github.com/fluffle/sp0rkle/drivers/netdriver.Init.func1",
+			[]string{"netdriver"}},
+		{"github.com/fluffle/sp0rkle/drivers/netdriver.(*mcStatus).Topic-fm",
+			[]string{"netdriver"}},
+		{"github.com/fluffle/sp0rkle/bot.connected", nil},
+		{"main", []string{"main", "main"}},
+	}
+	for i, test := range tests {
+		if n := splitName(test.in); !reflect.DeepEqual(n, test.out) {
+			t.Errorf("splitName(%d) %q: exp %v got %v", i, test.in, test.out, n)
+		}
+	}
+}
+
+func TestPolicyPermits(t *testing.T) {
+	fact := []string{"factdriver", "factdriver.lookup", "fact search"}
+	calc := []string{"calcdriver", "calcdriver.calculate", "calc"}
+	tests := []struct {
+		policy     string
+		fact, calc bool
+	}{
+		{"", true, true},
+		{"deny factdriver", false, true},
+		{"deny calc factdriver.lookup", false, false},
+		{"deny factdriver.insert", true, true},
+		{"allow calc", false, true},
+		{`deny "fact search"`, false, true},
+		{`deny calc "quote add"`, true, false},
+		{"allow", false, false},
+	}
+	for i, test := range tests {
+		p := parsePolicy(test.policy)
+		if f, c := p.permits(fact), p.permits(calc); f != test.fact || c != test.calc {
+			t.Errorf("permits(%d) %q: exp %t, %t got %t, %t",
+				i, test.policy, test.fact, test.calc, f, c)
+		}
+		if p.String() != test.policy {
+			t.Errorf("String(%d): exp %q got %q", i, test.policy, p.String())
+		}
+		if !p.permits(nil) {
+			t.Errorf("permits(%d) %q: bot internals should always be permitted",
+				i, test.policy)
+		}
+	}
+}
+
+func TestSplitItems(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+		err bool
+	}{
+		{"", nil, false},
+		{"calc factdriver", []string{"calc", "factdriver"}, false},
+		{`"fact  search" calc`, []string{"fact search", "calc"}, false},
+		{`calc "remind del" in #chan`, []string{"calc", "remind del", "in", "#chan"}, false},
+		{`calc "remind del`, []string{"calc"}, true},
+	}
+	for i, test := range tests {
+		out, err := splitItems(test.in)
+		if !reflect.DeepEqual(out, test.out) || (err != nil) != test.err {
+			t.Errorf("splitItems(%d) %q: exp %q, %t got %q, %v",
+				i, test.in, test.out, test.err, out, err)
+		}
+	}
+}
@@ -1,96 +1,235 @@
 package bot
 
 import (
+	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+var (
+	pollerRuns = metrics.NewCounter("sp0rkle_poller_runs_total",
+		"Times each poller has polled.", "poller")
+	pollerFailures = metrics.NewCounter("sp0rkle_poller_failures_total",
+		"Polls that failed or panicked, by poller.", "poller")
+)
+
+// Conf namespace recording pollers stopped with "poller stop", by name,
+// so they stay stopped when the bot restarts.
+const pollersNs = "pollers"
+
+const (
+	// Intervals shorter than this are lengthened, so a bad setting
+	// can't make a poller hammer whatever it polls.
+	minTick = 10 * time.Second
+	// After each consecutive failure a poller's interval is doubled,
+	// up to this or its usual interval, whichever is longer.
+	maxBackoff = time.Hour
+	// Intervals vary randomly by up to this fraction either way,
+	// so pollers with the same interval don't all run at once.
+	jitterFraction = 0.1
 )
 
 type Poller interface {
-	Poll([]*Context)
+	// Poll is passed a Context for each server the bot is connected to.
+	// If it returns an error, the next poll is delayed to back off.
+	Poll([]*Context) error
+	// Start and Stop are called when the poller starts and stops running.
 	Start()
 	Stop()
+	// Tick returns how long to wait between polls. It is called after
+	// every poll, so the interval can be changed at runtime.
 	Tick() time.Duration
 }
 
 type PollerSet interface {
-	Add(Poller)
-	client.Handler
+	Add(name string, p Poller)
+	Handler
+}
+
+// pollerState is what the pollerSet knows about a registered poller.
+// It's protected by the pollerSet's lock.
+type pollerState struct {
+	Poller
+	name string
+	// Stopped by an admin; it won't run until it's started again.
+	stopped bool
+	// Closed to make a running poller stop; nil if it isn't running.
+	quit chan struct{}
+	// Closed when the poller's last run has stopped.
+	done chan struct{}
+	// When the poller last ran and when it will next run.
+	last, next time.Time
+	// The most recent error, and the number of polls in a row that failed.
+	err      error
+	errAt    time.Time
+	failures int
 }
 
 type pollerSet struct {
 	sync.RWMutex
-	// Pollers are started once when we first connect to a server
-	// and are stopped when we disconnect from the last server.
-	set map[Poller]chan struct{}
+	// Pollers run while we're connected to at least one server,
+	// unless they have been stopped.
+	set map[string]*pollerState
 	// Each time Poll() is called, it is passed a list of Contexts
 	// that represent the set of servers currently connected to.
-	conns map[*client.Conn]*Context
+	conns map[Transport]*Context
 }
 
 func newPollerSet() *pollerSet {
 	return &pollerSet{
-		set:   make(map[Poller]chan struct{}),
-		conns: make(map[*client.Conn]*Context),
+		set:   make(map[string]*pollerState),
+		conns: make(map[Transport]*Context),
 	}
 }
 
-func (ps *pollerSet) Add(p Poller) {
+func (ps *pollerSet) Add(name string, p Poller) {
 	ps.Lock()
 	defer ps.Unlock()
-	ps.set[p] = ps.startOne(p)
+	if _, ok := ps.set[name]; ok {
+		logging.Error("Poller '%s' already registered.", name)
+		return
+	}
+	st := &pollerState{Poller: p, name: name,
+		stopped: conf.Ns(pollersNs).String(name) != ""}
+	ps.set[name] = st
+	ps.startOne(st)
 	logging.Debug("Add: # conns: %d, # pollers: %d", len(ps.conns), len(ps.set))
 }
 
 // pollerSet handles both CONNECTED and DISCONNECTED events
-func (ps *pollerSet) Handle(conn *client.Conn, line *client.Line) {
+func (ps *pollerSet) Handle(t Transport, line *Line) {
 	ps.Lock()
 	defer ps.Unlock()
 	switch line.Cmd {
-	case client.CONNECTED:
-		ps.conns[conn] = reqContext(conn, line)
+	case CONNECTED:
+		ps.conns[t] = reqContext(t, line)
 		logging.Debug("Conn: # conns: %d, # pollers: %d", len(ps.conns), len(ps.set))
 		if len(ps.conns) == 1 {
-			for p := range ps.set {
-				ps.set[p] = ps.startOne(p)
+			for _, st := range ps.set {
+				ps.startOne(st)
 			}
 		}
-	case client.DISCONNECTED:
-		delete(ps.conns, conn)
+	case DISCONNECTED:
+		delete(ps.conns, t)
 		logging.Debug("Disc: # conns: %d, # pollers: %d", len(ps.conns), len(ps.set))
 		if len(ps.conns) == 0 {
-			for p, quit := range ps.set {
-				close(quit)
-				ps.set[p] = nil
+			for _, st := range ps.set {
+				ps.stopOne(st)
 			}
 		}
 	}
 }
 
-func (ps *pollerSet) startOne(p Poller) chan struct{} {
-	if len(ps.conns) == 0 {
-		return nil
-	}
-	logging.Debug("Starting poller %#v at %s intervals.", p, p.Tick())
-	tick := time.NewTicker(p.Tick())
-	quit := make(chan struct{})
-	go func() {
-		p.Start()
-		p.Poll(ps.contexts())
-		for {
-			select {
-			case <-tick.C:
-				p.Poll(ps.contexts())
-			case <-quit:
-				tick.Stop()
-				p.Stop()
-				return
-			}
+// startOne runs st if it should be running and isn't.
+// It must be called with the lock held.
+func (ps *pollerSet) startOne(st *pollerState) {
+	if len(ps.conns) == 0 || st.stopped || st.quit != nil {
+		return
+	}
+	logging.Debug("Starting poller %s.", st.name)
+	prev := st.done
+	st.quit, st.done = make(chan struct{}), make(chan struct{})
+	st.next = time.Now()
+	go ps.run(st, st.quit, st.done, prev)
+}
+
+// stopOne stops st if it's running. It must be called with the lock held.
+func (ps *pollerSet) stopOne(st *pollerState) {
+	if st.quit == nil {
+		return
+	}
+	logging.Debug("Stopping poller %s.", st.name)
+	close(st.quit)
+	st.quit = nil
+}
+
+// run polls st until quit is closed, then closes done. If the poller was
+// restarted quickly, the previous run may still be finishing a poll, so
+// it waits for that run's done first, to keep polls from overlapping and
+// Start and Stop calls paired up.
+func (ps *pollerSet) run(st *pollerState, quit, done, prev chan struct{}) {
+	defer close(done)
+	if prev != nil {
+		<-prev
+	}
+	select {
+	case <-quit:
+		// Stopped again while waiting.
+		return
+	default:
+	}
+	st.Start()
+	for {
+		t := time.NewTimer(ps.poll(st))
+		select {
+		case <-t.C:
+		case <-quit:
+			t.Stop()
+			st.Stop()
+			return
+		}
+	}
+}
+
+// poll runs st once and records the outcome, returning how long to wait
+// before running it again.
+func (ps *pollerSet) poll(st *pollerState) time.Duration {
+	pollerRuns.Inc(st.name)
+	err := st.safePoll(ps.contexts())
+	tick := st.Tick()
+	ps.Lock()
+	defer ps.Unlock()
+	st.last = time.Now()
+	if err != nil {
+		pollerFailures.Inc(st.name)
+		logging.Error("Poller %s failed: %v", st.name, err)
+		st.err, st.errAt = err, st.last
+		st.failures++
+	} else {
+		st.failures = 0
+	}
+	d := jitter(backoff(tick, st.failures))
+	st.next = st.last.Add(d)
+	return d
+}
+
+// safePoll polls, recovering from any panic so that one bad poll
+// doesn't take the bot down with it.
+func (st *pollerState) safePoll(ctxs []*Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panicked: %v", r)
 		}
 	}()
-	return quit
+	return st.Poll(ctxs)
+}
+
+// backoff returns how long to wait between polls for a poller with the
+// given interval, after some number of consecutive failures.
+func backoff(tick time.Duration, failures int) time.Duration {
+	if tick < minTick {
+		tick = minTick
+	}
+	d := tick
+	for i := 0; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if failures > 0 && d > maxBackoff {
+		d = max(tick, maxBackoff)
+	}
+	return d
+}
+
+// jitter varies d randomly by up to jitterFraction.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration((2*rand.Float64()-1)*jitterFraction*float64(d))
 }
 
 func (ps *pollerSet) contexts() []*Context {
@@ -102,3 +241,63 @@ func (ps *pollerSet) contexts() []*Context {
 	}
 	return ctxs
 }
+
+// setStopped stops or starts the named poller, remembering the choice.
+// It returns false if there's no such poller.
+func (ps *pollerSet) setStopped(name string, stopped bool) bool {
+	ps.Lock()
+	defer ps.Unlock()
+	st, ok := ps.set[name]
+	if !ok {
+		return false
+	}
+	st.stopped = stopped
+	if stopped {
+		conf.Ns(pollersNs).String(name, "stopped")
+		ps.stopOne(st)
+	} else {
+		conf.Ns(pollersNs).Delete(name)
+		ps.startOne(st)
+	}
+	return true
+}
+
+// status describes each poller, sorted by name.
+func (ps *pollerSet) status() []string {
+	ps.RLock()
+	defer ps.RUnlock()
+	l := make([]string, 0, len(ps.set))
+	for name, st := range ps.set {
+		s := name + ": "
+		switch next := time.Until(st.next); {
+		case st.stopped:
+			s += "stopped"
+		case st.quit == nil:
+			s += "waiting for a connection"
+		case next < time.Second:
+			s += "polling now"
+		default:
+			s += fmt.Sprintf("next poll in %s",
+				util.TimeSince(time.Now().Add(-next)))
+		}
+		if !st.last.IsZero() {
+			s += ", last polled " + ago(st.last)
+		}
+		if st.failures > 0 {
+			s += fmt.Sprintf(", failed %d time(s) in a row", st.failures)
+		}
+		if st.err != nil {
+			s += fmt.Sprintf(", last error %s: %v", ago(st.errAt), st.err)
+		}
+		l = append(l, s)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func ago(t time.Time) string {
+	if s := util.TimeSince(t); s != "" {
+		return s + " ago"
+	}
+	return "just now"
+}
@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		tick     time.Duration
+		failures int
+		exp      time.Duration
+	}{
+		{time.Minute, 0, time.Minute},
+		{time.Minute, 1, 2 * time.Minute},
+		{time.Minute, 3, 8 * time.Minute},
+		{time.Minute, 6, time.Hour},
+		{time.Minute, 100, time.Hour},
+		{0, 0, minTick},
+		{time.Second, 1, 2 * minTick},
+		{2 * time.Hour, 0, 2 * time.Hour},
+		{2 * time.Hour, 3, 2 * time.Hour},
+	}
+	for i, test := range tests {
+		if d := backoff(test.tick, test.failures); d != test.exp {
+			t.Errorf("backoff(%d) %s, %d: exp %s got %s", i,
+				test.tick, test.failures, test.exp, d)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Minute); d < 54*time.Second || d > 66*time.Second {
+			t.Fatalf("jitter(1m): %s out of range", d)
+		}
+	}
+}
+
+type fakePoller struct {
+	polls, started, stopped chan bool
+	err                     error
+}
+
+func (p *fakePoller) Poll([]*Context) error {
+	p.polls <- true
+	return p.err
+}
+func (p *fakePoller) Start()              { p.started <- true }
+func (p *fakePoller) Stop()               { p.stopped <- true }
+func (p *fakePoller) Tick() time.Duration { return time.Hour }
+
+func TestPollerControl(t *testing.T) {
+	logging.InitFromFlags()
+	conf.UseInMem()
+	ps := newPollerSet()
+	// Pretend we're connected to a server.
+	ps.conns[nil] = &Context{}
+	p := &fakePoller{make(chan bool, 1), make(chan bool, 1),
+		make(chan bool, 1), errors.New("oops")}
+	waitFor := func(c chan bool, what string) {
+		t.Helper()
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+	expect := func(substr string) {
+		t.Helper()
+		// The poller's state is updated just after Poll returns.
+		for i := 0; i < 100; i++ {
+			if s := ps.status(); len(s) == 1 && strings.Contains(s[0], substr) {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Errorf("status: expected %q, got %q", substr, ps.status())
+	}
+
+	ps.Add("fake", p)
+	waitFor(p.started, "start")
+	waitFor(p.polls, "poll")
+	expect("fake: next poll in ")
+	expect("failed 1 time(s) in a row, last error just now: oops")
+
+	if !ps.setStopped("fake", true) {
+		t.Fatalf("setStopped: fake not found")
+	}
+	waitFor(p.stopped, "stop")
+	expect("fake: stopped, last polled just now")
+	if conf.Ns(pollersNs).String("fake") == "" {
+		t.Errorf("setStopped: stopped state not saved")
+	}
+
+	p.err = nil
+	ps.setStopped("fake", false)
+	waitFor(p.started, "restart")
+	waitFor(p.polls, "second poll")
+	expect("fake: next poll in ")
+	if s := ps.status(); strings.Contains(s[0], "failed") {
+		t.Errorf("status: failures not reset: %q", s)
+	}
+
+	if ps.setStopped("nonexistent", true) {
+		t.Errorf("setStopped: found nonexistent poller")
+	}
+	ps.Handle(nil, &Line{Cmd: DISCONNECTED})
+	waitFor(p.stopped, "stop on disconnect")
+	expect("fake: waiting for a connection")
+}
+
+type slowPoller struct {
+	events  chan string
+	release chan bool
+}
+
+func (p *slowPoller) Poll([]*Context) error {
+	p.events <- "poll"
+	<-p.release
+	return nil
+}
+func (p *slowPoller) Start()              { p.events <- "start" }
+func (p *slowPoller) Stop()               { p.events <- "stop" }
+func (p *slowPoller) Tick() time.Duration { return time.Hour }
+
+func TestPollerRestart(t *testing.T) {
+	logging.InitFromFlags()
+	conf.UseInMem()
+	ps := newPollerSet()
+	ps.conns[nil] = &Context{}
+	p := &slowPoller{make(chan string, 10), make(chan bool)}
+	next := func() string {
+		t.Helper()
+		select {
+		case ev := <-p.events:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for poller")
+		}
+		return ""
+	}
+
+	ps.Add("slow", p)
+	if ev := next() + " " + next(); ev != "start poll" {
+		t.Fatalf("Add: exp start poll got %s", ev)
+	}
+	// Restarting while a poll is in progress shouldn't start a second
+	// run until the first has finished and stopped.
+	ps.setStopped("slow", true)
+	ps.setStopped("slow", false)
+	ps.setStopped("slow", true)
+	ps.setStopped("slow", false)
+	p.release <- true
+	var evs []string
+	for i := 0; i < 3; i++ {
+		evs = append(evs, next())
+	}
+	if s := strings.Join(evs, " "); s != "stop start poll" {
+		t.Errorf("restart: exp stop start poll got %s", s)
+	}
+	ps.setStopped("slow", true)
+	p.release <- true
+	if ev := next(); ev != "stop" {
+		t.Errorf("stop: exp stop got %s", ev)
+	}
+}
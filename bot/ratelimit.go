@@ -0,0 +1,265 @@
+package bot
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/util"
+)
+
+// Conf namespace for rate limits. Keys are a scope -- "nick", "chan" or
+// "cmd" -- optionally prefixed by the name of a limited thing, so "nick"
+// sets the default per-nick limit and "quote:nick" overrides it for
+// quotes. Values look like "4/1m", meaning a burst of 4 that refills at
+// a rate of 4 per minute, or "off".
+const rateLimitNs = "ratelimit"
+
+var limitScopes = []string{"nick", "chan", "cmd"}
+
+// Out of the box, each nick gets a burst of 4 uses of each limited thing,
+// refilled at one every 15 seconds, and each channel a burst of 10.
+var defaultLimits = map[string]*limitFlag{
+	"nick": newLimitFlag("ratelimit_nick", "4/1m"),
+	"chan": newLimitFlag("ratelimit_chan", "10/1m"),
+	"cmd":  newLimitFlag("ratelimit_cmd", "off"),
+}
+
+// limitFlag is a flag.Value that only accepts valid limits. It's locked
+// because it can be reloaded while commands are being limited.
+type limitFlag struct {
+	mu sync.Mutex
+	s  string
+}
+
+func newLimitFlag(name, def string) *limitFlag {
+	lf := &limitFlag{s: def}
+	flag.Var(lf, name, "Default rate limit for each "+name[len("ratelimit_"):]+
+		", as <burst>/<period> or off. Overridden by the ratelimit command.")
+	return lf
+}
+
+func (lf *limitFlag) String() string {
+	if lf == nil {
+		return ""
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.s
+}
+
+func (lf *limitFlag) Set(s string) error {
+	if _, err := parseLimit(s); err != nil {
+		return err
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	lf.s = s
+	return nil
+}
+
+type limit struct {
+	burst int
+	per   time.Duration
+}
+
+func parseLimit(s string) (limit, error) {
+	if s == "" || s == "off" {
+		return limit{}, nil
+	}
+	bp := strings.SplitN(s, "/", 2)
+	if len(bp) != 2 {
+		return limit{}, fmt.Errorf("'%s' doesn't look like <burst>/<period>", s)
+	}
+	burst, err := strconv.Atoi(bp[0])
+	if err != nil || burst <= 0 {
+		return limit{}, fmt.Errorf("'%s' isn't a positive burst size", bp[0])
+	}
+	per, err := util.ParseDuration(bp[1])
+	if err != nil || per <= 0 {
+		return limit{}, fmt.Errorf("'%s' isn't a positive duration", bp[1])
+	}
+	return limit{burst, per}, nil
+}
+
+func (l limit) off() bool {
+	return l.burst == 0
+}
+
+func (l limit) String() string {
+	if l.off() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.burst, l.per)
+}
+
+// A bucket holds tokens that are taken by each use and refilled over time.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	warned bool
+}
+
+// refill brings the bucket up to date, returning how long it will be
+// until the next token is available if the bucket is empty.
+func (b *bucket) refill(l limit, now time.Time) time.Duration {
+	rate := float64(l.burst) / float64(l.per)
+	if b.last.IsZero() {
+		b.tokens = float64(l.burst)
+	} else {
+		b.tokens += float64(now.Sub(b.last)) * rate
+	}
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / rate))
+}
+
+func (b *bucket) full(l limit, now time.Time) bool {
+	return b.refill(l, now) == 0 && b.tokens >= float64(l.burst)
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	limits  map[string]limit
+}
+
+var limiter = &rateLimiter{
+	buckets: make(map[string]*bucket),
+	limits:  make(map[string]limit),
+}
+
+// limitFor must be called with the lock held.
+func (rl *rateLimiter) limitFor(name, scope string) limit {
+	key := name + ":" + scope
+	if l, ok := rl.limits[key]; ok {
+		return l
+	}
+	s := conf.Ns(rateLimitNs).String(key)
+	if s == "" {
+		if s = conf.Ns(rateLimitNs).String(scope); s == "" {
+			s = defaultLimits[scope].String()
+		}
+	}
+	l, _ := parseLimit(s)
+	rl.limits[key] = l
+	return l
+}
+
+func (rl *rateLimiter) set(key string, l limit) {
+	rl.Lock()
+	defer rl.Unlock()
+	conf.Ns(rateLimitNs).String(key, l.String())
+	// Limits are cached per name, and changing a default could affect any.
+	rl.limits = make(map[string]limit)
+}
+
+// reset drops cached limits, e.g. when the default flags change.
+func (rl *rateLimiter) reset() {
+	rl.Lock()
+	defer rl.Unlock()
+	rl.limits = make(map[string]limit)
+}
+
+// take removes a token from each of the buckets for name, nick and channel.
+// If any bucket is empty, nothing is taken; take returns how long until the
+// next token is available and whether the caller should be warned about it.
+func (rl *rateLimiter) take(name, nick, ch string) (time.Duration, bool) {
+	rl.Lock()
+	defer rl.Unlock()
+	now := time.Now()
+	if len(rl.buckets) > 1000 {
+		rl.prune(now)
+	}
+	keys := map[string]string{
+		"nick": name + ":nick:" + strings.ToLower(nick),
+		"chan": name + ":chan:" + strings.ToLower(ch),
+		"cmd":  name + ":cmd",
+	}
+	if ch == "" {
+		delete(keys, "chan")
+	}
+	taken := make([]*bucket, 0, len(keys))
+	for scope, key := range keys {
+		l := rl.limitFor(name, scope)
+		if l.off() {
+			continue
+		}
+		b, ok := rl.buckets[key]
+		if !ok {
+			b = &bucket{}
+			rl.buckets[key] = b
+		}
+		if wait := b.refill(l, now); wait > 0 {
+			warn := !b.warned
+			b.warned = true
+			return wait, warn
+		}
+		taken = append(taken, b)
+	}
+	for _, b := range taken {
+		b.tokens--
+		b.warned = false
+	}
+	return 0, false
+}
+
+// prune drops full buckets, since they're equivalent to new ones.
+func (rl *rateLimiter) prune(now time.Time) {
+	for key, b := range rl.buckets {
+		f := strings.SplitN(key, ":", 3)
+		if b.full(rl.limitFor(f[0], f[1]), now) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+func (rl *rateLimiter) list() []string {
+	rl.Lock()
+	defer rl.Unlock()
+	set := make(map[string]string)
+	for scope, lf := range defaultLimits {
+		set[scope] = lf.String()
+	}
+	for _, e := range conf.Ns(rateLimitNs).All() {
+		set[e.Key], _ = e.Value.(string)
+	}
+	list := make([]string, 0, len(set))
+	for key, s := range set {
+		list = append(list, key+"="+s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Limit rate-limits a command, grouping it with others of the same name.
+func Limit(name string) CommandOpt {
+	return func(c *command) { c.limit = name }
+}
+
+// Limited returns true if the sender of the line has used the rate-limited
+// thing called name too much recently. Commands can opt in to this with
+// the Limit() option; handlers should call it before replying. The first
+// time someone hits a limit they're told to slow down, after that they
+// are ignored until the limit resets.
+func (ctx *Context) Limited(name string) bool {
+	wait, warn := limiter.take(name, ctx.Nick, ctx.channel())
+	if wait == 0 {
+		return false
+	}
+	if warn {
+		ctx.ReplyN("Slow down! Try again in %s.", wait.Round(time.Second))
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp limit
+		err bool
+	}{
+		{"4/1m", limit{4, time.Minute}, false},
+		{"10/1h30m", limit{10, 90 * time.Minute}, false},
+		{"2/1d", limit{2, 24 * time.Hour}, false},
+		{"off", limit{}, false},
+		{"", limit{}, false},
+		{"4", limit{}, true},
+		{"0/1m", limit{}, true},
+		{"-1/1m", limit{}, true},
+		{"4/fish", limit{}, true},
+		{"4/-1m", limit{}, true},
+	}
+	for i, test := range tests {
+		l, err := parseLimit(test.in)
+		if (err != nil) != test.err || l != test.exp {
+			t.Errorf("parseLimit(%d) %q: exp %v (err %t) got %v (%v)",
+				i, test.in, test.exp, test.err, l, err)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	l := limit{4, time.Minute}
+	b := &bucket{}
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if wait := b.refill(l, now); wait != 0 {
+			t.Fatalf("take %d: exp no wait, got %s", i, wait)
+		}
+		b.tokens--
+	}
+	if wait := b.refill(l, now); wait != 15*time.Second {
+		t.Errorf("empty bucket: exp 15s wait, got %s", wait)
+	}
+	if wait := b.refill(l, now.Add(10*time.Second)); wait != 5*time.Second {
+		t.Errorf("after 10s: exp 5s wait, got %s", wait)
+	}
+	if wait := b.refill(l, now.Add(15*time.Second)); wait != 0 {
+		t.Errorf("after 15s: exp no wait, got %s", wait)
+	}
+	if b.full(l, now.Add(30*time.Second)) {
+		t.Errorf("after 30s: bucket should not yet be full")
+	}
+	if !b.full(l, now.Add(time.Hour)) {
+		t.Errorf("after 1h: bucket should be full")
+	}
+}
@@ -16,6 +16,12 @@ func (rwf RewriteFunc) Rewrite(in string, ctx *Context) string {
 	return rwf(in, ctx)
 }
 
+// namedRewriter is a Rewriter and the names policies know it by.
+type namedRewriter struct {
+	Rewriter
+	names []string
+}
+
 type RewriteSet interface {
 	Rewriter
 	Add(Rewriter)
@@ -40,6 +46,9 @@ func (rws *rewriteSet) Rewrite(in string, ctx *Context) string {
 	rws.RLock()
 	defer rws.RUnlock()
 	for _, rw := range rws.set {
+		if nr, ok := rw.(namedRewriter); ok && !policies.Permits(ctx, nr.names) {
+			continue
+		}
 		in = rw.Rewrite(in, ctx)
 	}
 	return in
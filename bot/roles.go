@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+var owners *string = flag.String("owner", "",
+	"Comma-separated nick!ident@host masks that hold the owner role. "+
+		"Masks must name an ident or host, since anyone can take a nick.")
+
+// Conf namespace for role grants, keyed by mask.
+const rolesNs = "roles"
+
+// A Role is a level of privilege. Higher roles can do everything
+// lower roles can, and each command can require a minimum role.
+type Role int
+
+const (
+	User Role = iota
+	Trusted
+	Admin
+	Owner
+)
+
+var roleNames = []string{"user", "trusted", "admin", "owner"}
+
+func (r Role) String() string {
+	if r < User || r > Owner {
+		return "unknown"
+	}
+	return roleNames[r]
+}
+
+// RoleForName returns the named role, or false if there isn't one.
+func RoleForName(name string) (Role, bool) {
+	name = strings.ToLower(name)
+	for i, n := range roleNames {
+		if n == name {
+			return Role(i), true
+		}
+	}
+	return User, false
+}
+
+// A Grant associates a mask with a role.
+type Grant struct {
+	Mask string
+	Role Role
+}
+
+// Grants returns all the stored role grants.
+func Grants() []Grant {
+	grants := []Grant{}
+	for _, e := range conf.Ns(rolesNs).All() {
+		name, _ := e.Value.(string)
+		if r, ok := RoleForName(name); ok {
+			grants = append(grants, Grant{e.Key, r})
+		}
+	}
+	return grants
+}
+
+// ownerMasks returns the masks given with --owner, leaving out any that
+// match every ident and host.
+func ownerMasks() []string {
+	var masks []string
+	for _, mask := range strings.Split(*owners, ",") {
+		if mask = strings.TrimSpace(mask); mask == "" {
+			continue
+		}
+		if !AnyHost(mask) {
+			masks = append(masks, mask)
+		}
+	}
+	return masks
+}
+
+// checkOwners warns about owner settings that won't work.
+func checkOwners() {
+	for _, mask := range strings.Split(*owners, ",") {
+		if mask = strings.TrimSpace(mask); mask != "" && AnyHost(mask) {
+			logging.Warn("Ignoring --owner mask %q: anyone using the nick "+
+				"would match it. Give an ident or host too.", mask)
+		}
+	}
+	if *rebuilder != "" {
+		logging.Warn("--rebuilder is deprecated, use --owner instead.")
+	}
+}
+
+// RoleFor returns the highest role held by src, a nick!ident@host.
+// Grants for masks matching any ident and host are ignored.
+func RoleFor(src string) Role {
+	if src == "" {
+		return User
+	}
+	for _, mask := range ownerMasks() {
+		if MatchMask(mask, src) {
+			return Owner
+		}
+	}
+	role := User
+	for _, g := range Grants() {
+		if AnyHost(g.Mask) {
+			continue
+		}
+		if g.Role > role && MatchMask(g.Mask, src) {
+			role = g.Role
+		}
+	}
+	return role
+}
+
+func grantRole(mask string, r Role) {
+	conf.Ns(rolesNs).String(NormalizeMask(mask), r.String())
+}
+
+func revokeRole(mask string) (Role, bool) {
+	mask = NormalizeMask(mask)
+	r, ok := RoleForName(conf.Ns(rolesNs).String(mask))
+	if ok {
+		conf.Ns(rolesNs).Delete(mask)
+	}
+	return r, ok
+}
@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/schedules"
+	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// If the schedules can't be loaded, try again after this long.
+const scheduleRetry = time.Minute
+
+// Interval names that can follow "every", with or without a number.
+var scheduleUnits = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+}
+
+// parseDays parses "day", "weekday", "weekend" or the names of days of
+// the week, separated by commas or "and", into a schedules.Schedule.Days
+// bitmask.
+func parseDays(words []string) (int, error) {
+	days := 0
+	for _, w := range words {
+		w = strings.TrimSuffix(strings.ToLower(strings.TrimSuffix(w, ",")), "s")
+		switch w {
+		case "", "and":
+			continue
+		case "day":
+			days |= 0x7f
+			continue
+		case "weekday":
+			days |= 0x3e
+			continue
+		case "weekend":
+			days |= 0x41
+			continue
+		}
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			name := strings.ToLower(d.String())
+			if w == name || w == name[:3] {
+				days |= 1 << d
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("'%s' isn't a day I know.", w)
+		}
+	}
+	if days == 0 {
+		return 0, fmt.Errorf("Which days?")
+	}
+	return days, nil
+}
+
+// parseWhen parses what follows "every": an interval like "hour", "30m"
+// or "2 hours", or days and an optional time of day in zone, like
+// "weekday at 9am". It fills in the timing fields of s.
+func parseWhen(words []string, zone *time.Location, s *schedules.Schedule) error {
+	if len(words) == 0 {
+		return fmt.Errorf("Every when?")
+	}
+	unit := strings.TrimSuffix(strings.ToLower(words[len(words)-1]), "s")
+	switch {
+	case len(words) == 1 && scheduleUnits[unit] > 0:
+		s.Every = scheduleUnits[unit]
+	case len(words) == 2 && scheduleUnits[unit] > 0:
+		n, err := strconv.Atoi(words[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("'%s' isn't a number of %ss.", words[0], unit)
+		}
+		s.Every = time.Duration(n) * scheduleUnits[unit]
+	case len(words) == 1 && words[0][0] >= '0' && words[0][0] <= '9':
+		d, err := util.ParseDuration(words[0])
+		if err != nil {
+			return fmt.Errorf("Couldn't parse interval '%s'.", words[0])
+		}
+		s.Every = d
+	default:
+		at := len(words)
+		for i, w := range words {
+			if strings.ToLower(w) == "at" {
+				at = i
+				break
+			}
+		}
+		days, err := parseDays(words[:at])
+		if err != nil {
+			return err
+		}
+		s.Days, s.Zone = days, zone.String()
+		if at < len(words) {
+			t, err := datetime.ParseZ(strings.Join(words[at+1:], " "), zone)
+			if err != nil {
+				return fmt.Errorf("Couldn't parse time '%s'.",
+					strings.Join(words[at+1:], " "))
+			}
+			s.At = time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second
+			s.Zone = t.Location().String()
+		}
+		return nil
+	}
+	if s.Every < schedules.MinInterval {
+		return fmt.Errorf("Schedules can't run more often than every %s.",
+			schedules.MinInterval)
+	}
+	return nil
+}
+
+// parseSchedule parses "say <text> [in <#chan>] every <when> [catch up]"
+// or "run <command> every <when> [catch up]", with times in zone.
+// If no channel is given, Chan is left empty.
+func parseSchedule(txt string, zone *time.Location) (*schedules.Schedule, error) {
+	words := strings.Fields(txt)
+	every := -1
+	for i, w := range words {
+		if strings.ToLower(w) == "every" {
+			every = i
+		}
+	}
+	if len(words) == 0 || every == -1 {
+		return nil, fmt.Errorf("Usage: schedule say <text> [in <#chan>] | " +
+			"run <command> every <when> [catch up]")
+	}
+	s := &schedules.Schedule{Action: strings.ToLower(words[0])}
+	what, when := words[1:every], words[every:]
+	switch s.Action {
+	case schedules.Say:
+		if n := len(what); n > 2 && strings.ToLower(what[n-2]) == "in" && isChannel(what[n-1]) {
+			s.Chan, what = what[n-1], what[:n-2]
+		}
+	case schedules.Run:
+	default:
+		return nil, fmt.Errorf("I can only schedule 'say' or 'run', not '%s'.", words[0])
+	}
+	if len(what) == 0 {
+		return nil, fmt.Errorf("What should I %s?", s.Action)
+	}
+	s.Text = strings.Join(what, " ")
+	if n := len(when); n > 2 && strings.ToLower(strings.Join(when[n-2:], " ")) == "catch up" {
+		s.CatchUp, when = true, when[:n-2]
+	}
+	if err := parseWhen(when[1:], zone, s); err != nil {
+		return nil, err
+	}
+	s.When = strings.Join(when, " ")
+	return s, nil
+}
+
+// scheduler runs schedules when they're due, while the bot is connected.
+type scheduler struct {
+	sync.Mutex
+	running bool
+	// Poked when schedules change or a server connects, so the scheduler
+	// works out when it next needs to wake up.
+	wake chan struct{}
+}
+
+var sched = &scheduler{wake: make(chan struct{}, 1)}
+
+func (sc *scheduler) poke() {
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Handle starts the scheduler when the bot first connects, since the
+// database isn't ready when Init() is called.
+func (sc *scheduler) Handle(t Transport, line *Line) {
+	sc.Lock()
+	defer sc.Unlock()
+	if !sc.running {
+		sc.running = true
+		go sc.loop(bot.ctx)
+	}
+	sc.poke()
+}
+
+func (sc *scheduler) loop(ctx context.Context) {
+	for {
+		// The poller set keeps track of which servers we're connected to.
+		next := runSchedules(bot.pollers.(*pollerSet).contexts(), time.Now())
+		var t *time.Timer
+		var wait <-chan time.Time
+		if !next.IsZero() {
+			t = time.NewTimer(time.Until(next))
+			wait = t.C
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-sc.wake:
+		case <-wait:
+		}
+		if t != nil {
+			t.Stop()
+		}
+	}
+}
+
+// runSchedules runs the schedules due at now, using ctxs to reach the
+// servers we're connected to, and returns when it next needs to be called.
+// Schedules for servers we aren't connected to are left alone; missed
+// runs are dealt with once the scheduler is poked on connecting.
+func runSchedules(ctxs []*Context, now time.Time) time.Time {
+	if len(ctxs) == 0 {
+		return time.Time{}
+	}
+	all, err := schedules.All(bot.ctx)
+	if err != nil {
+		logging.Error("Loading schedules: %v", err)
+		return now.Add(scheduleRetry)
+	}
+	var next time.Time
+	for _, s := range all {
+		base := serverContext(s.Server, ctxs)
+		if base == nil {
+			// Leave it until its server connects and pokes the scheduler.
+			continue
+		}
+		prev := s.Next
+		if s.Advance(now) {
+			runSchedule(s, base)
+		}
+		if s.Next != prev {
+			// Put would bring back a schedule deleted while it ran.
+			if ok, err := schedules.Update(bot.ctx, s); err != nil {
+				logging.Error("Saving schedule %s: %v", s.Id().Hex(), err)
+			} else if !ok {
+				continue
+			}
+		}
+		if !s.Next.IsZero() && (next.IsZero() || s.Next.Before(next)) {
+			next = s.Next
+		}
+	}
+	return next
+}
+
+// serverContext returns the context for the named server from ctxs,
+// or nil if we're not connected to it.
+func serverContext(server string, ctxs []*Context) *Context {
+	for _, ctx := range ctxs {
+		if ctx.conn.Name() == server {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// runSchedule does what s says on the server it was created on, which
+// base is connected to.
+func runSchedule(s *schedules.Schedule, base *Context) {
+	logging.Info("Running schedule %s: %s", s.Id().Hex(), s)
+	switch s.Action {
+	case schedules.Say:
+		base.Privmsg(s.Chan, s.Text)
+	case schedules.Run:
+		// Commands run as if their creator said them in the channel,
+		// so they're subject to the same roles, ignores and policies.
+		line := &Line{Nick: s.Nick, Src: s.Src, Cmd: PRIVMSG,
+			Args: []string{s.Chan, s.Text}, Time: time.Now()}
+		if i := strings.IndexByte(s.Src, '!'); i != -1 {
+			line.Ident, line.Host, _ = strings.Cut(s.Src[i+1:], "@")
+		}
+		ctx := reqContext(base.conn, line)
+		if ctx == nil {
+			return
+		}
+		ctx.out, ctx.Addressed = base.out, true
+		bot.commands.Dispatch(ctx)
+	}
+}
+
+// RunSchedules runs any schedules due at now, using ctx's connection,
+// as the scheduler would. It's for tests; see bot/bottest.
+func RunSchedules(ctx *Context, now time.Time) {
+	runSchedules([]*Context{ctx}, now)
+}
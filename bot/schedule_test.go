@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/collections/schedules"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp *schedules.Schedule
+		err string
+	}{
+		{"say hi every hour", &schedules.Schedule{Action: "say", Text: "hi",
+			When: "every hour", Every: time.Hour}, ""},
+		{"say good morning in #chan every weekday at 9am",
+			&schedules.Schedule{Action: "say", Text: "good morning",
+				Chan: "#chan", When: "every weekday at 9am", Days: 0x3e,
+				At: 9 * time.Hour, Zone: "UTC"}, ""},
+		{"say in #chan every day at 17:30 catch up",
+			&schedules.Schedule{Action: "say", Text: "in #chan",
+				When: "every day at 17:30", Days: 0x7f,
+				At: 17*time.Hour + 30*time.Minute, Zone: "UTC",
+				CatchUp: true}, ""},
+		{"run seen every 2 hours", &schedules.Schedule{Action: "run",
+			Text: "seen", When: "every 2 hours", Every: 2 * time.Hour}, ""},
+		// Only the last "every" starts the schedule.
+		{"run say every time every 90m", &schedules.Schedule{Action: "run",
+			Text: "say every time", When: "every 90m", Every: 90 * time.Minute}, ""},
+		{"say hi every mon, wed and Fridays", &schedules.Schedule{
+			Action: "say", Text: "hi", When: "every mon, wed and Fridays",
+			Days: 0x2a, Zone: "UTC"}, ""},
+		{"say hi", nil, "Usage: schedule say <text> [in <#chan>] | " +
+			"run <command> every <when> [catch up]"},
+		{"shout hi every hour", nil, "I can only schedule 'say' or 'run', not 'shout'."},
+		{"say every hour", nil, "What should I say?"},
+		{"say hi every", nil, "Every when?"},
+		{"say hi every 30s", nil, "Schedules can't run more often than every 1m0s."},
+		{"say hi every many hours", nil, "'many' isn't a number of hours."},
+		{"say hi every blursday", nil, "'blursday' isn't a day I know."},
+		{"say hi every day at teatime", nil, "Couldn't parse time 'teatime'."},
+	}
+	for _, test := range tests {
+		s, err := parseSchedule(test.in, time.UTC)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseSchedule(%q): exp error %q got %v", test.in, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSchedule(%q): unexpected error %v", test.in, err)
+			continue
+		}
+		if *s != *test.exp {
+			t.Errorf("parseSchedule(%q):\nexp %#v\ngot %#v", test.in, test.exp, s)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/util/config"
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+var (
+	sendBurst = config.Int("send_burst", 4,
+		"Lines that can be sent to one target before output is paced.").AtLeast(1)
+	sendDelay = config.Duration("send_delay", 2*time.Second,
+		"Delay between lines sent to one target once a burst is used up.").AtLeast(time.Millisecond)
+	sendConnBurst = config.Int("send_conn_burst", 10,
+		"Lines that can be sent to a server before output is paced.").AtLeast(1)
+	sendConnDelay = config.Duration("send_conn_delay", time.Second,
+		"Delay between lines sent to a server once a burst is used up.").AtLeast(time.Millisecond)
+	sendQueueLen = config.Int("send_queue", 50,
+		"Maximum lines queued for one target; any more are dropped.")
+)
+
+// Queue depths are exported per server on /debug/vars and /metrics.
+var (
+	sendQueueDepth = expvar.NewMap("send_queue_depth")
+	sendQueueGauge = metrics.NewGauge("sp0rkle_send_queue_depth",
+		"Lines waiting to be sent, by server.", "server")
+)
+
+type outLine struct {
+	cmd, target, text string
+}
+
+type targetQueue struct {
+	lines []outLine
+	b     bucket
+}
+
+// A sendQueue paces output to each target on a connection, so that long
+// or repetitive replies don't get the bot killed for flooding. Every
+// target also drains a bucket for the whole connection, so replying once
+// each to lots of targets doesn't flood it either.
+type sendQueue struct {
+	sync.Mutex
+	conn    Transport
+	server  string
+	targets map[string]*targetQueue
+	b       bucket
+	wake    chan struct{}
+}
+
+var sendQueues = struct {
+	sync.Mutex
+	m map[Transport]*sendQueue
+}{m: make(map[Transport]*sendQueue)}
+
+// queueFor returns the send queue for conn, creating it if necessary.
+func queueFor(conn Transport) *sendQueue {
+	sendQueues.Lock()
+	defer sendQueues.Unlock()
+	sq, ok := sendQueues.m[conn]
+	if !ok {
+		sq = newSendQueue(conn)
+		sendQueues.m[conn] = sq
+		go sq.loop()
+	}
+	return sq
+}
+
+func (sq *sendQueue) addDepth(n int) {
+	sendQueueDepth.Add(sq.server, int64(n))
+	sendQueueGauge.Add(float64(n), sq.server)
+}
+
+func newSendQueue(conn Transport) *sendQueue {
+	sq := &sendQueue{
+		conn:    conn,
+		targets: make(map[string]*targetQueue),
+		wake:    make(chan struct{}, 1),
+	}
+	if conn != nil {
+		sq.server = conn.Name()
+	}
+	return sq
+}
+
+func (sq *sendQueue) limit() limit {
+	burst := sendBurst.Get()
+	return limit{burst, time.Duration(burst) * sendDelay.Get()}
+}
+
+func (sq *sendQueue) connLimit() limit {
+	burst := sendConnBurst.Get()
+	return limit{burst, time.Duration(burst) * sendConnDelay.Get()}
+}
+
+// splitText splits text into chunks of at most max bytes, breaking at
+// the last space in each chunk where possible and never inside a rune.
+func splitText(text string, max int) []string {
+	var out []string
+	for len(text) > max {
+		cut := max
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if sp := strings.LastIndex(text[:cut], " "); sp > 0 {
+			cut = sp
+		} else if cut == 0 {
+			cut = max
+		}
+		out = append(out, text[:cut])
+		text = strings.TrimLeft(text[cut:], " ")
+	}
+	return append(out, text)
+}
+
+// send splits text and queues it for target.
+func (sq *sendQueue) send(cmd, target, text string) {
+	for _, s := range splitText(text, sq.conn.Room(cmd, target)) {
+		sq.push(outLine{cmd, target, s})
+	}
+	select {
+	case sq.wake <- struct{}{}:
+	default:
+	}
+}
+
+// push queues a line unless an identical one is already waiting to be sent
+// or the target's queue is full, and returns whether it was queued.
+func (sq *sendQueue) push(l outLine) bool {
+	sq.Lock()
+	defer sq.Unlock()
+	key := strings.ToLower(l.target)
+	tq, ok := sq.targets[key]
+	if !ok {
+		tq = &targetQueue{}
+		sq.targets[key] = tq
+	}
+	for _, q := range tq.lines {
+		if q == l {
+			return false
+		}
+	}
+	if len(tq.lines) >= sendQueueLen.Get() {
+		logging.Warn("Send queue for %s full, dropping %q.", l.target, l.text)
+		return false
+	}
+	tq.lines = append(tq.lines, l)
+	sq.addDepth(1)
+	return true
+}
+
+// next returns a line that may be sent now, or if there are none,
+// how long until there might be. A zero wait means the queue is empty.
+func (sq *sendQueue) next(now time.Time) (*outLine, time.Duration) {
+	sq.Lock()
+	defer sq.Unlock()
+	lim := sq.limit()
+	connWait := sq.b.refill(sq.connLimit(), now)
+	var wait time.Duration
+	for key, tq := range sq.targets {
+		if len(tq.lines) == 0 {
+			if tq.b.full(lim, now) {
+				delete(sq.targets, key)
+			}
+			continue
+		}
+		w := tq.b.refill(lim, now)
+		if w == 0 {
+			w = connWait
+		}
+		if w > 0 {
+			if wait == 0 || w < wait {
+				wait = w
+			}
+			continue
+		}
+		tq.b.tokens--
+		sq.b.tokens--
+		l := tq.lines[0]
+		tq.lines = tq.lines[1:]
+		sq.addDepth(-1)
+		return &l, 0
+	}
+	if wait == 0 {
+		for _, tq := range sq.targets {
+			if len(tq.lines) == 0 && !tq.b.full(lim, now) {
+				// Wake up later to clean up this target.
+				wait = lim.per
+			}
+		}
+	}
+	return nil, wait
+}
+
+// drop discards everything queued, e.g. when disconnected.
+func (sq *sendQueue) drop() {
+	sq.Lock()
+	defer sq.Unlock()
+	for _, tq := range sq.targets {
+		sq.addDepth(-len(tq.lines))
+	}
+	sq.targets = make(map[string]*targetQueue)
+}
+
+func (sq *sendQueue) loop() {
+	for {
+		l, wait := sq.next(time.Now())
+		if l != nil {
+			sq.write(l)
+			continue
+		}
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-sq.wake:
+		case <-timer:
+		}
+	}
+}
+
+func (sq *sendQueue) write(l *outLine) {
+	if !sq.conn.Connected() {
+		sq.drop()
+		return
+	}
+	sq.conn.Send(l.cmd, l.target, l.text)
+}
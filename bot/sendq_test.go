@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		in  string
+		max int
+		out []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"exactly ten", 11, []string{"exactly ten"}},
+		{"split on word boundary", 10, []string{"split on", "word", "boundary"}},
+		{"unbreakableword", 5, []string{"unbre", "akabl", "eword"}},
+		{"héllo wörld", 6, []string{"héllo", "wörld"}},
+		{"ééééé", 3, []string{"é", "é", "é", "é", "é"}},
+	}
+	for i, test := range tests {
+		if out := splitText(test.in, test.max); !reflect.DeepEqual(out, test.out) {
+			t.Errorf("splitText(%d) %q: exp %q got %q", i, test.in, test.out, out)
+		}
+		for _, s := range splitText(test.in, test.max) {
+			if len(s) > test.max {
+				t.Errorf("splitText(%d) %q: chunk %q longer than %d",
+					i, test.in, s, test.max)
+			}
+		}
+	}
+}
+
+func TestSendQueuePacing(t *testing.T) {
+	oldBurst, oldDelay := sendBurst.String(), sendDelay.String()
+	defer func() { sendBurst.Set(oldBurst); sendDelay.Set(oldDelay) }()
+	sendBurst.Set("2")
+	sendDelay.Set("1s")
+
+	sq := newSendQueue(nil)
+	for _, s := range []string{"one", "two", "two", "three"} {
+		sq.push(outLine{PRIVMSG, "#chan", s})
+	}
+	sq.push(outLine{PRIVMSG, "nick", "other"})
+
+	now := time.Now()
+	sent := []string{}
+	for {
+		l, _ := sq.next(now)
+		if l == nil {
+			break
+		}
+		sent = append(sent, l.target+" "+l.text)
+	}
+	// Duplicates collapse and #chan's burst is used up, but nick's is not.
+	if len(sent) != 3 || !strings.Contains(strings.Join(sent, ","), "nick other") {
+		t.Errorf("first burst: got %q", sent)
+	}
+	if l, wait := sq.next(now); l != nil || wait != time.Second {
+		t.Errorf("after burst: exp 1s wait got %v, %s", l, wait)
+	}
+	if l, _ := sq.next(now.Add(time.Second)); l == nil || l.text != "three" {
+		t.Errorf("after 1s: exp 'three' got %v", l)
+	}
+	// Once sent, a line can be queued again.
+	if !sq.push(outLine{PRIVMSG, "#chan", "two"}) {
+		t.Errorf("push: expected 'two' to be queued again")
+	}
+	sq.drop()
+	if l, _ := sq.next(now.Add(time.Hour)); l != nil {
+		t.Errorf("after drop: exp nothing got %v", l)
+	}
+}
+
+func TestSendQueueConnPacing(t *testing.T) {
+	oldBurst, oldDelay := sendConnBurst.String(), sendConnDelay.String()
+	defer func() { sendConnBurst.Set(oldBurst); sendConnDelay.Set(oldDelay) }()
+	sendConnBurst.Set("3")
+	sendConnDelay.Set("1s")
+
+	// One line each to lots of targets still uses up the connection's burst.
+	sq := newSendQueue(nil)
+	for _, nick := range []string{"a", "b", "c", "d", "e"} {
+		sq.push(outLine{PRIVMSG, nick, "hi"})
+	}
+	now := time.Now()
+	sent := 0
+	for {
+		l, _ := sq.next(now)
+		if l == nil {
+			break
+		}
+		sent++
+	}
+	if sent != 3 {
+		t.Errorf("first burst: exp 3 lines got %d", sent)
+	}
+	if l, wait := sq.next(now); l != nil || wait != time.Second {
+		t.Errorf("after burst: exp 1s wait got %v, %s", l, wait)
+	}
+	if l, _ := sq.next(now.Add(time.Second)); l == nil {
+		t.Errorf("after 1s: exp a line")
+	}
+	if l, _ := sq.next(now.Add(time.Second)); l != nil {
+		t.Errorf("after 1s: exp only one line got %v", l)
+	}
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluffle/sp0rkle/util/config"
+)
+
+// The "server_config" section of the --config file holds a list of per-server
+// configs. If it's there, --servers, --ssl, --channels, --oper and
+// --vhost are ignored.
+const serversSection = "server_config"
+
+func init() {
+	config.AddSection(serversSection)
+}
+
+// A ServerConfig describes how to connect to one chat network. Nick,
+// Transport and Pause default to the --nick, --transport and --pause
+// flags. Pass, Oper and VHost may be given as $ENV_VAR or <file_path to
+// a secret.
+type ServerConfig struct {
+	// Server is host:port, or "stdio" for the line transport. It also
+	// names the server in logs and conf.
+	Server   string   `json:"server"`
+	Nick     string   `json:"nick,omitempty"`
+	AltNicks []string `json:"alt_nicks,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+
+	// Transport is "irc" or "line"; see transport.go.
+	Transport string `json:"transport,omitempty"`
+
+	SSL bool `json:"ssl,omitempty"`
+	// Defaults to the host part of Server.
+	SSLServerName string `json:"ssl_server_name,omitempty"`
+	SSLInsecure   bool   `json:"ssl_insecure,omitempty"`
+
+	Pass string `json:"pass,omitempty"`
+	// user:password for the OPER and VHOST commands on connect.
+	Oper  string `json:"oper,omitempty"`
+	VHost string `json:"vhost,omitempty"`
+
+	// Wait time between reconnection attempts, e.g. "5m".
+	Pause string `json:"pause,omitempty"`
+
+	pause time.Duration
+}
+
+// flagServerConfigs builds configs from the command-line flags.
+func flagServerConfigs() []*ServerConfig {
+	var chans []string
+	for _, c := range strings.Split(channels.Get(), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			chans = append(chans, c)
+		}
+	}
+	var scs []*ServerConfig
+	for _, hostport := range strings.Split(*servers, ",") {
+		if hostport = strings.TrimSpace(hostport); hostport == "" {
+			continue
+		}
+		scs = append(scs, &ServerConfig{
+			Server:   hostport,
+			Channels: chans,
+			SSL:      *ssl,
+			Oper:     *oper,
+			VHost:    *vhost,
+		})
+	}
+	return scs
+}
+
+func parseServerConfigs(data []byte) ([]*ServerConfig, error) {
+	var scs []*ServerConfig
+	if err := json.Unmarshal(data, &scs); err != nil {
+		return nil, err
+	}
+	return scs, nil
+}
+
+// loadServerConfigs reads the server_config section of the config file,
+// or falls back to flags.
+func loadServerConfigs() ([]*ServerConfig, error) {
+	scs := flagServerConfigs()
+	if data := config.Section(serversSection); data != nil {
+		var err error
+		if scs, err = parseServerConfigs(data); err != nil {
+			return nil, fmt.Errorf("config %s: %v", serversSection, err)
+		}
+	}
+	if len(scs) == 0 {
+		return nil, fmt.Errorf("no servers configured")
+	}
+	seen := make(map[string]bool)
+	for i, sc := range scs {
+		if err := sc.validate(); err != nil {
+			return nil, fmt.Errorf("server %d: %v", i, err)
+		}
+		if seen[sc.Server] {
+			return nil, fmt.Errorf("server %q configured twice", sc.Server)
+		}
+		seen[sc.Server] = true
+	}
+	return scs, nil
+}
+
+// validate checks sc and fills in defaults.
+func (sc *ServerConfig) validate() error {
+	if sc.Server == "" {
+		return fmt.Errorf("no server host:port given")
+	}
+	if sc.Nick == "" {
+		sc.Nick = *nick
+	}
+	if sc.Transport == "" {
+		sc.Transport = *transport
+	}
+	if transports[sc.Transport] == nil {
+		return fmt.Errorf("unknown transport %q, expected one of %s",
+			sc.Transport, strings.Join(transportNames(), ", "))
+	}
+	for _, c := range sc.Channels {
+		if !isChannel(c) {
+			return fmt.Errorf("%q doesn't look like a channel", c)
+		}
+	}
+	for _, up := range []string{sc.Oper, sc.VHost} {
+		if up != "" && !strings.HasPrefix(up, "$") && !strings.HasPrefix(up, "<") &&
+			!strings.Contains(up, ":") {
+			return fmt.Errorf("oper and vhost should look like user:password")
+		}
+	}
+	sc.pause = *pause
+	if sc.Pause != "" {
+		d, err := time.ParseDuration(sc.Pause)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("bad reconnect pause %q", sc.Pause)
+		}
+		sc.pause = d
+	}
+	return nil
+}
+
+// newNick picks the next alternative nick when ours is in use,
+// falling back to appending an underscore.
+func (sc *ServerConfig) newNick(old string) string {
+	nicks := append([]string{sc.Nick}, sc.AltNicks...)
+	for i, n := range nicks[:len(nicks)-1] {
+		if strings.EqualFold(n, old) {
+			return nicks[i+1]
+		}
+	}
+	return old + "_"
+}
+
+// userPass splits a "user:password" secret.
+func userPass(secret string) (string, string, bool) {
+	up := strings.SplitN(GetSecret(secret), ":", 2)
+	if len(up) != 2 {
+		return "", "", false
+	}
+	return up[0], up[1], true
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseServerConfigs(t *testing.T) {
+	data := []byte(`[
+		{"server": "irc.pl0rt.org:6697", "nick": "sp0rkle",
+		 "alt_nicks": ["sp0rkle_", "sp0rklf"], "channels": ["#sp0rklf"],
+		 "ssl": true, "oper": "$OPER", "pause": "1m"},
+		{"server": "irc.example.net:6667", "channels": ["#a", "#b"]},
+		{"server": "stdio", "transport": "line"}
+	]`)
+	scs, err := parseServerConfigs(data)
+	if err != nil || len(scs) != 3 {
+		t.Fatalf("parseServerConfigs: got %d configs, err %v", len(scs), err)
+	}
+	for i, sc := range scs {
+		if err := sc.validate(); err != nil {
+			t.Errorf("validate(%d): unexpected error %v", i, err)
+		}
+	}
+	if scs[0].pause != time.Minute || scs[1].pause != *pause {
+		t.Errorf("validate: pause not defaulted correctly: %s, %s",
+			scs[0].pause, scs[1].pause)
+	}
+	if scs[1].Nick != *nick {
+		t.Errorf("validate: exp nick %q got %q", *nick, scs[1].Nick)
+	}
+	if scs[1].Transport != "irc" || scs[2].Transport != "line" {
+		t.Errorf("validate: transports not set correctly: %q, %q",
+			scs[1].Transport, scs[2].Transport)
+	}
+	cfg := scs[0].clientConfig()
+	if !cfg.SSL || cfg.SSLConfig.ServerName != "irc.pl0rt.org" {
+		t.Errorf("clientConfig: expected SSL with server name irc.pl0rt.org")
+	}
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	bad := []*ServerConfig{
+		{},
+		{Server: "irc:6667", Channels: []string{"nochan"}},
+		{Server: "irc:6667", Oper: "nopassword"},
+		{Server: "irc:6667", Pause: "soon"},
+		{Server: "irc:6667", Transport: "carrier pigeon"},
+	}
+	for i, sc := range bad {
+		if err := sc.validate(); err == nil {
+			t.Errorf("validate(%d): expected error for %#v", i, sc)
+		}
+	}
+}
+
+func TestServerConfigNewNick(t *testing.T) {
+	sc := &ServerConfig{Nick: "sp0rkle", AltNicks: []string{"sp0rklf", "sp0rk"}}
+	tests := [][2]string{
+		{"sp0rkle", "sp0rklf"},
+		{"SP0RKLF", "sp0rk"},
+		{"sp0rk", "sp0rk_"},
+		{"sp0rk_", "sp0rk__"},
+	}
+	for i, test := range tests {
+		if n := sc.newNick(test[0]); n != test[1] {
+			t.Errorf("newNick(%d) %q: exp %q got %q", i, test[0], test[1], n)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package bot
 
 import (
-	"crypto/tls"
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -10,8 +10,8 @@ import (
 	"sync"
 	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/util/metrics"
 )
 
 var (
@@ -25,18 +25,63 @@ var (
 		"Wait time between server reconnection attempts.")
 )
 
+var (
+	ircReconnects = metrics.NewCounter("sp0rkle_irc_reconnects_total",
+		"Attempts to reconnect to a server after the first.", "server")
+	ircConnectErrors = metrics.NewCounter("sp0rkle_irc_connect_errors_total",
+		"Failed attempts to connect to a server.", "server")
+	panics = metrics.NewCounter("sp0rkle_panics_total",
+		"Panics recovered in handlers.")
+)
+
 type server struct {
-	*client.Conn
+	Transport
+	cfg      *ServerConfig
 	hostport string
 	shutdown bool
 
 	wg   *sync.WaitGroup
 	wait chan struct{}
+
+	// ctx is cancelled when we disconnect from the server.
+	mu     sync.Mutex
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// context returns a context.Context that is cancelled when the
+// server disconnects or the bot shuts down.
+func (s *server) context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(s.parent)
+	}
+	return s.ctx
+}
+
+func (s *server) pause() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.pause
+}
+
+func (s *server) cancelContext() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.ctx, s.cancel = nil, nil
 }
 
 func (s *server) connectLoop() {
-	for {
+	for first := true; ; first = false {
 		logging.Info("Connecting to %s.", s.hostport)
+		if !first {
+			ircReconnects.Inc(s.hostport)
+		}
 		if err := s.Connect(); err == nil {
 			// Wait here for a disconnect signal
 			<-s.wait
@@ -45,6 +90,7 @@ func (s *server) connectLoop() {
 			}
 		} else {
 			logging.Error("Connection error: %s", err)
+			ircConnectErrors.Inc(s.hostport)
 			select {
 			case <-s.wait:
 				// If we are waiting for a reconnect to this server
@@ -52,7 +98,7 @@ func (s *server) connectLoop() {
 				if s.shutdown {
 					break
 				}
-			case <-time.After(*pause):
+			case <-time.After(s.pause()):
 			}
 		}
 	}
@@ -61,54 +107,52 @@ func (s *server) connectLoop() {
 }
 
 type ServerSet interface {
-	client.Handler
+	Handler
 	Connect() chan bool
-	HandleAll(event string, h client.Handler)
-	HandleAllBG(event string, h client.Handler)
+	HandleAll(event string, h Handler)
+	HandleAllBG(event string, h Handler)
+	Context(t Transport) context.Context
+	Config(t Transport) *ServerConfig
+	Reload()
 	Shutdown(rebuild bool)
 }
 
 type serverSet struct {
-	servers map[*client.Conn]*server
+	ctx     context.Context
+	servers map[Transport]*server
 	wg      *sync.WaitGroup
 	rebuild chan bool
 }
 
-func newServerSet() *serverSet {
-	list := strings.Split(*servers, ",")
-	if len(list) == 0 {
+func newServerSet(ctx context.Context) *serverSet {
+	scs, err := loadServerConfigs()
+	if err != nil {
 		// Don't call logging.Fatal as we don't want a backtrace in this case
-		logging.Error("--server option required. \nOptions are:\n")
+		logging.Error("Bad server config: %v\n"+
+			"--servers or a server_config section in --config required.\n"+
+			"Options are:\n", err)
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
 	ss := &serverSet{
-		servers: make(map[*client.Conn]*server),
+		ctx:     ctx,
+		servers: make(map[Transport]*server),
 		rebuild: make(chan bool),
 		wg:      &sync.WaitGroup{},
 	}
-	for _, hostport := range list {
-		// Configure IRC client
-		cfg := client.NewConfig(*nick, "boing", "slowly becoming sp0rkle")
-		cfg.Flood = true
-		if *ssl {
-			cfg.SSL = true
-			cfg.SSLConfig = &tls.Config{
-				ServerName: strings.Split(hostport, ":")[0],
-			}
-		}
-		cfg.Recover = unfail
-		cfg.Server = hostport
-		conn := client.Client(cfg)
-		ss.servers[conn] = &server{
-			Conn:     conn,
-			hostport: hostport,
-			wg:       ss.wg,
-			wait:     make(chan struct{}),
+	for _, sc := range scs {
+		t := transports[sc.Transport](sc)
+		ss.servers[t] = &server{
+			Transport: t,
+			cfg:       sc,
+			hostport:  sc.Server,
+			wg:        ss.wg,
+			wait:      make(chan struct{}),
+			parent:    ctx,
 		}
 	}
-	ss.HandleAll(client.DISCONNECTED, ss)
+	ss.HandleAll(DISCONNECTED, ss)
 	return ss
 }
 
@@ -128,6 +172,7 @@ func (ss *serverSet) Shutdown(rebuild bool) {
 	logging.Info(message)
 	for _, server := range ss.servers {
 		server.shutdown = true
+		server.cancelContext()
 		if server.Connected() {
 			// If we're connected to this server, disconnect gracefully
 			// and send wait strobe to connectLoop from Handle()
@@ -144,29 +189,129 @@ func (ss *serverSet) Shutdown(rebuild bool) {
 }
 
 // serverSet's Handle() deals with disconnects from individual servers
-func (ss *serverSet) Handle(conn *client.Conn, line *client.Line) {
-	server := ss.servers[conn]
+func (ss *serverSet) Handle(t Transport, line *Line) {
+	server := ss.servers[t]
 	logging.Info("Disconnected from %s...", server.hostport)
+	server.cancelContext()
+	queueFor(t).drop()
 	server.wait <- struct{}{}
 }
 
+// Context() returns the context.Context for requests from t.
+func (ss *serverSet) Context(t Transport) context.Context {
+	if server, ok := ss.servers[t]; ok {
+		return server.context()
+	}
+	return ss.ctx
+}
+
+// Config() returns the current configuration for t.
+func (ss *serverSet) Config(t Transport) *ServerConfig {
+	if server, ok := ss.servers[t]; ok {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return server.cfg
+	}
+	return &ServerConfig{}
+}
+
+// Reload() re-reads server configs. Channels are joined and parted on
+// connected servers to match; nick, password and TLS changes need a
+// restart, while other changes apply when a server next reconnects.
+func (ss *serverSet) Reload() {
+	scs, err := loadServerConfigs()
+	if err != nil {
+		logging.Error("Not reloading server config: %v", err)
+		return
+	}
+	configs := make(map[string]*ServerConfig)
+	for _, sc := range scs {
+		configs[sc.Server] = sc
+	}
+	for _, server := range ss.servers {
+		sc, ok := configs[server.hostport]
+		if !ok {
+			logging.Warn("Server %s no longer configured, restart to "+
+				"disconnect.", server.hostport)
+			continue
+		}
+		delete(configs, server.hostport)
+		server.mu.Lock()
+		old := server.cfg
+		server.cfg = sc
+		server.mu.Unlock()
+		if server.Connected() {
+			// Channels joined or parted at runtime stay that way.
+			joined, parted := channelChanges(server.hostport)
+			join, part := diffChannels(
+				mergeChannels(old.Channels, joined, parted),
+				mergeChannels(sc.Channels, joined, parted))
+			for _, c := range join {
+				logging.Info("Joining %s on %s after reload.", c, server.hostport)
+				server.Join(c)
+			}
+			for _, c := range part {
+				logging.Info("Parting %s on %s after reload.", c, server.hostport)
+				server.Part(c, "")
+			}
+		}
+	}
+	for hostport := range configs {
+		logging.Warn("Server %s newly configured, restart to connect.", hostport)
+	}
+}
+
+// diffChannels returns the channels in to but not from, and vice versa.
+func diffChannels(from, to []string) (added, removed []string) {
+	in := func(c string, list []string) bool {
+		for _, l := range list {
+			if strings.EqualFold(c, l) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range to {
+		if !in(c, from) {
+			added = append(added, c)
+		}
+	}
+	for _, c := range from {
+		if !in(c, to) {
+			removed = append(removed, c)
+		}
+	}
+	return added, removed
+}
+
 // HandleAll() registers Handlers with all the servers in the set
-func (ss *serverSet) HandleAll(ev string, h client.Handler) {
-	for conn, _ := range ss.servers {
-		conn.Handle(ev, h)
+func (ss *serverSet) HandleAll(ev string, h Handler) {
+	for t, _ := range ss.servers {
+		t.Handle(ev, recovering{h})
 	}
 }
 
 // HandleAllBG() registers background Handlers with all the servers in the set
-func (ss *serverSet) HandleAllBG(ev string, h client.Handler) {
-	for conn, _ := range ss.servers {
-		conn.HandleBG(ev, h)
+func (ss *serverSet) HandleAllBG(ev string, h Handler) {
+	for t, _ := range ss.servers {
+		t.HandleBG(ev, recovering{h})
 	}
 }
 
+// recovering stops panics in handlers taking down the bot.
+type recovering struct {
+	Handler
+}
+
+func (r recovering) Handle(t Transport, line *Line) {
+	defer unfail(t, line)
+	r.Handler.Handle(t, line)
+}
+
 // Catch, log, and complain about panics in handlers.
-func unfail(conn *client.Conn, line *client.Line) {
+func unfail(t Transport, line *Line) {
 	if err := recover(); err != nil {
+		panics.Inc()
 		// Depth 4 is where our code usually starts.
 		// But if the panic is somewhere in the depths of the standard
 		// library or dependency code it's helpful to know what
@@ -183,7 +328,7 @@ func unfail(conn *client.Conn, line *client.Line) {
 		}
 		msg := strings.Join(msgs, ", ")
 		logging.Error(msg)
-		conn.Privmsg(line.Target(), msg)
+		queueFor(t).send(PRIVMSG, line.Target(), msg)
 	}
 }
 
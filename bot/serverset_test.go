@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestServerContext(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &server{parent: parent}
+
+	c1 := s.context()
+	if c2 := s.context(); c1 != c2 {
+		t.Errorf("context: expected the same context until disconnect")
+	}
+	s.cancelContext()
+	if c1.Err() == nil {
+		t.Errorf("cancelContext: expected context to be cancelled")
+	}
+	c3 := s.context()
+	if c3.Err() != nil {
+		t.Errorf("context: expected a fresh context after reconnect")
+	}
+	cancel()
+	if c3.Err() == nil {
+		t.Errorf("context: expected cancelling parent to cancel server context")
+	}
+}
+
+func TestServerSetContext(t *testing.T) {
+	base := context.WithValue(context.Background(), "k", "v")
+	ss := &serverSet{ctx: base}
+	if c := ss.Context(nil); c != base {
+		t.Errorf("Context(unknown conn): expected base context")
+	}
+}
+
+func TestDiffChannels(t *testing.T) {
+	tests := []struct {
+		from, to, added, removed []string
+	}{
+		{nil, nil, nil, nil},
+		{nil, []string{"#a"}, []string{"#a"}, nil},
+		{[]string{"#a"}, nil, nil, []string{"#a"}},
+		{[]string{"#a", "#B"}, []string{"#b", "#c"}, []string{"#c"}, []string{"#a"}},
+	}
+	for i, test := range tests {
+		added, removed := diffChannels(test.from, test.to)
+		if !reflect.DeepEqual(added, test.added) || !reflect.DeepEqual(removed, test.removed) {
+			t.Errorf("diffChannels(%d): exp %v %v got %v %v",
+				i, test.added, test.removed, added, removed)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"flag"
+	"sort"
+)
+
+var transport *string = flag.String("transport", "irc",
+	"Transport used to connect to --servers: irc or line.")
+
+// A Transport connects the bot to one chat network. Drivers only see
+// transports through Contexts, so supporting a new kind of network means
+// writing a Transport, not changing drivers.
+type Transport interface {
+	// Name identifies the network in logs, metrics and conf. It's the
+	// Server field of the network's ServerConfig.
+	Name() string
+	// Connect connects to the network, returning once connected or failed.
+	// Transports may be reconnected after they disconnect.
+	Connect() error
+	Connected() bool
+	// Me returns who the bot is on the network.
+	Me() Identity
+
+	// Send sends text to a channel or nick, as a PRIVMSG, ACTION or NOTICE,
+	// and Topic sets or queries a channel's topic. Output from the bot
+	// is split and paced by a send queue before it reaches Send.
+	Output
+	// Room returns how many bytes of text fit in one message of cmd to
+	// target; longer text is split before it is sent.
+	Room(cmd, target string) int
+	Join(ch string)
+	Part(ch, msg string)
+	SetNick(nick string)
+	Quit(msg string)
+
+	// Handle and HandleBG register h to receive the transport's events:
+	// Lines with Cmd event. Handlers registered with Handle are run in
+	// turn as each line is received; those registered with HandleBG run
+	// in their own goroutines. Delivering DISCONNECTED mustn't wait for
+	// foreground handlers to return, since one may be waiting for the
+	// transport to disconnect, e.g. after calling Quit.
+	Handle(event string, h Handler)
+	HandleBG(event string, h Handler)
+}
+
+// Identity is who the bot is on a network.
+type Identity struct {
+	Nick, Ident, Host string
+}
+
+// A Handler handles Lines received from a Transport.
+type Handler interface {
+	Handle(t Transport, line *Line)
+}
+
+// A bgHandler is a Handler registered with Handle, or with HandleBG if bg
+// is set, for transports that deliver some events themselves.
+type bgHandler struct {
+	Handler
+	bg bool
+}
+
+// run delivers a copy of line to h.
+func (h bgHandler) run(t Transport, line *Line) {
+	if h.bg {
+		go h.Handle(t, line.Copy())
+	} else {
+		h.Handle(t, line.Copy())
+	}
+}
+
+// transports builds a Transport for each kind of network the bot can
+// connect to, selected by ServerConfig.Transport.
+var transports = map[string]func(sc *ServerConfig) Transport{
+	"irc":  newIRCTransport,
+	"line": newLineTransport,
+}
+
+// RegisterTransport adds a kind of Transport, for ServerConfigs with
+// Transport set to name. Call it before Init().
+func RegisterTransport(name string, f func(sc *ServerConfig) Transport) {
+	transports[name] = f
+}
+
+// An Unpaced Transport's output isn't put through a send queue, so it
+// is sent as soon as a handler sends it. It's for fake transports in
+// tests; see bot/bottest.
+type Unpaced interface {
+	Transport
+	Unpaced()
+}
+
+func transportNames() []string {
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+func TestIRCTransportRoom(t *testing.T) {
+	it := newIRCTransport(&ServerConfig{Server: "irc.example.com:6667", Nick: "sp0rklf"})
+	// 512 - len(":sp0rklf!<74 bytes> PRIVMSG #chan :\r\n")
+	if n := it.Room(PRIVMSG, "#chan"); n != 411 {
+		t.Errorf("Room(PRIVMSG): exp 411 got %d", n)
+	}
+	if n := it.Room(ACTION, "#chan"); n != 402 {
+		t.Errorf("Room(ACTION): exp 402 got %d", n)
+	}
+}
+
+func TestIRCTransportLine(t *testing.T) {
+	logging.InitFromFlags()
+	it := newIRCTransport(&ServerConfig{Server: "irc.example.com:6667", Nick: "sp0rklf"}).(*ircTransport)
+	when := time.Date(2020, 2, 29, 12, 34, 56, 789e6, time.UTC)
+	tests := []struct {
+		in      string
+		tagCaps bool
+		account string
+		time    time.Time
+	}{
+		{":alice!a@host PRIVMSG #chan :hi", false, "", time.Time{}},
+		{":alice!a@host JOIN #chan alice_acct :Alice", false, "alice_acct", time.Time{}},
+		{":alice!a@host PRIVMSG #chan :hi", false, "alice_acct", time.Time{}},
+		{":alice!a@host NICK :alicia", false, "alice_acct", time.Time{}},
+		{":alicia!a@host PRIVMSG #chan :hi", false, "alice_acct", time.Time{}},
+		{":alice!a@host PRIVMSG #chan :hi", false, "", time.Time{}},
+		{":alicia!a@host ACCOUNT *", false, "", time.Time{}},
+		{":alicia!a@host NICK :alice", false, "", time.Time{}},
+		{":alicia!a@host PRIVMSG #chan :hi", false, "", time.Time{}},
+		{":bob!b@host JOIN #chan * :Bob", false, "", time.Time{}},
+		{":bob!b@host ACCOUNT bob_acct", false, "bob_acct", time.Time{}},
+		{":bob!b@host QUIT :bye", false, "bob_acct", time.Time{}},
+		{":bob!b@host PRIVMSG #chan :hi", false, "", time.Time{}},
+		{"@time=2020-02-29T12:34:56.789Z :bob!b@host PRIVMSG #chan :hi", false, "", when},
+		{"@time=yesterday :bob!b@host PRIVMSG #chan :hi", false, "", time.Time{}},
+		// With account-tag, only the tag counts.
+		{"@account=bob_acct :bob!b@host PRIVMSG #chan :hi", true, "bob_acct", time.Time{}},
+		{":bob!b@host ACCOUNT bob_acct", true, "", time.Time{}},
+		{":bob!b@host QUIT :bye", true, "", time.Time{}},
+	}
+	for i, test := range tests {
+		it.caps["account-tag"] = test.tagCaps
+		cl := client.ParseLine(test.in)
+		// The bot's handlers may see the line before or after track.
+		before := it.line(cl)
+		it.track(it.conn, cl)
+		line := it.line(cl)
+		if before.Account != test.account || line.Account != test.account {
+			t.Errorf("line(%d) %q: exp account %q got %q then %q", i, test.in,
+				test.account, before.Account, line.Account)
+		}
+		if !test.time.IsZero() && !line.Time.Equal(test.time) {
+			t.Errorf("line(%d) %q: exp time %s got %s", i, test.in, test.time, line.Time)
+		}
+		if test.time.IsZero() && !line.Time.Equal(cl.Time) {
+			t.Errorf("line(%d) %q: exp receipt time %s got %s", i, test.in, cl.Time, line.Time)
+		}
+		// Only the line from 2020 is old enough to have been replayed.
+		if line.Replayed != !test.time.IsZero() {
+			t.Errorf("line(%d) %q: exp replayed %t got %t", i, test.in,
+				!test.time.IsZero(), line.Replayed)
+		}
+	}
+	recent := time.Now().Add(-replayAge / 2).UTC().Format(time.RFC3339Nano)
+	if line := it.line(client.ParseLine("@time=" + recent +
+		" :bob!b@host PRIVMSG #chan :hi")); line.Replayed {
+		t.Errorf("line: %s is too recent to have been replayed", recent)
+	}
+}
+
+func TestLineTransportParse(t *testing.T) {
+	lt := newLineTransport(&ServerConfig{Server: "bridge.example.com:7000"}).(*lineTransport)
+	tests := []struct {
+		in       string
+		cmd, src string
+		args     []string
+	}{
+		{"alice #chan hello there", PRIVMSG, "alice!line@bridge.example.com",
+			[]string{"#chan", "hello there"}},
+		{"alice sp0rkle /me waves", ACTION, "alice!line@bridge.example.com",
+			[]string{"sp0rkle", "waves"}},
+		{"alice #chan", "", "", nil},
+	}
+	for i, test := range tests {
+		line := lt.parse(test.in)
+		if test.args == nil {
+			if line != nil {
+				t.Errorf("parse(%d) %q: exp nil got %#v", i, test.in, line)
+			}
+			continue
+		}
+		if line == nil || line.Cmd != test.cmd || line.Src != test.src ||
+			!reflect.DeepEqual(line.Args, test.args) {
+			t.Errorf("parse(%d) %q: got %#v", i, test.in, line)
+		}
+	}
+}
+
+// lineRecorder is a Handler that passes on the lines it's given.
+type lineRecorder chan *Line
+
+func (lr lineRecorder) Handle(t Transport, line *Line) {
+	lr <- line
+}
+
+func TestLineTransport(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lt := newLineTransport(&ServerConfig{Server: l.Addr().String(), Nick: "sp0rkle"})
+	lr := make(lineRecorder, 10)
+	for _, ev := range []string{CONNECTED, DISCONNECTED, PRIVMSG, ACTION} {
+		lt.Handle(ev, lr)
+	}
+	expect := func(cmd string) *Line {
+		t.Helper()
+		select {
+		case line := <-lr:
+			if line.Cmd != cmd {
+				t.Fatalf("expected %s got %#v", cmd, line)
+			}
+			return line
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", cmd)
+		}
+		return nil
+	}
+
+	accept := func() (net.Conn, *bufio.Reader) {
+		t.Helper()
+		if err := lt.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		c, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(CONNECTED)
+		if !lt.Connected() {
+			t.Errorf("Connected: expected true after connecting")
+		}
+		return c, bufio.NewReader(c)
+	}
+	read := func(r *bufio.Reader, exp string) {
+		t.Helper()
+		if s, err := r.ReadString('\n'); err != nil || s != exp+"\n" {
+			t.Errorf("read: exp %q got %q (%v)", exp, s, err)
+		}
+	}
+
+	c, r := accept()
+	c.Write([]byte("alice #chan hello\nnonsense\nalice sp0rkle /me waves\n"))
+	if line := expect(PRIVMSG); line.Nick != "alice" || line.Text() != "hello" {
+		t.Errorf("PRIVMSG: got %#v", line)
+	}
+	if line := expect(ACTION); line.Target() != "alice" || line.Text() != "waves" {
+		t.Errorf("ACTION: got %#v", line)
+	}
+	lt.Send(PRIVMSG, "#chan", "hi")
+	lt.Topic("#chan", "new", "topic")
+	lt.SetNick("sp0rklf")
+	read(r, "PRIVMSG #chan :hi")
+	read(r, "TOPIC #chan :new topic")
+	read(r, "NICK sp0rklf")
+	if me := lt.Me(); me.Nick != "sp0rklf" {
+		t.Errorf("Me: exp sp0rklf got %q", me.Nick)
+	}
+	lt.Quit("bye")
+	read(r, "QUIT :bye")
+	expect(DISCONNECTED)
+	if lt.Connected() {
+		t.Errorf("Connected: expected false after quitting")
+	}
+	c.Close()
+
+	// The other end hanging up disconnects too, and we can reconnect.
+	c, _ = accept()
+	c.Close()
+	expect(DISCONNECTED)
+}
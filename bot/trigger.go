@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+// Conf namespace for command trigger prefixes. The key "*" holds the global
+// triggers, and lowercase channel keys override them for that channel.
+// Values are space-separated lists of prefixes, or "none".
+const triggerNs = "trigger"
+
+const globalTriggers = "*"
+
+// triggerSet caches trigger prefixes, since they're checked for every line.
+type triggerSet struct {
+	sync.RWMutex
+	chans map[string][]string
+}
+
+var triggers = &triggerSet{chans: make(map[string][]string)}
+
+func parseTriggers(s string) []string {
+	if s == "none" {
+		return []string{}
+	}
+	if f := strings.Fields(s); len(f) > 0 {
+		return f
+	}
+	return nil
+}
+
+// validTrigger checks that t can't be confused with normal text.
+func validTrigger(t string) bool {
+	if t == "" || len(t) > 3 {
+		return false
+	}
+	for _, r := range t {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns nil if key has no triggers set.
+func (ts *triggerSet) lookup(key string) []string {
+	key = strings.ToLower(key)
+	ts.RLock()
+	t, ok := ts.chans[key]
+	ts.RUnlock()
+	if ok {
+		return t
+	}
+	t = parseTriggers(conf.Ns(triggerNs).String(key))
+	ts.Lock()
+	defer ts.Unlock()
+	ts.chans[key] = t
+	return t
+}
+
+// For returns the trigger prefixes in effect for ch, which may be empty
+// for private messages.
+func (ts *triggerSet) For(ch string) []string {
+	if ch != "" {
+		if t := ts.lookup(ch); t != nil {
+			return t
+		}
+	}
+	return ts.lookup(globalTriggers)
+}
+
+// set stores triggers for key; nil removes any that were set.
+func (ts *triggerSet) set(key string, t []string) {
+	key = strings.ToLower(key)
+	ts.Lock()
+	defer ts.Unlock()
+	switch {
+	case t == nil:
+		conf.Ns(triggerNs).Delete(key)
+	case len(t) == 0:
+		conf.Ns(triggerNs).String(key, "none")
+	default:
+		conf.Ns(triggerNs).String(key, strings.Join(t, " "))
+	}
+	ts.chans[key] = t
+}
@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTriggers(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"none", []string{}},
+		{"! .", []string{"!", "."}},
+	}
+	for i, test := range tests {
+		if out := parseTriggers(test.in); !reflect.DeepEqual(out, test.out) {
+			t.Errorf("parseTriggers(%d) %q: exp %#v got %#v", i, test.in, test.out, out)
+		}
+	}
+}
+
+func TestValidTrigger(t *testing.T) {
+	tests := map[string]bool{
+		"!":    true,
+		"~~":   true,
+		"@@@":  true,
+		"!!!!": false,
+		"":     false,
+		"a":    false,
+		"!1":   false,
+		"! ":   false,
+	}
+	for in, exp := range tests {
+		if got := validTrigger(in); got != exp {
+			t.Errorf("validTrigger(%q): exp %t got %t", in, exp, got)
+		}
+	}
+}
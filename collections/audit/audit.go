@@ -0,0 +1,161 @@
+// Package audit keeps an append-only log of destructive and
+// administrative actions in BoltDB, so they can be reviewed or undone
+// by hand later. It deliberately doesn't depend on package bot, so that
+// the bot's own admin commands can record actions too.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/sp0rkle/db"
+	"github.com/fluffle/sp0rkle/util/datetime"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const COLLECTION string = "audit"
+
+// An Entry records who did what, where, when, and what was there before.
+type Entry struct {
+	Nick string
+	// Src is the nick!ident@host the action came from.
+	Src  string
+	Chan string
+	// Action is the command used, e.g. "forget that".
+	Action string
+	// Collection is the kind of thing changed, e.g. "factoids".
+	Collection string
+	// Item identifies what was changed, e.g. a factoid key or quote ID.
+	Item string
+	// Prior describes what was there before the change, if anything.
+	Prior     string
+	Timestamp time.Time
+	Id_       bson.ObjectId `bson:"_id,omitempty"`
+}
+
+var _ db.Indexer = (*Entry)(nil)
+
+func (e *Entry) Indexes() []db.Key {
+	// As with factoids, the ObjectId makes keys unique inside each bucket,
+	// and because it begins with a timestamp they sort in time order.
+	id := string(e.Id_)
+	return []db.Key{
+		db.K{db.S{"nick", strings.ToLower(e.Nick)}, db.S{"v", id}},
+		db.K{db.S{"chan", strings.ToLower(e.Chan)}, db.S{"v", id}},
+		db.K{db.S{"collection", e.Collection}, db.S{"v", id}},
+	}
+}
+
+func (e *Entry) Id() bson.ObjectId {
+	return e.Id_
+}
+
+func (e *Entry) String() string {
+	s := fmt.Sprintf("%s <%s:%s> %s %s", datetime.Format(e.Timestamp),
+		e.Nick, e.Chan, e.Action, e.Collection)
+	if e.Item != "" {
+		s += " " + e.Item
+	}
+	if e.Prior != "" {
+		s += fmt.Sprintf(" (was %q)", e.Prior)
+	}
+	return s
+}
+
+type Entries []*Entry
+
+// A Query selects entries. Empty fields match everything; Nick and Chan
+// are compared case-insensitively.
+type Query struct {
+	Nick, Chan, Collection string
+	// Entries must be recorded at or after From and before To.
+	From, To time.Time
+	// Return at most Limit of the most recent matching entries, if > 0.
+	Limit int
+}
+
+func (q Query) matches(e *Entry) bool {
+	return (q.Nick == "" || strings.EqualFold(q.Nick, e.Nick)) &&
+		(q.Chan == "" || strings.EqualFold(q.Chan, e.Chan)) &&
+		(q.Collection == "" || q.Collection == e.Collection) &&
+		(q.From.IsZero() || !e.Timestamp.Before(q.From)) &&
+		(q.To.IsZero() || e.Timestamp.Before(q.To))
+}
+
+// key picks the most selective index to scan for q.
+func (q Query) key() db.K {
+	switch {
+	case q.Nick != "":
+		return db.K{db.S{"nick", strings.ToLower(q.Nick)}}
+	case q.Chan != "":
+		return db.K{db.S{"chan", strings.ToLower(q.Chan)}}
+	case q.Collection != "":
+		return db.K{db.S{"collection", q.Collection}}
+	}
+	return db.K{}
+}
+
+// filter returns the entries matching q, newest first.
+func (q Query) filter(in Entries) Entries {
+	out := Entries{}
+	for _, e := range in {
+		if q.matches(e) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Timestamp.After(out[j].Timestamp)
+	})
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out
+}
+
+var bolt db.C
+
+// When non-nil, entries are kept in memory rather than the database.
+var mem struct {
+	sync.Mutex
+	entries Entries
+}
+
+// UseInMem makes the audit log live in memory instead of the database,
+// discarding anything recorded there before. It's for tests.
+func UseInMem() {
+	mem.Lock()
+	defer mem.Unlock()
+	mem.entries = Entries{}
+}
+
+// Record appends e to the log, setting its timestamp and id.
+func Record(ctx context.Context, e *Entry) error {
+	e.Timestamp, e.Id_ = time.Now(), bson.NewObjectId()
+	mem.Lock()
+	defer mem.Unlock()
+	if mem.entries != nil {
+		mem.entries = append(mem.entries, e)
+		return nil
+	}
+	bolt.Init(db.Bolt.Indexed(), COLLECTION, nil)
+	return bolt.Put(ctx, e)
+}
+
+// Search returns the entries matching q, newest first.
+func Search(ctx context.Context, q Query) (Entries, error) {
+	mem.Lock()
+	defer mem.Unlock()
+	if mem.entries != nil {
+		return q.filter(mem.entries), nil
+	}
+	bolt.Init(db.Bolt.Indexed(), COLLECTION, nil)
+	var all Entries
+	if err := bolt.All(ctx, q.key(), &all); err != nil {
+		return nil, err
+	}
+	return q.filter(all), nil
+}
@@ -1,6 +1,7 @@
 package conf
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/fluffle/golog/logging"
@@ -17,10 +18,10 @@ func (migrator) MigrateTo(newState db.MigrationState) error {
 	var all []Entry
 	mongo.Init(db.Mongo, COLLECTION, mongoIndexes)
 	bolt.Init(db.Bolt.Keyed(), COLLECTION, nil)
-	if err := mongo.All(db.K{}, &all); err != nil {
+	if err := mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := bolt.BatchPut(all); err != nil {
+	if err := bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating conf entries: %v.", err)
 		return err
 	}
@@ -32,10 +33,10 @@ func (migrator) Diff() ([]string, []string, error) {
 	mongo.Init(db.Mongo, COLLECTION, mongoIndexes)
 	bolt.Init(db.Bolt.Keyed(), COLLECTION, nil)
 	var mAll, bAll Entries
-	if err := mongo.All(db.K{}, &mAll); err != nil {
+	if err := mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := bolt.All(db.K{}, &bAll); err != nil {
+	if err := bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -3,6 +3,7 @@ package conf
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/fluffle/goirc/logging"
 	"github.com/fluffle/sp0rkle/db"
@@ -38,7 +39,36 @@ func Bolt(ns string) *namespace {
 
 var checker db.M
 
-func Ns(ns string) *both {
+// When non-nil, namespaces are kept in memory rather than the database.
+var memNs struct {
+	sync.Mutex
+	ns map[string]Namespace
+}
+
+// UseInMem makes Ns() return namespaces kept in memory instead of the
+// database, discarding anything stored there before. It's for tests.
+func UseInMem() {
+	memNs.Lock()
+	defer memNs.Unlock()
+	memNs.ns = make(map[string]Namespace)
+}
+
+func inMemNs(ns string) Namespace {
+	memNs.Lock()
+	defer memNs.Unlock()
+	if memNs.ns == nil {
+		return nil
+	}
+	if _, ok := memNs.ns[ns]; !ok {
+		memNs.ns[ns] = InMem(ns)
+	}
+	return memNs.ns[ns]
+}
+
+func Ns(ns string) Namespace {
+	if n := inMemNs(ns); n != nil {
+		return n
+	}
 	checker.Init(migrator{}, COLLECTION)
 	return &both{bolt: Bolt(ns), mongo: Mongo(ns), Checker: checker}
 }
@@ -1,6 +1,7 @@
 package conf
 
 import (
+	"context"
 	"github.com/fluffle/goirc/logging"
 	"github.com/fluffle/sp0rkle/db"
 	"go.etcd.io/bbolt"
@@ -16,6 +17,8 @@ type Namespace interface {
 	Delete(key string)
 }
 
+// Config is read from all over the bot, mostly with no request context to
+// hand, so namespace operations are done with context.Background().
 type namespace struct {
 	db.Collection
 	ns string
@@ -29,14 +32,14 @@ var _ db.Keyer = (*namespace)(nil)
 
 func (ns *namespace) set(key string, value interface{}) {
 	e := &Entry{Ns: ns.ns, Key: key, Value: value}
-	if err := ns.Put(e); err != nil {
+	if err := ns.Put(context.Background(), e); err != nil {
 		logging.Error("Couldn't set config entry %q: %v", e, err)
 	}
 }
 
 func (ns *namespace) get(key string) interface{} {
 	e := &Entry{Ns: ns.ns, Key: key}
-	if err := ns.Get(e.K(), e); err != nil && err != mgo.ErrNotFound && err != bbolt.ErrTxNotWritable {
+	if err := ns.Get(context.Background(), e.K(), e); err != nil && err != mgo.ErrNotFound && err != bbolt.ErrTxNotWritable {
 		logging.Error("Couldn't get config entry for ns=%q key=%q: %v", ns.ns, key, err)
 		return nil
 	}
@@ -45,7 +48,7 @@ func (ns *namespace) get(key string) interface{} {
 
 func (ns *namespace) All() Entries {
 	var e Entries
-	if err := ns.Collection.All(ns.K(), &e); err == nil {
+	if err := ns.Collection.All(context.Background(), ns.K(), &e); err == nil {
 		return e
 	}
 	return Entries{}
@@ -93,5 +96,5 @@ func (ns *namespace) Value(key string, value ...interface{}) interface{} {
 }
 
 func (ns *namespace) Delete(key string) {
-	ns.Del(&Entry{Ns: ns.ns, Key: key})
+	ns.Del(context.Background(), &Entry{Ns: ns.ns, Key: key})
 }
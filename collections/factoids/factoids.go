@@ -1,6 +1,7 @@
 package factoids
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -158,10 +159,10 @@ func (m migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all Factoids
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating factoids: %v", err)
 		return err
 	}
@@ -171,10 +172,10 @@ func (m migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll Factoids
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -211,32 +212,32 @@ func mongoIndexes(c db.Collection) {
 }
 
 // Can't call this Count because that'd override mgo.Collection.Count()
-func (fc *Collection) GetCount(key string) int {
+func (fc *Collection) GetCount(ctx context.Context, key string) int {
 	// TODO(fluffle): less-wasteful GetCount()
-	return len(fc.GetAll(key))
+	return len(fc.GetAll(ctx, key))
 }
 
-func (fc *Collection) GetById(id bson.ObjectId) *Factoid {
+func (fc *Collection) GetById(ctx context.Context, id bson.ObjectId) *Factoid {
 	res := &Factoid{Id_: id}
-	if err := fc.Get(res.byId(), res); err != nil {
+	if err := fc.Get(ctx, res.byId(), res); err != nil {
 		logging.Warn("Factoid GetById failed: %v", err)
 		return nil
 	}
 	return res
 }
 
-func (fc *Collection) GetAll(key string) []*Factoid {
+func (fc *Collection) GetAll(ctx context.Context, key string) []*Factoid {
 	res := Factoids{}
-	if err := fc.All(byKey(key), &res); err != nil {
+	if err := fc.All(ctx, byKey(key), &res); err != nil {
 		logging.Warn("Factoid GetAll failed: %v", err)
 		return nil
 	}
 	return res
 }
 
-func (fc *Collection) GetPseudoRand(key string) *Factoid {
+func (fc *Collection) GetPseudoRand(ctx context.Context, key string) *Factoid {
 	// TODO(fluffle): GetPR implementation in package db.
-	facts := fc.GetAll(key)
+	facts := fc.GetAll(ctx, key)
 	filtered := Factoids{}
 	ids, ok := fc.seen[key]
 	if ok && len(ids) > 0 {
@@ -279,9 +280,9 @@ func (fc *Collection) GetPseudoRand(key string) *Factoid {
 	return res
 }
 
-func (fc *Collection) GetKeysMatching(regex string) []string {
+func (fc *Collection) GetKeysMatching(ctx context.Context, regex string) []string {
 	facts := Factoids{}
-	if err := fc.Match("Key", regex, &facts); err != nil {
+	if err := fc.Match(ctx, "Key", regex, &facts); err != nil {
 		logging.Warn("Factoid GetKeyMatching failed: %v", err)
 		return nil
 	}
@@ -297,9 +298,9 @@ func (fc *Collection) GetKeysMatching(regex string) []string {
 	return res
 }
 
-func (fc *Collection) GetLast(key string) (c *Factoid, m *Factoid, a *Factoid) {
+func (fc *Collection) GetLast(ctx context.Context, key string) (c *Factoid, m *Factoid, a *Factoid) {
 	// Waaay less efficient for MongoDB but works for both.
-	facts := fc.GetAll(key)
+	facts := fc.GetAll(ctx, key)
 	for _, fact := range facts {
 		if c == nil || c.Created.Timestamp.Before(fact.Created.Timestamp) {
 			c = fact
@@ -314,7 +315,7 @@ func (fc *Collection) GetLast(key string) (c *Factoid, m *Factoid, a *Factoid) {
 	return
 }
 
-func (fc *Collection) InfoMR(key string) *FactoidInfo {
+func (fc *Collection) InfoMR(ctx context.Context, key string) *FactoidInfo {
 	// MapReduce has no BoltDB equivalent and building one seems excessive.
 	minfo := &FactoidInfo{}
 	binfo := &FactoidInfo{}
@@ -360,7 +361,7 @@ func (fc *Collection) InfoMR(key string) *FactoidInfo {
 		// does involve maybe slurping all the factoids into a slice, *again*.
 		// TODO(fluffle): Add a ForEach() to boltdb wrapper once migrated.
 		facts := Factoids{}
-		if err := fc.Both.BoltC.All(byKey(key), &facts); err != nil {
+		if err := fc.Both.BoltC.All(ctx, byKey(key), &facts); err != nil {
 			logging.Warn("Factoid InfoMR All failed: %v", err)
 		}
 
@@ -1,6 +1,7 @@
 package karma
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -83,10 +84,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all []*Karma
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating karma entries: %v", err)
 		return err
 	}
@@ -96,10 +97,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll Karmas
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -135,9 +136,9 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (kc *Collection) KarmaFor(sub string) *Karma {
+func (kc *Collection) KarmaFor(ctx context.Context, sub string) *Karma {
 	res := &Karma{Key: strings.ToLower(sub)}
-	if err := kc.Get(res.K(), res); err == nil {
+	if err := kc.Get(ctx, res.K(), res); err == nil {
 		return res
 	}
 	return nil
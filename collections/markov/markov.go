@@ -1,6 +1,7 @@
 package markov
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"strings"
@@ -184,7 +185,7 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (mc *Collection) incUses(source, dest, tag string) {
+func (mc *Collection) incUses(ctx context.Context, source, dest, tag string) {
 	if util.LooksURLish(source) || util.LooksURLish(dest) {
 		// Skip URLs entirely.
 		return
@@ -194,7 +195,7 @@ func (mc *Collection) incUses(source, dest, tag string) {
 	state := mc.Check()
 	// Read current value.
 	if state < db.BOLT_ONLY {
-		if err := mc.mongo.Get(mlink.byTagSrcDest(), mlink); err != nil {
+		if err := mc.mongo.Get(ctx, mlink.byTagSrcDest(), mlink); err != nil {
 			mlink = New(source, dest, tag)
 		}
 	}
@@ -212,7 +213,7 @@ func (mc *Collection) incUses(source, dest, tag string) {
 	mlink.Uses++
 	// Increment and write new value.
 	if state < db.BOLT_ONLY {
-		if err := mc.mongo.Put(mlink); err != nil {
+		if err := mc.mongo.Put(ctx, mlink); err != nil {
 			logging.Error("Failed to insert Mongo MarkovLink %s: %v",
 				mlink, err)
 		}
@@ -261,20 +262,20 @@ func (mc *Collection) putUsesTx(tx *bolt.Tx, link *MarkovLink) error {
 	return sb.Put([]byte(link.Dest), link.uses)
 }
 
-func (mc *Collection) AddAction(action, tag string) {
-	mc.Add(markov.ACTION_START, action, tag)
+func (mc *Collection) AddAction(ctx context.Context, action, tag string) {
+	mc.Add(ctx, markov.ACTION_START, action, tag)
 }
 
-func (mc *Collection) AddSentence(sentence, tag string) {
-	mc.Add(markov.SENTENCE_START, sentence, tag)
+func (mc *Collection) AddSentence(ctx context.Context, sentence, tag string) {
+	mc.Add(ctx, markov.SENTENCE_START, sentence, tag)
 }
 
-func (mc *Collection) Add(source, data, tag string) {
+func (mc *Collection) Add(ctx context.Context, source, data, tag string) {
 	for _, dest := range strings.Fields(data) {
-		mc.incUses(source, dest, tag)
+		mc.incUses(ctx, source, dest, tag)
 		source = dest
 	}
-	mc.incUses(source, markov.SENTENCE_END, tag)
+	mc.incUses(ctx, source, markov.SENTENCE_END, tag)
 }
 
 func (mc *Collection) ClearTag(tag string) error {
@@ -296,11 +297,12 @@ func (mc *Collection) ClearTag(tag string) error {
 
 type MarkovSource struct {
 	*Collection
+	ctx context.Context
 	tag string
 }
 
-func (mc *Collection) Source(tag string) markov.Source {
-	return &MarkovSource{mc, tag}
+func (mc *Collection) Source(ctx context.Context, tag string) markov.Source {
+	return &MarkovSource{mc, ctx, tag}
 }
 
 func (ms *MarkovSource) GetLinks(source string) (markov.Links, error) {
@@ -314,7 +316,7 @@ func (ms *MarkovSource) GetLinks(source string) (markov.Links, error) {
 			Tag:    ms.tag,
 		}
 		var mAll MarkovLinks
-		mErr = ms.mongo.All(key.byTagSrc(), &mAll)
+		mErr = ms.mongo.All(ms.ctx, key.byTagSrc(), &mAll)
 		for _, link := range mAll {
 			if util.LooksURLish(link.Source) || util.LooksURLish(link.Dest) {
 				// Avoid diffs due to URLs skipped during migration.
@@ -1,6 +1,7 @@
 package pushes
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"strings"
@@ -125,10 +126,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all States
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating push states: %v", err)
 		return err
 	}
@@ -138,10 +139,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll States
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -172,31 +173,31 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (pc *Collection) NewState(nick string) (*State, error) {
+func (pc *Collection) NewState(ctx context.Context, nick string) (*State, error) {
 	s := &State{
 		Nick: strings.ToLower(nick),
 		Time: time.Now(),
 		Id_:  bson.NewObjectId(),
 	}
-	if err := pc.Put(s); err != nil {
+	if err := pc.Put(ctx, s); err != nil {
 		return nil, err
 	}
 	return s, nil
 }
 
-func (pc *Collection) GetByB64(b64 string) *State {
+func (pc *Collection) GetByB64(ctx context.Context, b64 string) *State {
 	id, err := base64.URLEncoding.DecodeString(b64)
 	if err != nil {
 		logging.Error("Decoding base64 string %q: %v", b64, err)
 		return nil
 	}
 	s := &State{Id_: bson.ObjectId(id)}
-	if err := pc.Get(s.byId(), s); err != nil {
+	if err := pc.Get(ctx, s.byId(), s); err != nil {
 		logging.Error("Looking up state with id=%q: %v", id, err)
 		return nil
 	}
 	if s.AuthWindowExpired() {
-		if err := pc.Del(s); err != nil {
+		if err := pc.Del(ctx, s); err != nil {
 			logging.Error("Deleting state with id=%q: %v", id, err)
 		}
 		return nil
@@ -204,15 +205,15 @@ func (pc *Collection) GetByB64(b64 string) *State {
 	return s
 }
 
-func (pc *Collection) GetByNick(nick string, checkAliases bool) *State {
+func (pc *Collection) GetByNick(ctx context.Context, nick string, checkAliases bool) *State {
 	s := &State{}
-	if err := pc.Get(byNick(nick), s); err != nil {
+	if err := pc.Get(ctx, byNick(nick), s); err != nil {
 		logging.Error("Looking up state with nick=%q: %v", nick, err)
 		return nil
 	}
 	if !s.Exists() && checkAliases {
 		// Not found by nick, check aliases.
-		if err := pc.Get(byAlias(nick), s); err != nil {
+		if err := pc.Get(ctx, byAlias(nick), s); err != nil {
 			logging.Error("Looking up state with alias=%q: %v", nick, err)
 			return nil
 		}
@@ -221,7 +222,7 @@ func (pc *Collection) GetByNick(nick string, checkAliases bool) *State {
 		return nil
 	}
 	if s.AuthWindowExpired() {
-		if err := pc.Del(s); err != nil {
+		if err := pc.Del(ctx, s); err != nil {
 			logging.Error("Deleting state with id=%q: %v", s.Id_, err)
 		}
 		return nil
@@ -1,6 +1,7 @@
 package quotes
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"sync/atomic"
@@ -72,22 +73,22 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 	if err := m.mongo.Mongo().Find(bson.M{}).Sort("qid").All(&all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating quotes: %v", err)
 		return err
 	}
 	logging.Info("Migrated %d quotes.", len(all))
 	// Update sequence with current largest QID.
-	_, err := m.bolt.Next(db.K{}, all[len(all)-1].QID)
+	_, err := m.bolt.Next(context.Background(), db.K{}, all[len(all)-1].QID)
 	return err
 }
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll Quotes
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -132,15 +133,15 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (qc *Collection) GetByQID(qid int) *Quote {
+func (qc *Collection) GetByQID(ctx context.Context, qid int) *Quote {
 	res := &Quote{QID: qid}
-	if err := qc.Get(res.byQID(), res); err == nil {
+	if err := qc.Get(ctx, res.byQID(), res); err == nil {
 		return res
 	}
 	return nil
 }
 
-func (qc *Collection) NewQID() (int, error) {
+func (qc *Collection) NewQID(ctx context.Context) (int, error) {
 	var mNext, bNext int
 	var err error
 	state := qc.Check()
@@ -148,7 +149,7 @@ func (qc *Collection) NewQID() (int, error) {
 		mNext = int(atomic.AddInt32(&qc.maxQID, 1))
 	}
 	if state > db.MONGO_ONLY {
-		bNext, err = qc.Next(db.K{})
+		bNext, err = qc.Next(ctx, db.K{})
 	}
 	if (state == db.MONGO_PRIMARY || state == db.BOLT_PRIMARY) &&
 		mNext != bNext {
@@ -160,7 +161,7 @@ func (qc *Collection) NewQID() (int, error) {
 	return mNext, nil
 }
 
-func (qc *Collection) GetPseudoRand(regex string) *Quote {
+func (qc *Collection) GetPseudoRand(ctx context.Context, regex string) *Quote {
 	// TODO(fluffle): This implementation of GetPseudoRand is inefficient
 	// for either Bolt or Mongo on their own. It's a lowest-common-denominator
 	// that should work for both. There are 3 steps: fetch all quotes matching
@@ -169,12 +170,12 @@ func (qc *Collection) GetPseudoRand(regex string) *Quote {
 
 	quotes := Quotes{}
 	if regex == "" {
-		if err := qc.All(db.K{}, &quotes); err != nil {
+		if err := qc.All(ctx, db.K{}, &quotes); err != nil {
 			logging.Warn("Quote All() failed: %s", err)
 			return nil
 		}
 	} else {
-		if err := qc.Match("Quote", regex, &quotes); err != nil {
+		if err := qc.Match(ctx, "Quote", regex, &quotes); err != nil {
 			logging.Warn("Quote Match(%q) failed: %s", regex, err)
 			return nil
 		}
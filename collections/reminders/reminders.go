@@ -1,6 +1,7 @@
 package reminders
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -178,10 +179,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all Reminders
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating reminders: %v", err)
 		return err
 	}
@@ -191,10 +192,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll Reminders
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -224,20 +225,20 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (rc *Collection) GetById(id bson.ObjectId) *Reminder {
+func (rc *Collection) GetById(ctx context.Context, id bson.ObjectId) *Reminder {
 	r := &Reminder{Id_: id}
-	if err := rc.Get(r.byId(), r); err != nil {
+	if err := rc.Get(ctx, r.byId(), r); err != nil {
 		logging.Error("Reminder GetById(%s) failed: %v", id, err)
 		return nil
 	}
 	return r
 }
 
-func (rc *Collection) LoadAndPrune() Reminders {
+func (rc *Collection) LoadAndPrune(ctx context.Context) Reminders {
 	// Can't delete from Bolt without loading everything and sorting.
 	// This will work fine in Mongo too, so let's just do that.
 	var all Reminders
-	if err := rc.All(db.K{db.T{"tell", false}}, &all); err != nil {
+	if err := rc.All(ctx, db.K{db.T{"tell", false}}, &all); err != nil {
 		logging.Error("Loading all reminders: %v", err)
 		return nil
 	}
@@ -253,7 +254,7 @@ func (rc *Collection) LoadAndPrune() Reminders {
 
 	if last > 0 {
 		for _, r := range all[:last] {
-			if err := rc.Del(r); err != nil {
+			if err := rc.Del(ctx, r); err != nil {
 				logging.Error("Deleting expired reminder %v (expiry %s): %v", r.Id_, r.At(), err)
 			}
 		}
@@ -263,13 +264,13 @@ func (rc *Collection) LoadAndPrune() Reminders {
 	return all
 }
 
-func (rc *Collection) RemindersFor(nick string) Reminders {
+func (rc *Collection) RemindersFor(ctx context.Context, nick string) Reminders {
 	nick = strings.ToLower(nick)
 	var from, to Reminders
-	if err := rc.All(remindFrom(nick), &from); err != nil {
+	if err := rc.All(ctx, remindFrom(nick), &from); err != nil {
 		logging.Error("Loading reminders from %s returned error: %v", nick, err)
 	}
-	if err := rc.All(remindTo(nick), &to); err != nil {
+	if err := rc.All(ctx, remindTo(nick), &to); err != nil {
 		logging.Error("Loading reminders to %s returned error: %v", nick, err)
 	}
 	if len(from) == 0 && len(to) == 0 {
@@ -289,9 +290,9 @@ func (rc *Collection) RemindersFor(nick string) Reminders {
 	return from
 }
 
-func (rc *Collection) TellsFor(nick string) Reminders {
+func (rc *Collection) TellsFor(ctx context.Context, nick string) Reminders {
 	var tells Reminders
-	if err := rc.All(tellTo(strings.ToLower(nick)), &tells); err != nil {
+	if err := rc.All(ctx, tellTo(strings.ToLower(nick)), &tells); err != nil {
 		logging.Error("Loading tells for %s returned error: %v", nick, err)
 		return nil
 	}
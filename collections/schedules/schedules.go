@@ -0,0 +1,243 @@
+// Package schedules stores recurring actions for the bot's scheduler in
+// BoltDB, and works out when they should next run. Like audit, it doesn't
+// depend on package bot, since the scheduler lives there.
+package schedules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/sp0rkle/db"
+	"github.com/fluffle/sp0rkle/util/datetime"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const COLLECTION string = "schedules"
+
+// Actions a schedule can take.
+const (
+	// Say says Text in Chan.
+	Say = "say"
+	// Run runs the command Text in Chan, as if Src had said it.
+	Run = "run"
+)
+
+const (
+	// Schedules can't run more often than this.
+	MinInterval = time.Minute
+	// Runs more than this late are missed, e.g. because the bot was down.
+	Grace = time.Minute
+)
+
+// A Schedule runs an action either at a fixed interval, or on some days
+// of the week at a time of day.
+type Schedule struct {
+	// Who created the schedule; commands run with their permissions.
+	Nick, Src string
+	// The server and channel the schedule acts in.
+	Server, Chan string
+	Action       string
+	Text         string
+	// When describes the schedule as it was given, e.g. "every weekday at 9am".
+	When string
+	// Every is the interval between runs, counted from Created.
+	// If it is zero, the schedule runs at time of day At in Zone
+	// on Days, a bitmask with bit N set for time.Weekday(N).
+	Every time.Duration
+	Days  int
+	At    time.Duration
+	Zone  string
+	// If CatchUp is set, runs missed while the bot was down are made up
+	// for with a single run when it returns. Otherwise they're skipped.
+	CatchUp bool
+	// When the schedule will next run and last ran.
+	Next, Last time.Time
+	Created    time.Time
+	Id_        bson.ObjectId `bson:"_id,omitempty"`
+}
+
+var _ db.Indexer = (*Schedule)(nil)
+
+// New returns a schedule with its first run set.
+func New(s Schedule) *Schedule {
+	s.Created, s.Id_ = time.Now(), bson.NewObjectId()
+	s.Next = s.After(s.Created)
+	return &s
+}
+
+func (s *Schedule) Indexes() []db.Key {
+	return []db.Key{
+		db.K{db.S{"nick", strings.ToLower(s.Nick)}, db.S{"v", string(s.Id_)}},
+	}
+}
+
+func (s *Schedule) Id() bson.ObjectId {
+	return s.Id_
+}
+
+// After returns the first time after t that s should run.
+func (s *Schedule) After(t time.Time) time.Time {
+	if s.Every > 0 {
+		n := t.Sub(s.Created)/s.Every + 1
+		if n < 1 {
+			n = 1
+		}
+		return s.Created.Add(n * s.Every)
+	}
+	if s.Days == 0 {
+		return time.Time{}
+	}
+	loc := datetime.ZoneOrLocal(s.Zone)
+	t = t.In(loc)
+	h, m, sec := int(s.At/time.Hour), int(s.At/time.Minute%60), int(s.At/time.Second%60)
+	// A week and a day covers a time earlier today on the same weekday.
+	for i := 0; i <= 7; i++ {
+		run := time.Date(t.Year(), t.Month(), t.Day()+i, h, m, sec, 0, loc)
+		if s.Days&(1<<run.Weekday()) != 0 && run.After(t) {
+			return run
+		}
+	}
+	return time.Time{}
+}
+
+// Advance moves Next past now, returning true if s should run now.
+func (s *Schedule) Advance(now time.Time) bool {
+	if s.Next.IsZero() || s.Next.After(now) {
+		return false
+	}
+	run := s.CatchUp || now.Sub(s.Next) <= Grace
+	if run {
+		s.Last = now
+	}
+	s.Next = s.After(now)
+	return run
+}
+
+// Describe says what s does and when.
+func (s *Schedule) Describe() string {
+	what := fmt.Sprintf("say %q in %s", s.Text, s.Chan)
+	if s.Action == Run {
+		what = fmt.Sprintf("run %q in %s as %s", s.Text, s.Chan, s.Nick)
+	}
+	str := fmt.Sprintf("%s %s", what, s.When)
+	if s.CatchUp {
+		str += ", catching up"
+	}
+	return str
+}
+
+func (s *Schedule) String() string {
+	if s.Next.IsZero() {
+		return s.Describe()
+	}
+	return s.Describe() + ", next at " + datetime.Format(s.Next)
+}
+
+type Schedules []*Schedule
+
+// sortByCreated keeps listings in a stable order, so they can be
+// referred to by number.
+func (ss Schedules) sortByCreated() {
+	sort.SliceStable(ss, func(i, j int) bool {
+		return ss[i].Created.Before(ss[j].Created)
+	})
+}
+
+var bolt db.C
+
+// When non-nil, schedules are kept in memory rather than the database.
+var mem struct {
+	sync.Mutex
+	m map[bson.ObjectId]*Schedule
+}
+
+// UseInMem makes schedules live in memory instead of the database,
+// discarding any stored there before. It's for tests.
+func UseInMem() {
+	mem.Lock()
+	defer mem.Unlock()
+	mem.m = make(map[bson.ObjectId]*Schedule)
+}
+
+// Put stores a new or updated schedule.
+func Put(ctx context.Context, s *Schedule) error {
+	mem.Lock()
+	defer mem.Unlock()
+	return put(ctx, s)
+}
+
+// Update stores a schedule that has run, unless it was deleted while it
+// was running, in which case it returns false.
+func Update(ctx context.Context, s *Schedule) (bool, error) {
+	mem.Lock()
+	defer mem.Unlock()
+	if ok, err := exists(ctx, s); !ok || err != nil {
+		return false, err
+	}
+	return true, put(ctx, s)
+}
+
+// put and exists must be called with mem locked.
+func put(ctx context.Context, s *Schedule) error {
+	if mem.m != nil {
+		c := *s
+		mem.m[s.Id_] = &c
+		return nil
+	}
+	bolt.Init(db.Bolt.Indexed(), COLLECTION, nil)
+	return bolt.Put(ctx, s)
+}
+
+func exists(ctx context.Context, s *Schedule) (bool, error) {
+	if mem.m != nil {
+		_, ok := mem.m[s.Id_]
+		return ok, nil
+	}
+	bolt.Init(db.Bolt.Indexed(), COLLECTION, nil)
+	var theirs Schedules
+	if err := bolt.All(ctx, db.K{db.S{"nick", strings.ToLower(s.Nick)}}, &theirs); err != nil {
+		return false, err
+	}
+	for _, t := range theirs {
+		if t.Id_ == s.Id_ {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Del removes a schedule.
+func Del(ctx context.Context, s *Schedule) error {
+	mem.Lock()
+	defer mem.Unlock()
+	if mem.m != nil {
+		delete(mem.m, s.Id_)
+		return nil
+	}
+	bolt.Init(db.Bolt.Indexed(), COLLECTION, nil)
+	return bolt.Del(ctx, s)
+}
+
+// All returns every schedule, oldest first.
+func All(ctx context.Context) (Schedules, error) {
+	mem.Lock()
+	defer mem.Unlock()
+	var all Schedules
+	if mem.m != nil {
+		for _, s := range mem.m {
+			c := *s
+			all = append(all, &c)
+		}
+	} else {
+		bolt.Init(db.Bolt.Indexed(), COLLECTION, nil)
+		if err := bolt.All(ctx, db.K{}, &all); err != nil {
+			return nil, err
+		}
+	}
+	all.sortByCreated()
+	return all, nil
+}
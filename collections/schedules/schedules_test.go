@@ -0,0 +1,89 @@
+package schedules
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAfter(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s   Schedule
+		t   time.Time
+		exp time.Time
+	}{
+		// Intervals are counted from when the schedule was created.
+		{Schedule{Every: time.Hour, Created: now}, now, now.Add(time.Hour)},
+		{Schedule{Every: time.Hour, Created: now}, now.Add(90 * time.Minute),
+			now.Add(2 * time.Hour)},
+		{Schedule{Every: time.Hour, Created: now}, now.Add(-time.Hour),
+			now.Add(time.Hour)},
+		// Later today, or tomorrow if that time has passed.
+		{Schedule{Days: 0x7f, At: 13 * time.Hour, Zone: "UTC"}, now,
+			now.Add(time.Hour)},
+		{Schedule{Days: 0x7f, At: 12 * time.Hour, Zone: "UTC"}, now,
+			now.AddDate(0, 0, 1)},
+		// Weekdays skip to Monday.
+		{Schedule{Days: 0x3e, At: 9 * time.Hour, Zone: "UTC"}, now.AddDate(0, 0, 2),
+			time.Date(2024, 5, 20, 9, 0, 0, 0, time.UTC)},
+		// Wednesdays only, so a week later.
+		{Schedule{Days: 1 << time.Wednesday, At: 11 * time.Hour, Zone: "UTC"}, now,
+			time.Date(2024, 5, 22, 11, 0, 0, 0, time.UTC)},
+		{Schedule{}, now, time.Time{}},
+	}
+	for i, test := range tests {
+		if got := test.s.After(test.t); !got.Equal(test.exp) {
+			t.Errorf("After(%d): exp %s got %s", i, test.exp, got)
+		}
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		next    time.Time
+		catchUp bool
+		run     bool
+	}{
+		{now.Add(time.Second), false, false},
+		{now, false, true},
+		{now.Add(-Grace), false, true},
+		// Missed runs are skipped unless catching up.
+		{now.Add(-time.Hour), false, false},
+		{now.Add(-time.Hour), true, true},
+		{time.Time{}, true, false},
+	}
+	for i, test := range tests {
+		s := &Schedule{Every: time.Hour, Created: now.Add(-24 * time.Hour),
+			Next: test.next, CatchUp: test.catchUp}
+		if run := s.Advance(now); run != test.run {
+			t.Errorf("Advance(%d): exp %t got %t", i, test.run, run)
+		}
+		if !test.next.IsZero() && !s.Next.After(now) {
+			t.Errorf("Advance(%d): next run %s isn't after now", i, s.Next)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	UseInMem()
+	ctx := context.Background()
+	s := New(Schedule{Nick: "boss", Every: time.Hour})
+	if ok, err := Update(ctx, s); ok || err != nil {
+		t.Errorf("Update: unsaved schedule exp false, nil got %t, %v", ok, err)
+	}
+	Put(ctx, s)
+	s.Last = time.Now()
+	if ok, err := Update(ctx, s); !ok || err != nil {
+		t.Errorf("Update: saved schedule exp true, nil got %t, %v", ok, err)
+	}
+	Del(ctx, s)
+	if ok, _ := Update(ctx, s); ok {
+		t.Errorf("Update: brought back a deleted schedule")
+	}
+	if all, _ := All(ctx); len(all) != 0 {
+		t.Errorf("All: exp no schedules got %d", len(all))
+	}
+}
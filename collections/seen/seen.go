@@ -1,6 +1,7 @@
 package seen
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -147,10 +148,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all Nicks
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating seen: %v", err)
 		return err
 	}
@@ -160,10 +161,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll Nicks
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -197,7 +198,7 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (sc *Collection) LastSeen(nick string) *Nick {
+func (sc *Collection) LastSeen(ctx context.Context, nick string) *Nick {
 	var mAll, bAll Nicks
 	var mErr, bErr error
 	n := &Nick{Nick: bot.Nick(nick)}
@@ -209,7 +210,7 @@ func (sc *Collection) LastSeen(nick string) *Nick {
 		mErr = q.All(&mAll)
 	}
 	if state > db.MONGO_ONLY {
-		bErr = sc.BoltC.All(n.byNick(), &bAll)
+		bErr = sc.BoltC.All(ctx, n.byNick(), &bAll)
 	}
 	if state == db.MONGO_PRIMARY || state == db.BOLT_PRIMARY {
 		if mErr != bErr {
@@ -232,17 +233,17 @@ func (sc *Collection) LastSeen(nick string) *Nick {
 	return mAll[len(mAll)-1]
 }
 
-func (sc *Collection) LastSeenDoing(nick, act string) *Nick {
+func (sc *Collection) LastSeenDoing(ctx context.Context, nick, act string) *Nick {
 	n := &Nick{Nick: bot.Nick(nick), Action: act}
-	if err := sc.Get(n.byNickAction(), n); err == nil && n.Exists() {
+	if err := sc.Get(ctx, n.byNickAction(), n); err == nil && n.Exists() {
 		return n
 	}
 	return nil
 }
 
-func (sc *Collection) SeenAnyMatching(rx string) []string {
+func (sc *Collection) SeenAnyMatching(ctx context.Context, rx string) []string {
 	var ns Nicks
-	if err := sc.Match("Nick", rx, &ns); err != nil {
+	if err := sc.Match(ctx, "Nick", rx, &ns); err != nil {
 		return nil
 	}
 	sort.Sort(ns)
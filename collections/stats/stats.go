@@ -1,6 +1,7 @@
 package stats
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -38,11 +39,11 @@ func NewStat(n bot.Nick, c bot.Chan) *NickStat {
 	}
 }
 
-func (ns *NickStat) Update(line string) {
+// Update counts line, said at time t.
+func (ns *NickStat) Update(line string, t time.Time) {
 	ns.Lines++
 	ns.Words += len(strings.Fields(line))
 	ns.Chars += len(line)
-	t := time.Now()
 	ns.Active[int(t.Weekday())][t.Hour()]++
 }
 
@@ -107,10 +108,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all []*NickStat
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating stats entries: %v", err)
 		return err
 	}
@@ -120,10 +121,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll NickStats
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -157,15 +158,15 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (sc *Collection) StatsFor(nick, ch string) *NickStat {
+func (sc *Collection) StatsFor(ctx context.Context, nick, ch string) *NickStat {
 	res := NewStat(bot.Nick(nick), bot.Chan(ch))
-	if err := sc.Get(res.byKey(), res); err == nil {
+	if err := sc.Get(ctx, res.byKey(), res); err == nil {
 		return res
 	}
 	return nil
 }
 
-func (sc *Collection) TopTen(ch string) []*NickStat {
+func (sc *Collection) TopTen(ctx context.Context, ch string) []*NickStat {
 	var mRes, bRes NickStats
 	state := sc.Check()
 	if state < db.BOLT_ONLY {
@@ -175,7 +176,7 @@ func (sc *Collection) TopTen(ch string) []*NickStat {
 		}
 	}
 	if state > db.MONGO_ONLY {
-		if err := sc.Both.BoltC.All(db.K{db.S{"lines", ch}}, &bRes); err != nil {
+		if err := sc.Both.BoltC.All(ctx, db.K{db.S{"lines", ch}}, &bRes); err != nil {
 			logging.Error("Bolt TopTen All error for channel %s: %v", ch, err)
 		}
 		// TODO(fluffle): Results from Bolt are in ascending order, meh.
@@ -1,6 +1,7 @@
 package urls
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
@@ -100,10 +101,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 		return nil
 	}
 	var all []*Url
-	if err := m.mongo.All(db.K{}, &all); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &all); err != nil {
 		return err
 	}
-	if err := m.bolt.BatchPut(all); err != nil {
+	if err := m.bolt.BatchPut(context.Background(), all); err != nil {
 		logging.Error("Migrating urls: %v", err)
 		return err
 	}
@@ -113,10 +114,10 @@ func (m *migrator) MigrateTo(newState db.MigrationState) error {
 
 func (m *migrator) Diff() ([]string, []string, error) {
 	var mAll, bAll Urls
-	if err := m.mongo.All(db.K{}, &mAll); err != nil {
+	if err := m.mongo.All(context.Background(), db.K{}, &mAll); err != nil {
 		return nil, nil, err
 	}
-	if err := m.bolt.All(db.K{}, &bAll); err != nil {
+	if err := m.bolt.All(context.Background(), db.K{}, &bAll); err != nil {
 		return nil, nil, err
 	}
 	return mAll.Strings(), bAll.Strings(), nil
@@ -157,17 +158,17 @@ func mongoIndexes(c db.Collection) {
 	}
 }
 
-func (uc *Collection) GetById(id bson.ObjectId) *Url {
+func (uc *Collection) GetById(ctx context.Context, id bson.ObjectId) *Url {
 	res := &Url{Id_: id}
-	if err := uc.Get(res.byId(), res); err == nil && res.Exists() {
+	if err := uc.Get(ctx, res.byId(), res); err == nil && res.Exists() {
 		return res
 	}
 	return nil
 }
 
-func (uc *Collection) GetByUrl(u string) *Url {
+func (uc *Collection) GetByUrl(ctx context.Context, u string) *Url {
 	res := &Url{Url: u}
-	if err := uc.Get(res.byUrl(), res); err == nil && res.Exists() {
+	if err := uc.Get(ctx, res.byUrl(), res); err == nil && res.Exists() {
 		return res
 	}
 	return nil
@@ -175,15 +176,15 @@ func (uc *Collection) GetByUrl(u string) *Url {
 
 // TODO(fluffle): Dedupe with quotes and other pseudo-rand implementations.
 // Comments in quotes collection about efficiency apply here too.
-func (uc *Collection) GetRand(regex string) *Url {
+func (uc *Collection) GetRand(ctx context.Context, regex string) *Url {
 	urls := Urls{}
 	if regex == "" {
-		if err := uc.All(db.K{}, &urls); err != nil {
+		if err := uc.All(ctx, db.K{}, &urls); err != nil {
 			logging.Warn("URL All() failed: %v", err)
 			return nil
 		}
 	} else {
-		if err := uc.Match("Url", regex, &urls); err != nil {
+		if err := uc.Match(ctx, "Url", regex, &urls); err != nil {
 			logging.Warn("URL Match(%q) failed: %v", regex, err)
 			return nil
 		}
@@ -231,17 +232,17 @@ func (uc *Collection) GetRand(regex string) *Url {
 	return url
 }
 
-func (uc *Collection) GetCached(c string) *Url {
+func (uc *Collection) GetCached(ctx context.Context, c string) *Url {
 	res := &Url{CachedAs: c}
-	if err := uc.Get(res.byCachedAs(), res); err == nil && res.Exists() {
+	if err := uc.Get(ctx, res.byCachedAs(), res); err == nil && res.Exists() {
 		return res
 	}
 	return nil
 }
 
-func (uc *Collection) GetShortened(s string) *Url {
+func (uc *Collection) GetShortened(ctx context.Context, s string) *Url {
 	res := &Url{Shortened: s}
-	if err := uc.Get(res.byShortened(), res); err == nil && res.Exists() {
+	if err := uc.Get(ctx, res.byShortened(), res); err == nil && res.Exists() {
 		return res
 	}
 	return nil
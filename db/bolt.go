@@ -58,6 +58,7 @@ type boltDatabase struct {
 	db    *bolt.DB
 	dir   string
 	every time.Duration
+	reset chan time.Duration
 	quit  chan struct{}
 }
 
@@ -74,6 +75,7 @@ func (b *boltDatabase) Init(path, backupDir string, backupEvery time.Duration) e
 		return err
 	}
 	b.db, b.dir, b.every, b.quit = db, backupDir, backupEvery, make(chan struct{})
+	b.reset = make(chan time.Duration, 1)
 	// Do a backup on startup and error if it is not successful.
 	if err := os.MkdirAll(b.dir, 0700); err != nil {
 		return fmt.Errorf("could not create backup dir %q: %v", b.dir, err)
@@ -85,6 +87,17 @@ func (b *boltDatabase) Init(path, backupDir string, backupEvery time.Duration) e
 	return nil
 }
 
+// Backup writes a backup now, e.g. before shutting down.
+func (b *boltDatabase) Backup() error {
+	b.Lock()
+	defer b.Unlock()
+	if b.db == nil {
+		return errors.New("db not open")
+	}
+	return b.doBackup()
+}
+
+// Close waits for open transactions to finish before closing the db.
 func (b *boltDatabase) Close() {
 	b.Lock()
 	defer b.Unlock()
@@ -103,10 +116,23 @@ func (b *boltDatabase) DB() *bolt.DB {
 	return b.db
 }
 
+// BackupEvery changes how often backups are written.
+func (b *boltDatabase) BackupEvery(every time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	if b.db == nil || every <= 0 || every == b.every {
+		return
+	}
+	b.every = every
+	b.reset <- every
+}
+
 func (b *boltDatabase) backupLoop() {
 	tick := time.NewTicker(b.every)
 	for {
 		select {
+		case every := <-b.reset:
+			tick.Reset(every)
 		case <-tick.C:
 			if err := b.doBackup(); err != nil {
 				logging.Error("Backup error: %v", err)
@@ -126,10 +152,19 @@ func (b *boltDatabase) doBackup() error {
 		return fmt.Errorf("could not create %q: %v", fn, err)
 	}
 	fz := gzip.NewWriter(fh)
-	defer fz.Close()
 	err = b.db.View(func(tx *bolt.Tx) error {
 		return tx.Copy(fz)
 	})
+	// A backup isn't complete until it's safely on disk.
+	if err == nil {
+		err = fz.Close()
+	}
+	if err == nil {
+		err = fh.Sync()
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
 		os.Remove(fn)
 		return fmt.Errorf("could not copy db to %q: %v", fn, err)
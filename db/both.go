@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/fluffle/goirc/logging"
 	"github.com/fluffle/sp0rkle/util/diff"
+	"github.com/fluffle/sp0rkle/util/metrics"
 	"gopkg.in/mgo.v2"
 )
 
@@ -54,9 +56,14 @@ func dupe(in interface{}) interface{} {
 	return dupeR(reflect.TypeOf(in), reflect.ValueOf(in)).Interface()
 }
 
+var mismatches = metrics.NewCounter("sp0rkle_db_mismatches_total",
+	"Reads or writes where MongoDB and BoltDB disagreed, by collection and method.",
+	"collection", "method")
+
 func (b *Both) compareErr(method string, mErr, bErr error) error {
 	if mErr != bErr {
 		logging.Warn("%s() errors differ: %v != %v", method, mErr, bErr)
+		mismatches.Inc(b.BoltC.name, method)
 	}
 	if b.Check() <= MONGO_PRIMARY {
 		return mErr
@@ -74,107 +81,109 @@ func (b *Both) compare(method, key string, mValue, bValue interface{}, mErr, bEr
 	if err == diff.ErrDiff {
 		logging.Debug("%s() Diff for key %s (-mongo, +bolt): %v\n%s",
 			method, key, err, strings.Join(unified, "\n"))
+		mismatches.Inc(b.BoltC.name, method)
 	} else if err == diff.ErrNotDiffable && !reflect.DeepEqual(mValue, bValue) {
 		logging.Warn("%s() mismatch for key %s.", method, key)
+		mismatches.Inc(b.BoltC.name, method)
 		logging.Debug("Mongo: %#v", mValue)
 		logging.Debug("Bolt: %#v", bValue)
 	}
 	return b.compareErr(method, mErr, bErr)
 }
 
-func (b *Both) Get(key Key, value interface{}) error {
+func (b *Both) Get(ctx context.Context, key Key, value interface{}) error {
 	other := dupe(value)
 	switch b.Check() {
 	case MONGO_ONLY:
-		return b.MongoC.Get(key, value)
+		return b.MongoC.Get(ctx, key, value)
 	case MONGO_PRIMARY:
 		return b.compare("Get", key.String(), value, other,
-			b.MongoC.Get(key, value), b.BoltC.Get(key, other))
+			b.MongoC.Get(ctx, key, value), b.BoltC.Get(ctx, key, other))
 	case BOLT_PRIMARY:
 		return b.compare("Get", key.String(), other, value,
-			b.MongoC.Get(key, other), b.BoltC.Get(key, value))
+			b.MongoC.Get(ctx, key, other), b.BoltC.Get(ctx, key, value))
 	case BOLT_ONLY:
-		return b.BoltC.Get(key, value)
+		return b.BoltC.Get(ctx, key, value)
 	}
 	return ErrInvalidState
 }
 
-func (b *Both) Match(key, re string, value interface{}) error {
+func (b *Both) Match(ctx context.Context, key, re string, value interface{}) error {
 	other := dupe(value)
 	switch b.Check() {
 	case MONGO_ONLY:
-		return b.MongoC.Match(key, re, value)
+		return b.MongoC.Match(ctx, key, re, value)
 	case MONGO_PRIMARY:
 		return b.compare("Match", key, value, other,
-			b.MongoC.Match(key, re, value), b.BoltC.Match(key, re, other))
+			b.MongoC.Match(ctx, key, re, value), b.BoltC.Match(ctx, key, re, other))
 	case BOLT_PRIMARY:
 		return b.compare("Match", key, other, value,
-			b.MongoC.Match(key, re, other), b.BoltC.Match(key, re, value))
+			b.MongoC.Match(ctx, key, re, other), b.BoltC.Match(ctx, key, re, value))
 	case BOLT_ONLY:
-		return b.BoltC.Match(key, re, value)
+		return b.BoltC.Match(ctx, key, re, value)
 	}
 	return ErrInvalidState
 }
 
-func (b *Both) All(key Key, value interface{}) error {
+func (b *Both) All(ctx context.Context, key Key, value interface{}) error {
 	other := dupe(value)
 	switch b.Check() {
 	case MONGO_ONLY:
-		return b.MongoC.All(key, value)
+		return b.MongoC.All(ctx, key, value)
 	case MONGO_PRIMARY:
 		return b.compare("All", key.String(), value, other,
-			b.MongoC.All(key, value), b.BoltC.All(key, other))
+			b.MongoC.All(ctx, key, value), b.BoltC.All(ctx, key, other))
 	case BOLT_PRIMARY:
 		return b.compare("All", key.String(), other, value,
-			b.MongoC.All(key, other), b.BoltC.All(key, value))
+			b.MongoC.All(ctx, key, other), b.BoltC.All(ctx, key, value))
 	case BOLT_ONLY:
-		return b.BoltC.All(key, value)
+		return b.BoltC.All(ctx, key, value)
 	}
 	return ErrInvalidState
 }
 
-func (b *Both) Put(value interface{}) error {
+func (b *Both) Put(ctx context.Context, value interface{}) error {
 	switch b.Check() {
 	case MONGO_ONLY:
-		return b.MongoC.Put(value)
+		return b.MongoC.Put(ctx, value)
 	case MONGO_PRIMARY, BOLT_PRIMARY:
-		return b.compareErr("Put", b.MongoC.Put(value), b.BoltC.Put(value))
+		return b.compareErr("Put", b.MongoC.Put(ctx, value), b.BoltC.Put(ctx, value))
 	case BOLT_ONLY:
-		return b.BoltC.Put(value)
+		return b.BoltC.Put(ctx, value)
 	}
 	return ErrInvalidState
 }
 
-func (b *Both) BatchPut(value interface{}) error {
+func (b *Both) BatchPut(ctx context.Context, value interface{}) error {
 	switch b.Check() {
 	case MONGO_ONLY:
 		// BatchPut is a bolt thing, fail before migration
 		return fmt.Errorf("unable to BatchPut in MONGO_ONLY migration state\n\n%#v\n", value)
 	case MONGO_PRIMARY, BOLT_PRIMARY, BOLT_ONLY:
-		return b.BoltC.BatchPut(value)
+		return b.BoltC.BatchPut(ctx, value)
 	}
 	return ErrInvalidState
 }
 
-func (b *Both) Del(value interface{}) error {
+func (b *Both) Del(ctx context.Context, value interface{}) error {
 	switch b.Check() {
 	case MONGO_ONLY:
-		return b.MongoC.Del(value)
+		return b.MongoC.Del(ctx, value)
 	case MONGO_PRIMARY, BOLT_PRIMARY:
-		return b.compareErr("Del", b.MongoC.Del(value), b.BoltC.Del(value))
+		return b.compareErr("Del", b.MongoC.Del(ctx, value), b.BoltC.Del(ctx, value))
 	case BOLT_ONLY:
-		return b.BoltC.Del(value)
+		return b.BoltC.Del(ctx, value)
 	}
 	return ErrInvalidState
 }
 
-func (b *Both) Next(key Key, set ...int) (int, error) {
+func (b *Both) Next(ctx context.Context, key Key, set ...int) (int, error) {
 	switch b.Check() {
 	case MONGO_ONLY:
 		// Next is a bolt think, fail before migration
 		return 0, fmt.Errorf("unable to Next(%s, %v) in MONGO_ONLY migration state", key, set)
 	case MONGO_PRIMARY, BOLT_PRIMARY, BOLT_ONLY:
-		return b.BoltC.Next(key, set...)
+		return b.BoltC.Next(ctx, key, set...)
 	}
 	return 0, ErrInvalidState
 }
@@ -2,11 +2,14 @@ package db
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/fluffle/sp0rkle/util/metrics"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -25,15 +28,18 @@ type Database interface {
 	C(name string) Collection
 }
 
+// A Collection's operations take the context.Context of whatever they're
+// done for. Neither MongoDB nor BoltDB can abandon an operation that has
+// started, but none are started once the context is done.
 type Collection interface {
-	Get(Key, interface{}) error
+	Get(context.Context, Key, interface{}) error
 	// GetPR(Key, interface{}) error ?
-	Match(string, string, interface{}) error
-	All(Key, interface{}) error
-	Put(interface{}) error
-	BatchPut(interface{}) error
-	Del(interface{}) error
-	Next(Key, ...int) (int, error)
+	Match(context.Context, string, string, interface{}) error
+	All(context.Context, Key, interface{}) error
+	Put(context.Context, interface{}) error
+	BatchPut(context.Context, interface{}) error
+	Del(context.Context, interface{}) error
+	Next(context.Context, Key, ...int) (int, error)
 	// Turn on debugging for this collection.
 	Debug(bool)
 	// So we don't have to do everything at once.
@@ -43,17 +49,103 @@ type Collection interface {
 type C struct {
 	Collection
 	sync.Once
+	name string
 }
 
 func (c *C) Init(db Database, name string, f func(Collection)) {
 	c.Do(func() {
-		c.Collection = db.C(name)
+		c.name = name
+		coll := db.C(name)
+		if _, ok := coll.(offline); ok {
+			// No MongoDB, so no indexes to create; see BoltOnly.
+			c.Collection = coll
+			return
+		}
+		c.Collection = &timed{coll, name, backend(db)}
 		if f != nil {
 			f(c)
 		}
 	})
 }
 
+var opSeconds = metrics.NewHistogram("sp0rkle_db_op_seconds",
+	"Time taken by database operations, by collection, method and backend.",
+	metrics.DefBuckets, "collection", "method", "backend")
+
+func backend(db Database) string {
+	if _, ok := db.(*mongoDatabase); ok {
+		return "mongo"
+	}
+	return "bolt"
+}
+
+// timed records the latency of a Collection's operations, and doesn't
+// start them once their context is done.
+type timed struct {
+	Collection
+	name, backend string
+}
+
+func (t *timed) since(start time.Time, method string) {
+	opSeconds.Since(start, t.name, method, t.backend)
+}
+
+func (t *timed) Get(ctx context.Context, key Key, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer t.since(time.Now(), "Get")
+	return t.Collection.Get(ctx, key, value)
+}
+
+func (t *timed) Match(ctx context.Context, key, re string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer t.since(time.Now(), "Match")
+	return t.Collection.Match(ctx, key, re, value)
+}
+
+func (t *timed) All(ctx context.Context, key Key, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer t.since(time.Now(), "All")
+	return t.Collection.All(ctx, key, value)
+}
+
+func (t *timed) Put(ctx context.Context, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer t.since(time.Now(), "Put")
+	return t.Collection.Put(ctx, value)
+}
+
+func (t *timed) BatchPut(ctx context.Context, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer t.since(time.Now(), "BatchPut")
+	return t.Collection.BatchPut(ctx, value)
+}
+
+func (t *timed) Del(ctx context.Context, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer t.since(time.Now(), "Del")
+	return t.Collection.Del(ctx, value)
+}
+
+func (t *timed) Next(ctx context.Context, key Key, set ...int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	defer t.since(time.Now(), "Next")
+	return t.Collection.Next(ctx, key, set...)
+}
+
 type Elem interface {
 	Pair() (string, interface{})
 	Bytes() []byte
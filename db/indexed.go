@@ -2,6 +2,7 @@ package db
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -113,7 +114,7 @@ func (bucket *indexedBucket) create(tx *bbolt.Tx, elems [][]byte) (*bbolt.Bucket
 	return b, nil
 }
 
-func (bucket *indexedBucket) Get(key Key, value interface{}) error {
+func (bucket *indexedBucket) Get(ctx context.Context, key Key, value interface{}) error {
 	elems, last := key.B()
 	if len(last) == 0 {
 		return bucket.error("Get(): zero length key")
@@ -142,7 +143,7 @@ func (bucket *indexedBucket) Get(key Key, value interface{}) error {
 	})
 }
 
-func (bucket *indexedBucket) All(key Key, value interface{}) error {
+func (bucket *indexedBucket) All(ctx context.Context, key Key, value interface{}) error {
 	elems, last := key.B()
 	if len(last) == 0 {
 		// A zero-length key will perform a scan over the vals bucket directly,
@@ -174,7 +175,7 @@ func (bucket *indexedBucket) All(key Key, value interface{}) error {
 	})
 }
 
-func (bucket *indexedBucket) Match(field, re string, value interface{}) error {
+func (bucket *indexedBucket) Match(ctx context.Context, field, re string, value interface{}) error {
 	if re == "" {
 		return bucket.error("Match(): zero-length regex match")
 	}
@@ -204,7 +205,7 @@ func (bucket *indexedBucket) Match(field, re string, value interface{}) error {
 	})
 }
 
-func (bucket *indexedBucket) Put(value interface{}) error {
+func (bucket *indexedBucket) Put(ctx context.Context, value interface{}) error {
 	indexer, ok := value.(Indexer)
 	if !ok {
 		return bucket.error("Put(): don't know how to put value %#v", value)
@@ -218,7 +219,7 @@ func (bucket *indexedBucket) Put(value interface{}) error {
 	})
 }
 
-func (bucket *indexedBucket) BatchPut(value interface{}) error {
+func (bucket *indexedBucket) BatchPut(ctx context.Context, value interface{}) error {
 	// vv == value Value
 	vv := reflect.ValueOf(value)
 	if vv.Kind() != reflect.Slice || !vv.Type().Elem().Implements(indexerType) {
@@ -311,7 +312,7 @@ func (bucket *indexedBucket) delIndex(tx *bbolt.Tx, value Indexer) error {
 	return nil
 }
 
-func (bucket *indexedBucket) Del(value interface{}) error {
+func (bucket *indexedBucket) Del(ctx context.Context, value interface{}) error {
 	indexer, ok := value.(Indexer)
 	if !ok {
 		return bucket.error("Del(): don't know how to delete value %#v", value)
@@ -325,7 +326,7 @@ func (bucket *indexedBucket) Del(value interface{}) error {
 	})
 }
 
-func (bucket *indexedBucket) Next(k Key, set ...int) (int, error) {
+func (bucket *indexedBucket) Next(ctx context.Context, k Key, set ...int) (int, error) {
 	var i uint64
 	elems, last := k.B()
 	// Next implies that the last key elem is also a bucket.
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -91,7 +92,7 @@ func (bucket *keyedBucket) create(tx *bbolt.Tx, elems [][]byte) (*bbolt.Bucket,
 	return b, nil
 }
 
-func (bucket *keyedBucket) Get(key Key, value interface{}) error {
+func (bucket *keyedBucket) Get(ctx context.Context, key Key, value interface{}) error {
 	elems, last := key.B()
 	if len(last) == 0 {
 		return bucket.error("Get(): zero length key")
@@ -110,7 +111,7 @@ func (bucket *keyedBucket) Get(key Key, value interface{}) error {
 	})
 }
 
-func (bucket *keyedBucket) All(key Key, value interface{}) error {
+func (bucket *keyedBucket) All(ctx context.Context, key Key, value interface{}) error {
 	elems, last := key.B()
 	// All implies that the last key elem is also a bucket.
 	// We support a zero-length key to perform a scan over the root bucket.
@@ -131,7 +132,7 @@ func (bucket *keyedBucket) All(key Key, value interface{}) error {
 	})
 }
 
-func (bucket *keyedBucket) Match(field, re string, value interface{}) error {
+func (bucket *keyedBucket) Match(ctx context.Context, field, re string, value interface{}) error {
 	if re == "" {
 		return bucket.error("Match(): zero-length regex match")
 	}
@@ -163,7 +164,7 @@ func (bucket *keyedBucket) Match(field, re string, value interface{}) error {
 	})
 }
 
-func (bucket *keyedBucket) Put(value interface{}) error {
+func (bucket *keyedBucket) Put(ctx context.Context, value interface{}) error {
 	keyer, ok := value.(Keyer)
 	if !ok {
 		return bucket.error("Put(): don't know how to put value %#v", value)
@@ -182,7 +183,7 @@ func (bucket *keyedBucket) Put(value interface{}) error {
 	})
 }
 
-func (bucket *keyedBucket) BatchPut(value interface{}) error {
+func (bucket *keyedBucket) BatchPut(ctx context.Context, value interface{}) error {
 	// vv == value Value
 	vv := reflect.ValueOf(value)
 	if vv.Kind() != reflect.Slice || !vv.Type().Elem().Implements(keyerType) {
@@ -229,7 +230,7 @@ func (bucket *keyedBucket) putTx(tx *bbolt.Tx, elems [][]byte, key, value []byte
 	return b.Put(key, value)
 }
 
-func (bucket *keyedBucket) Del(value interface{}) error {
+func (bucket *keyedBucket) Del(ctx context.Context, value interface{}) error {
 	keyer, ok := value.(Keyer)
 	if !ok {
 		return bucket.error("Del(): don't know how to delete value %#v", value)
@@ -252,7 +253,7 @@ func (bucket *keyedBucket) Del(value interface{}) error {
 	})
 }
 
-func (bucket *keyedBucket) Next(k Key, set ...int) (int, error) {
+func (bucket *keyedBucket) Next(ctx context.Context, k Key, set ...int) (int, error) {
 	var i uint64
 	elems, last := k.B()
 	// Next implies that the last key elem is also a bucket.
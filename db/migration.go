@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -93,7 +94,7 @@ func (d *done) K() Key {
 
 func getMigrationState(coll string) MigrationState {
 	d := &done{collection: coll}
-	if err := ms.db.Get(d.K(), d); err != nil {
+	if err := ms.db.Get(context.Background(), d.K(), d); err != nil {
 		logging.Warn("Checking migrated status for %q: %v", coll, err)
 	}
 	return d.State
@@ -127,7 +128,10 @@ func addMigrator(m Migrator, coll string) Checker {
 		logging.Warn("Second call to MigratorSet.Add for %q.", coll)
 		return checker
 	}
-	state := getMigrationState(coll)
+	state := BOLT_ONLY
+	if !Mongo.isBoltOnly() {
+		state = getMigrationState(coll)
+	}
 	ms.migrators[coll] = &migrator{m, state}
 	logging.Debug("Added migrator for %s, current state == %s.", coll, state)
 	return checker
@@ -181,7 +185,7 @@ func MigrateTo(newState MigrationState) error {
 		}
 		// This is probably a little more locking than strictly necessary.
 		ms.Lock()
-		if err := ms.db.Put(&done{collection: coll, State: newState}); err != nil {
+		if err := ms.db.Put(context.Background(), &done{collection: coll, State: newState}); err != nil {
 			logging.Warn("Setting migrated status for %q: %v", coll, err)
 		}
 		m.state = newState
@@ -4,6 +4,7 @@ package db
 // Yes, these are globals. I'm undecided, but let's see how it goes.
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -20,6 +21,8 @@ const DATABASE string = "sp0rkle"
 type mongoDatabase struct {
 	sync.Mutex
 	sessions []*mgo.Session
+	// Set by BoltOnly, when there's no MongoDB to connect to.
+	boltOnly bool
 }
 
 var Mongo = &mongoDatabase{}
@@ -59,6 +62,9 @@ func (m *mongoDatabase) Close() {
 func (m *mongoDatabase) C(name string) Collection {
 	m.Lock()
 	defer m.Unlock()
+	if m.boltOnly {
+		return offline{}
+	}
 	if m.sessions == nil {
 		logging.Fatal("Tried to create MongoDB collection %q when disconnected.", name)
 	}
@@ -84,22 +90,22 @@ func (m *mongoCollection) debug(f string, args ...interface{}) {
 	}
 }
 
-func (m *mongoCollection) Get(key Key, value interface{}) error {
+func (m *mongoCollection) Get(ctx context.Context, key Key, value interface{}) error {
 	k := key.M()
 	m.debug("Get(%v)", k)
 	return m.Collection.Find(k).One(value)
 }
 
-func (m *mongoCollection) Match(key, regex string, value interface{}) error {
+func (m *mongoCollection) Match(ctx context.Context, key, regex string, value interface{}) error {
 	q := bson.M{strings.ToLower(key): bson.M{"$regex": regex, "$options": "i"}}
 	return m.Collection.Find(q).All(value)
 }
 
-func (m *mongoCollection) All(key Key, value interface{}) error {
+func (m *mongoCollection) All(ctx context.Context, key Key, value interface{}) error {
 	return m.Collection.Find(key.M()).All(value)
 }
 
-func (m *mongoCollection) Put(value interface{}) (err error) {
+func (m *mongoCollection) Put(ctx context.Context, value interface{}) (err error) {
 	switch value := value.(type) {
 	case Keyer:
 		_, err = m.Collection.Upsert(value.K().M(), value)
@@ -111,11 +117,11 @@ func (m *mongoCollection) Put(value interface{}) (err error) {
 	return err
 }
 
-func (m *mongoCollection) BatchPut(value interface{}) error {
+func (m *mongoCollection) BatchPut(ctx context.Context, value interface{}) error {
 	panic("no batch puts for you")
 }
 
-func (m *mongoCollection) Del(value interface{}) error {
+func (m *mongoCollection) Del(ctx context.Context, value interface{}) error {
 	switch value := value.(type) {
 	case Keyer:
 		return m.Collection.Remove(value.K().M())
@@ -125,10 +131,41 @@ func (m *mongoCollection) Del(value interface{}) error {
 	return fmt.Errorf("del: don't know how to delete value %#v", value)
 }
 
-func (m *mongoCollection) Next(k Key, set ...int) (int, error) {
+func (m *mongoCollection) Next(ctx context.Context, k Key, set ...int) (int, error) {
 	panic("no autoincrements for you")
 }
 
 func (m *mongoCollection) Mongo() *mgo.Collection {
 	return m.Collection
 }
+
+// BoltOnly makes every collection use only BoltDB, as if it had been
+// migrated from MongoDB, so that no MongoDB is needed. It's for tests,
+// and must be called before any collections are initialised.
+func BoltOnly() {
+	Mongo.Lock()
+	defer Mongo.Unlock()
+	Mongo.boltOnly = true
+}
+
+func (m *mongoDatabase) isBoltOnly() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.boltOnly
+}
+
+// offline stands in for MongoDB collections after BoltOnly. Collections
+// don't read or write MongoDB once they're migrated, so it's never used.
+type offline struct{}
+
+var errOffline = errors.New("not connected to MongoDB")
+
+func (offline) Get(context.Context, Key, interface{}) error              { return errOffline }
+func (offline) Match(context.Context, string, string, interface{}) error { return errOffline }
+func (offline) All(context.Context, Key, interface{}) error              { return errOffline }
+func (offline) Put(context.Context, interface{}) error                   { return errOffline }
+func (offline) BatchPut(context.Context, interface{}) error              { return errOffline }
+func (offline) Del(context.Context, interface{}) error                   { return errOffline }
+func (offline) Next(context.Context, Key, ...int) (int, error)           { return 0, errOffline }
+func (offline) Debug(bool)                                               {}
+func (offline) Mongo() *mgo.Collection                                   { return nil }
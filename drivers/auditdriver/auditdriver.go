@@ -0,0 +1,94 @@
+package auditdriver
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/audit"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+var httpToken *string = flag.String("audit_token", "",
+	"Token required to view the audit log at /audit?token=<token>. "+
+		"May be given as $ENV_VAR or <file_path. The page is off if unset.")
+
+const auditPath = "/audit"
+
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "auditdriver",
+		Description: "Searches the log of destructive and admin actions.",
+		Init:        Init,
+	})
+}
+
+func Init() {
+	bot.Command(search, "audit", "audit [by <nick>] [in <#chan>] "+
+		"[of <collection>] [since <time>] [until <time>]  -- search the "+
+		"log of deletions and admin actions.", bot.Requires(bot.Admin))
+
+	if *httpToken != "" {
+		http.HandleFunc(auditPath, auditHTTP)
+	}
+}
+
+var keywords = map[string]bool{
+	"by": true, "in": true, "of": true, "since": true, "until": true,
+}
+
+// parseQuery parses "[by <nick>] [in <#chan>] [of <collection>]
+// [since <time>] [until <time>]", in any order. Times are parsed in zone,
+// and may also be durations like "36h", meaning that long ago.
+func parseQuery(txt string, zone *time.Location) (audit.Query, error) {
+	q := audit.Query{}
+	fields := strings.Fields(txt)
+	for i := 0; i < len(fields); {
+		kw := strings.ToLower(fields[i])
+		if !keywords[kw] {
+			return q, fmt.Errorf("Expected by, in, of, since or until, not '%s'.", fields[i])
+		}
+		j := i + 1
+		for j < len(fields) && !keywords[strings.ToLower(fields[j])] {
+			j++
+		}
+		val := strings.Join(fields[i+1:j], " ")
+		if val == "" {
+			return q, fmt.Errorf("'%s' what?", kw)
+		}
+		switch kw {
+		case "by":
+			q.Nick = val
+		case "in":
+			q.Chan = val
+		case "of":
+			q.Collection = strings.ToLower(val)
+		case "since", "until":
+			t, err := parseTime(val, zone)
+			if err != nil {
+				return q, err
+			}
+			if kw == "since" {
+				q.From = t
+			} else {
+				q.To = t
+			}
+		}
+		i = j
+	}
+	return q, nil
+}
+
+func parseTime(s string, zone *time.Location) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := datetime.ParseZ(s, zone)
+	if err != nil {
+		return t, fmt.Errorf("Couldn't parse time '%s'.", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package auditdriver
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+	"github.com/fluffle/sp0rkle/collections/audit"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		in    string
+		exp   audit.Query
+		since time.Duration
+		err   bool
+	}{
+		{"", audit.Query{}, 0, false},
+		{"by Fluffle", audit.Query{Nick: "Fluffle"}, 0, false},
+		{"of Factoids in #chan by bob",
+			audit.Query{Nick: "bob", Chan: "#chan", Collection: "factoids"}, 0, false},
+		{"in #chan since 36h", audit.Query{Chan: "#chan"}, 36 * time.Hour, false},
+		{"by", audit.Query{}, 0, true},
+		{"bob", audit.Query{}, 0, true},
+		{"since whenever", audit.Query{}, 0, true},
+	}
+	for i, test := range tests {
+		q, err := parseQuery(test.in, time.UTC)
+		if (err != nil) != test.err {
+			t.Errorf("%d: %q: err = %v, want error %t", i, test.in, err, test.err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if test.since > 0 {
+			if d := now.Sub(q.From) - test.since; d < -time.Minute || d > time.Minute {
+				t.Errorf("%d: %q: From = %s, want %s ago", i, test.in, q.From, test.since)
+			}
+			q.From = time.Time{}
+		}
+		if q != test.exp {
+			t.Errorf("%d: %q: exp %#v got %#v", i, test.in, test.exp, q)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	bottest.Init()
+	Init()
+	b := bottest.New()
+	bottest.Grant("boss!ident@*", bot.Admin)
+	b.Say("boss", "#chan", "sp0rkle: ignore spammer!*@*")
+	b.Say("boss", "#chan", "sp0rkle: unignore spammer!*@*")
+	b.Sent()
+
+	b.Say("boss", "#chan", "sp0rkle: audit by boss of ignores")
+	texts := b.Texts()
+	if len(texts) != 3 || texts[0] != "boss: 2 audit log entries, newest first:" {
+		t.Fatalf("audit: unexpected output %q", texts)
+	}
+	if !strings.Contains(texts[1], "<boss:#chan> unignore ignores spammer!*@*") ||
+		!strings.Contains(texts[1], `(was "spammer!*@*")`) {
+		t.Errorf("audit: unexpected unignore entry %q", texts[1])
+	}
+	if !strings.Contains(texts[2], "<boss:#chan> ignore ignores spammer!*@*") {
+		t.Errorf("audit: unexpected ignore entry %q", texts[2])
+	}
+
+	b.Say("boss", "#chan", "sp0rkle: audit by nobody")
+	if texts := b.Texts(); len(texts) != 1 ||
+		texts[0] != "boss: Nothing in the audit log matches that." {
+		t.Errorf("audit by nobody: unexpected output %q", texts)
+	}
+	b.Say("pleb", "#chan", "sp0rkle: audit")
+	if texts := b.Texts(); len(texts) != 1 || !strings.Contains(texts[0], "You need to be") {
+		t.Errorf("audit by non-admin: unexpected output %q", texts)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	bottest.Init()
+	audit.Record(context.Background(), &audit.Entry{Nick: "web", Chan: "#chan", Action: "qdel",
+		Collection: "quotes", Item: "#1", Prior: "<b>bold</b>"})
+	*httpToken = "sekrit"
+	defer func() { *httpToken = "" }()
+
+	tests := []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"", 403, "Forbidden"},
+		{"?token=wrong", 403, "Forbidden"},
+		{"?token=sekrit&nick=web", 200, "&lt;b&gt;bold&lt;/b&gt;"},
+		{"?token=sekrit&nick=nobody", 200, "Nothing in the audit log"},
+		{"?token=sekrit&since=whenever", 200, "Couldn&#39;t parse time"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		auditHTTP(rec, httptest.NewRequest("GET", auditPath+test.query, nil))
+		if rec.Code != test.code || !strings.Contains(rec.Body.String(), test.body) {
+			t.Errorf("%q: exp %d containing %q, got %d:\n%s",
+				test.query, test.code, test.body, rec.Code, rec.Body.String())
+		}
+	}
+}
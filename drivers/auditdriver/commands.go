@@ -0,0 +1,37 @@
+package auditdriver
+
+import (
+	"fmt"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/audit"
+	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// Replies on IRC are paged, but nobody is going to read more than this.
+const maxResults = 50
+
+func search(ctx *bot.Context) {
+	q, err := parseQuery(ctx.Text(), datetime.ZoneOrLocal(conf.Zone(ctx.Nick)))
+	if err != nil {
+		ctx.ReplyN("%s", err)
+		return
+	}
+	q.Limit = maxResults
+	entries, err := audit.Search(ctx.Ctx(), q)
+	if err != nil {
+		ctx.ReplyN("Searching the audit log failed: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		ctx.ReplyN("Nothing in the audit log matches that.")
+		return
+	}
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+	// Entries quote what people said, so show them as they were.
+	ctx.ReplyPagedLiteral(fmt.Sprintf("%d audit log entries, newest first:", len(entries)), lines)
+}
@@ -0,0 +1,102 @@
+package auditdriver
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/audit"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// The page shows this many entries unless asked for more.
+const defaultHTTPLimit = 200
+
+type auditPage struct {
+	Token                  string
+	Nick, Chan, Collection string
+	Since, Until           string
+	Limit                  int
+	Error                  string
+	Entries                audit.Entries
+}
+
+func auditHTTP(rw http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := bot.GetSecret(*httpToken)
+	if token == "" || subtle.ConstantTimeCompare(
+		[]byte(req.FormValue("token")), []byte(token)) != 1 {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+	p := &auditPage{
+		Token:      req.FormValue("token"),
+		Nick:       req.FormValue("nick"),
+		Chan:       req.FormValue("chan"),
+		Collection: req.FormValue("collection"),
+		Since:      req.FormValue("since"),
+		Until:      req.FormValue("until"),
+		Limit:      defaultHTTPLimit,
+	}
+	if n, err := strconv.Atoi(req.FormValue("limit")); err == nil && n > 0 {
+		p.Limit = n
+	}
+	q := audit.Query{Nick: p.Nick, Chan: p.Chan, Collection: p.Collection, Limit: p.Limit}
+	var err error
+	if p.Since != "" {
+		q.From, err = parseTime(p.Since, datetime.TZ())
+	}
+	if err == nil && p.Until != "" {
+		q.To, err = parseTime(p.Until, datetime.TZ())
+	}
+	if err == nil {
+		p.Entries, err = audit.Search(req.Context(), q)
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if err := auditTmpl.Execute(rw, p); err != nil {
+		logging.Error("Rendering audit page: %v", err)
+	}
+}
+
+var auditTmpl = template.Must(template.New("audit").Funcs(template.FuncMap{
+	"when": func(e *audit.Entry) string { return datetime.Format(e.Timestamp) },
+}).Parse(`<html>
+<head>
+  <title>sp0rkle's audit log</title>
+</head>
+<body>
+  <h1>Audit log</h1>
+  <form action="/audit" method="GET">
+    <input type="hidden" name="token" value="{{.Token}}">
+    Nick <input type="text" name="nick" value="{{.Nick}}">
+    Channel <input type="text" name="chan" value="{{.Chan}}">
+    Collection <input type="text" name="collection" value="{{.Collection}}">
+    Since <input type="text" name="since" value="{{.Since}}">
+    Until <input type="text" name="until" value="{{.Until}}">
+    Limit <input type="text" name="limit" value="{{.Limit}}" size="4">
+    <input type="submit" value="Search">
+  </form>
+{{ if .Error }}
+  <p><b>{{.Error}}</b></p>
+{{ else if not .Entries }}
+  <p>Nothing in the audit log matches that.</p>
+{{ else }}
+  <table border="1" cellpadding="3">
+    <tr><th>When</th><th>Who</th><th>Where</th><th>Action</th>
+      <th>Collection</th><th>Item</th><th>Was</th></tr>
+{{ range .Entries }}
+    <tr><td>{{ when . }}</td><td title="{{.Src}}">{{.Nick}}</td><td>{{.Chan}}</td>
+      <td>{{.Action}}</td><td>{{.Collection}}</td><td>{{.Item}}</td><td>{{.Prior}}</td></tr>
+{{ end }}
+  </table>
+{{ end }}
+</body>
+</html>`))
@@ -4,18 +4,29 @@ import (
 	"github.com/fluffle/sp0rkle/bot"
 )
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "calcdriver",
+		Description: "Does maths and converts dates, netmasks and bases.",
+		Init:        Init,
+	})
+}
+
 func Init() {
-	bot.Command(calculate, "calc", "calc <expr>  -- does maths for you")
+	bot.Command(calculate, "calc", "calc <expr>  -- does maths for you",
+		bot.Pipes())
 	bot.Command(date, "date", "date <time/date> [in <zone>] -- "+
-		"works out the absolute time for <time/date> [in <zone>]")
+		"works out the absolute time for <time/date> [in <zone>]", bot.Pipes())
 	bot.Command(netmask, "netmask", "netmask <ip/cidr>|<ip> <mask>"+
-		"  -- calculate IPv4 / IPv6 netmasks")
+		"  -- calculate IPv4 / IPv6 netmasks", bot.Pipes())
 	bot.Command(chr, "chr", "chr <int>  -- "+
-		"prints the character represented by <int> in various formats")
+		"prints the character represented by <int> in various formats",
+		bot.Pipes())
 	bot.Command(ord, "ord", "ord <char>  -- "+
-		"prints the numeric and UTF-8 representations of <char>")
+		"prints the numeric and UTF-8 representations of <char>",
+		bot.Pipes())
 	bot.Command(convertBase, "base", "base <from>to<to> <num>  -- "+
-		"converts <num> from base <from> to base <to>")
+		"converts <num> from base <from> to base <to>", bot.Pipes())
 	bot.Command(length, "length", "length <string>  -- "+
-		"prints the length of <string>")
+		"prints the length of <string>", bot.Pipes())
 }
@@ -0,0 +1,37 @@
+package calcdriver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestCommands(t *testing.T) {
+	bottest.Init()
+	Init()
+	b := bottest.New()
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"calc 1 + 2 * 3", []string{"tester: 1 + 2 * 3 = 7"}},
+		{"chr 65", []string{"tester: chr(65) is A, U+0041, '0x41'"}},
+		{"ord é", []string{"tester: ord(é) is 233, U+00E9, '0xc3 0xa9'"}},
+		{"base 10to16 255", []string{"tester: 255 in base 10 is ff in base 16"}},
+		{"base 10to99 255", []string{"tester: Either 10 or 99 is a bad base, must be in range 2-36"}},
+		{"length hello world", []string{"tester: 'hello world' is 11 characters long"}},
+		{"calc 2**20 | base 10to16", []string{"tester: 1048576 in base 10 is 100000 in base 16"}},
+		{"calc 15 * 17 | base 10to16 | length", []string{"tester: 'ff' is 2 characters long"}},
+		// Errors stop a pipeline rather than being piped on.
+		{"base 10to99 255 | length", []string{"tester: Either 10 or 99 is a bad base, must be in range 2-36"}},
+		{"netmask 10.0.0.1/24", []string{"tester: 10.0.0.1/24 is in the range " +
+			"10.0.0.0-10.0.0.255 and has the netmask 255.255.255.0"}},
+	}
+	for i, test := range tests {
+		b.Say("tester", "#chan", "sp0rkle: "+test.in)
+		if out := b.Texts(); !reflect.DeepEqual(out, test.out) {
+			t.Errorf("%d: %q: exp %q got %q", i, test.in, test.out, out)
+		}
+	}
+}
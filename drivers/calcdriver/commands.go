@@ -24,10 +24,14 @@ func calculate(ctx *bot.Context) {
 		tm["result"] = res
 	}
 	if num, err := calc.Calc(maths, tm); err == nil {
-		ctx.ReplyN("%s = %g", maths, num)
+		if ctx.Piped() {
+			ctx.Reply("%s", strconv.FormatFloat(num, 'f', -1, 64))
+		} else {
+			ctx.ReplyN("%s = %g", maths, num)
+		}
 		tm[nick] = num
 	} else {
-		ctx.ReplyN("%s error while parsing %s", err, maths)
+		ctx.Fail("%s error while parsing %s", err, maths)
 	}
 }
 
@@ -44,7 +48,7 @@ func date(ctx *bot.Context) {
 	if tstr != "" {
 		var err error
 		if tm, err = datetime.ParseZ(tstr, zone); err != nil {
-			ctx.ReplyN("Couldn't parse time string %q: %v.", tstr, err)
+			ctx.Fail("Couldn't parse time string %q: %v.", tstr, err)
 			return
 		}
 	}
@@ -60,7 +64,7 @@ func netmask(ctx *bot.Context) {
 		// Assume we have netmask ip nm
 		ctx.ReplyN("%s", parseMask(s[0], s[1]))
 	} else {
-		ctx.ReplyN("bad netmask args: %s", ctx.Text())
+		ctx.Fail("bad netmask args: %s", ctx.Text())
 	}
 }
 
@@ -129,7 +133,7 @@ func chr(ctx *bot.Context) {
 	// handles decimal, hex, and octal \o/
 	i, err := strconv.ParseInt(chr, 0, 0)
 	if err != nil {
-		ctx.ReplyN("Couldn't parse %s as an integer: %s", chr, err)
+		ctx.Fail("Couldn't parse %s as an integer: %s", chr, err)
 		return
 	}
 	ctx.ReplyN("chr(%s) is %c, %U, '%s'", chr, i, i, utf8repr(rune(i)))
@@ -139,7 +143,7 @@ func ord(ctx *bot.Context) {
 	ord := ctx.Text()
 	r, _ := utf8.DecodeRuneInString(ord)
 	if r == utf8.RuneError {
-		ctx.ReplyN("Couldn't parse a utf8 rune from %s", ord)
+		ctx.Fail("Couldn't parse a utf8 rune from %s", ord)
 		return
 	}
 	ctx.ReplyN("ord(%c) is %d, %U, '%s'", r, r, r, utf8repr(r))
@@ -159,21 +163,25 @@ func convertBase(ctx *bot.Context) {
 	s := strings.Split(ctx.Text(), " ")
 	fromto := strings.Split(s[0], "to")
 	if len(fromto) != 2 {
-		ctx.ReplyN("Specify base as: <from base>to<to base>")
+		ctx.Fail("Specify base as: <from base>to<to base>")
 		return
 	}
 	from, errf := strconv.Atoi(fromto[0])
 	to, errt := strconv.Atoi(fromto[1])
 	if errf != nil || errt != nil ||
 		from < 2 || from > 36 || to < 2 || to > 36 {
-		ctx.ReplyN("Either %s or %s is a bad base, must be in range 2-36",
+		ctx.Fail("Either %s or %s is a bad base, must be in range 2-36",
 			fromto[0], fromto[1])
 
 		return
 	}
 	i, err := strconv.ParseInt(s[1], from, 64)
 	if err != nil {
-		ctx.ReplyN("Couldn't parse %s as a base %d integer", s[1], from)
+		ctx.Fail("Couldn't parse %s as a base %d integer", s[1], from)
+		return
+	}
+	if ctx.Piped() {
+		ctx.Reply("%s", strconv.FormatInt(i, to))
 		return
 	}
 	ctx.ReplyN("%s in base %d is %s in base %d",
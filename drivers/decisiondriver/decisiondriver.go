@@ -17,12 +17,20 @@ import (
 
 var ErrUnbalanced = errors.New("unbalanced quotes")
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "decisiondriver",
+		Description: "Makes decisions and picks random numbers.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	bot.Rewrite(randPlugin)
 	bot.Rewrite(decidePlugin)
 
 	bot.Command(randCmd, "rand", "rand <range>  -- "+
-		"choose a random number in range [lo-]hi")
+		"choose a random number in range [lo-]hi", bot.Pipes())
 	bot.Command(decideCmd, "decide", "decide <options>  -- "+
 		"choose one of the (space, pipe, quote) delimited options at random")
 	bot.Command(decideCmd, "choose", "choose <options>  -- "+
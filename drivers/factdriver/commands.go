@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/factoids"
 	"github.com/fluffle/sp0rkle/util"
 	"github.com/fluffle/sp0rkle/util/datetime"
 )
@@ -43,7 +44,7 @@ func chance(ctx *bot.Context) {
 	// Retrieve last seen ObjectId, replace with ""
 	ls := LastSeen(ctx.Target(), "")
 	// ok, we're good to update the chance.
-	fact := fc.GetById(ls)
+	fact := fc.GetById(ctx.Ctx(), ls)
 	if !fact.Exists() {
 		ctx.ReplyN("Whatever that was, I've already forgotten it.")
 		return
@@ -54,7 +55,7 @@ func chance(ctx *bot.Context) {
 	// Update the Modified field
 	fact.Modify(ctx.Storable())
 	// And store the new factoid data
-	if err := fc.Put(fact); err != nil {
+	if err := fc.Put(ctx.Ctx(), fact); err != nil {
 		ctx.ReplyN("I failed to replace '%s': %s", fact.Key, err)
 		return
 	}
@@ -103,7 +104,7 @@ func edit(ctx *bot.Context) {
 	}
 	// Retrieve last seen ObjectId, replace with ""
 	ls := LastSeen(ctx.Target(), "")
-	fact := fc.GetById(ls)
+	fact := fc.GetById(ctx.Ctx(), ls)
 	if !fact.Exists() {
 		ctx.ReplyN("I've forgotten what we were talking about, sorry!")
 		return
@@ -111,10 +112,11 @@ func edit(ctx *bot.Context) {
 	old := fact.Value
 	fact.Value = rx.ReplaceAllString(old, rp)
 	fact.Modify(ctx.Storable())
-	if err := fc.Put(fact); err != nil {
+	if err := fc.Put(ctx.Ctx(), fact); err != nil {
 		ctx.ReplyN("I failed to replace '%s': %s", fact.Key, err)
 		return
 	}
+	ctx.Audit(factoids.COLLECTION, fact.Key, old)
 	ctx.ReplyN("'%s' was '%s', is now '%s'.",
 		fact.Key, old, fact.Value)
 }
@@ -123,15 +125,16 @@ func edit(ctx *bot.Context) {
 func forget(ctx *bot.Context) {
 	// Get fresh state on the last seen factoid.
 	ls := LastSeen(ctx.Target(), "")
-	fact := fc.GetById(ls)
+	fact := fc.GetById(ctx.Ctx(), ls)
 	if !fact.Exists() {
 		ctx.ReplyN("Whatever that was, I've already forgotten it.")
 		return
 	}
-	if err := fc.Del(fact); err != nil {
+	if err := fc.Del(ctx.Ctx(), fact); err != nil {
 		ctx.ReplyN("I failed to forget '%s': %s", fact.Key, err)
 		return
 	}
+	ctx.Audit(factoids.COLLECTION, fact.Key, fact.Value)
 	ctx.ReplyN("I forgot that '%s' was '%s'.",
 		fact.Key, fact.Value)
 }
@@ -139,7 +142,7 @@ func forget(ctx *bot.Context) {
 // Factoid info: 'fact info key' => some information about key
 func info(ctx *bot.Context) {
 	key := ToKey(ctx.Text(), false)
-	count := fc.GetCount(key)
+	count := fc.GetCount(ctx.Ctx(), key)
 	if count == 0 {
 		ctx.ReplyN("I don't know anything about '%s'.", key)
 		return
@@ -151,7 +154,7 @@ func info(ctx *bot.Context) {
 		msgs = append(msgs, fmt.Sprintf("I know %d things about '%s'.",
 			count, key))
 	}
-	created, modified, accessed := fc.GetLast(key)
+	created, modified, accessed := fc.GetLast(ctx.Ctx(), key)
 	if created != nil && modified != nil && accessed != nil {
 		c := created.Created
 		msgs = append(msgs, "A factoid")
@@ -169,7 +172,7 @@ func info(ctx *bot.Context) {
 		msgs = append(msgs, fmt.Sprintf("and accessed on %s by %s.",
 			datetime.Format(a.Timestamp), a.Nick))
 	}
-	if info := fc.InfoMR(key); info != nil {
+	if info := fc.InfoMR(ctx.Ctx(), key); info != nil {
 		if key == "" {
 			msgs = append(msgs, "These factoids have")
 		} else {
@@ -185,21 +188,17 @@ func info(ctx *bot.Context) {
 // Factoid literal: 'literal key' => info about factoid
 func literal(ctx *bot.Context) {
 	key := ToKey(ctx.Text(), false)
-	if count := fc.GetCount(key); count == 0 {
+	if count := fc.GetCount(ctx.Ctx(), key); count == 0 {
 		ctx.ReplyN("I don't know anything about '%s'.", key)
 		return
-	} else if count > 10 && ctx.Public() {
-		ctx.ReplyN("I know too much about '%s', ask me privately.", key)
-		return
 	}
 
-	if facts := fc.GetAll(key); facts != nil {
-		for _, fact := range facts {
-			// Use Privmsg directly here so that the results aren't output
-			// via the plugin system and contain the literal data.
-			ctx.Privmsg(ctx.Target(), fmt.Sprintf(
-				"[%3.0f%%] %s", fact.Chance*100, fact.Value))
+	if facts := fc.GetAll(ctx.Ctx(), key); facts != nil {
+		lines := make([]string, len(facts))
+		for i, fact := range facts {
+			lines[i] = fmt.Sprintf("[%3.0f%%] %s", fact.Chance*100, fact.Value)
 		}
+		ctx.ReplyPagedLiteral("", lines)
 	} else {
 		ctx.ReplyN("Something literally went wrong :-(")
 	}
@@ -208,7 +207,7 @@ func literal(ctx *bot.Context) {
 // Factoid replace: 'replace that with' => updates lastSeen[chan]
 func replace(ctx *bot.Context) {
 	ls := LastSeen(ctx.Target(), "")
-	fact := fc.GetById(ls)
+	fact := fc.GetById(ctx.Ctx(), ls)
 	if fact == nil {
 		ctx.ReplyN("Whatever that was, I've already forgotten it.")
 		return
@@ -220,17 +219,18 @@ func replace(ctx *bot.Context) {
 	// Update the Modified field
 	fact.Modify(ctx.Storable())
 	// And store the new factoid data
-	if err := fc.Put(fact); err != nil {
+	if err := fc.Put(ctx.Ctx(), fact); err != nil {
 		ctx.ReplyN("I failed to replace '%s': %s", fact.Key, err)
 		return
 	}
+	ctx.Audit(factoids.COLLECTION, fact.Key, old)
 	ctx.ReplyN("'%s' was '%s', now is '%s'.",
 		fact.Key, old, fact.Value)
 }
 
 // Factoid search: 'fact search regexp' => list of possible key matches
 func search(ctx *bot.Context) {
-	keys := fc.GetKeysMatching(ctx.Text())
+	keys := fc.GetKeysMatching(ctx.Ctx(), ctx.Text())
 	if keys == nil || len(keys) == 0 {
 		ctx.ReplyN("I couldn't think of anything matching '%s'.",
 			ctx.Text())
@@ -238,18 +238,9 @@ func search(ctx *bot.Context) {
 		return
 	}
 	// RESULTS.
-	count := len(keys)
-	if count > 10 {
-		res := strings.Join(keys[:10], "', '")
-		ctx.ReplyN(
-			"I found %d keys matching '%s', here's the first 10: '%s'.",
-			count, ctx.Text(), res)
-
-	} else {
-		res := strings.Join(keys, "', '")
-		ctx.ReplyN(
-			"I found %d keys matching '%s', here they are: '%s'.",
-			count, ctx.Text(), res)
-
+	for i := range keys {
+		keys[i] = "'" + keys[i] + "'"
 	}
+	ctx.ReplyPagedList(fmt.Sprintf("I found %d keys matching '%s': ",
+		len(keys), ctx.Text()), keys, ", ")
 }
@@ -3,7 +3,6 @@ package factdriver
 import (
 	"strings"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/factoids"
 	"github.com/fluffle/sp0rkle/util"
@@ -18,11 +17,19 @@ var fc *factoids.Collection
 // Do this on a per-channel basis to avoid (too much) confusion.
 var lastSeen = map[string]bson.ObjectId{}
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "factdriver",
+		Description: "Stores and looks up factoids.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	fc = factoids.Init()
 
-	bot.Handle(insert, client.PRIVMSG)
-	bot.Handle(lookup, client.PRIVMSG, client.ACTION)
+	bot.Handle(insert, bot.PRIVMSG)
+	bot.Handle(lookup, bot.PRIVMSG, bot.ACTION)
 
 	bot.Rewrite(replaceIdentifiers)
 
@@ -31,9 +38,11 @@ func Init() {
 	bot.Command(edit, "that =~",
 		"=~ s/regex/replacement/ -- Edits the last factoid value using regex.")
 	bot.Command(forget, "delete that",
-		"delete  -- Forgets the last displayed factoid value.")
+		"delete  -- Forgets the last displayed factoid value.",
+		bot.Requires(bot.Trusted))
 	bot.Command(forget, "forget that",
-		"forget  -- Forgets the last displayed factoid value.")
+		"forget  -- Forgets the last displayed factoid value.",
+		bot.Requires(bot.Trusted))
 	bot.Command(info, "fact info",
 		"fact info <key>  -- Displays some stats about factoid <key>.")
 	bot.Command(literal, "literal",
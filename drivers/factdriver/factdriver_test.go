@@ -0,0 +1,60 @@
+package factdriver
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "factdriver")
+	if err != nil {
+		panic(err)
+	}
+	if err := bottest.UseDB(dir); err != nil {
+		panic(err)
+	}
+	bottest.Init()
+	Init()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestFactoids(t *testing.T) {
+	b := bottest.New()
+	tests := []struct {
+		text string
+		exp  []bottest.Sent
+	}{
+		{"sp0rkle: greeting := hello, $nick", []bottest.Sent{{bot.PRIVMSG,
+			"#chan", "tester: Woo, I now know 1 things about 'greeting'."}}},
+		{"sp0rkle: wave := <me>waves at $chan", []bottest.Sent{{bot.PRIVMSG,
+			"#chan", "tester: Woo, I now know 1 things about 'wave'."}}},
+		{"sp0rkle: sky :is blue", []bottest.Sent{{bot.PRIVMSG,
+			"#chan", "tester: Woo, I now know 1 things about 'sky'."}}},
+		// Factoids are looked up whether or not the bot is addressed,
+		// and identifiers in their values are replaced.
+		{"greeting", []bottest.Sent{{bot.PRIVMSG, "#chan", "hello, tester"}}},
+		{"sp0rkle: wave", []bottest.Sent{{bot.ACTION, "#chan", "waves at #chan"}}},
+		{"sky?", []bottest.Sent{{bot.PRIVMSG, "#chan", "sky is blue"}}},
+		{"sp0rkle: literal greeting", []bottest.Sent{{bot.PRIVMSG,
+			"#chan", "[100%] hello, $nick"}}},
+		{"nothing to see here", nil},
+		// Addressed lines that aren't factoids may be mistyped commands,
+		// but not if they're just as likely to be chatter.
+		{"sp0rkle: literl greeting", []bottest.Sent{{bot.PRIVMSG,
+			"#chan", "tester: Did you mean 'literal'?"}}},
+		{"sp0rkle: nice", nil},
+		{"sp0rkle: sat down", nil},
+	}
+	for i, test := range tests {
+		b.Say("tester", "#chan", test.text)
+		if sent := b.Sent(); !reflect.DeepEqual(sent, test.exp) {
+			t.Errorf("Factoids(%d) %q: exp %v got %v", i, test.text, test.exp, sent)
+		}
+	}
+}
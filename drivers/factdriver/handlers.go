@@ -1,10 +1,10 @@
 package factdriver
 
 import (
+	"context"
 	"math/rand"
 	"strings"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/factoids"
 	"github.com/fluffle/sp0rkle/util"
@@ -33,15 +33,15 @@ func insert(ctx *bot.Context) {
 
 	// The "randomwoot" factoid contains random positive phrases for success.
 	joy := "Woo"
-	if rand := fc.GetPseudoRand("randomwoot"); rand != nil {
+	if rand := fc.GetPseudoRand(ctx.Ctx(), "randomwoot"); rand != nil {
 		joy = rand.Value
 	}
 
-	if err := fc.Put(fact); err != nil {
+	if err := fc.Put(ctx.Ctx(), fact); err != nil {
 		ctx.ReplyN("Error storing factoid: %s.", err)
 		return
 	}
-	count := fc.GetCount(key)
+	count := fc.GetCount(ctx.Ctx(), key)
 	LastSeen(ctx.Target(), fact.Id())
 	ctx.ReplyN("%s, I now know %d things about '%s'.", joy, count, key)
 }
@@ -51,15 +51,19 @@ func lookup(ctx *bot.Context) {
 	key := ToKey(ctx.Text(), !ctx.Addressed)
 	var fact *factoids.Factoid
 
-	if fact = fc.GetPseudoRand(key); fact == nil && ctx.Cmd == client.ACTION {
+	if fact = fc.GetPseudoRand(ctx.Ctx(), key); fact == nil && ctx.Cmd == bot.ACTION {
 		// Support sp0rkle's habit of stripping off it's own nick
 		// but only for actions, not privmsgs.
 		if strings.HasSuffix(key, ctx.Me()) {
 			key = strings.TrimSpace(key[:len(key)-len(ctx.Me())])
-			fact = fc.GetPseudoRand(key)
+			fact = fc.GetPseudoRand(ctx.Ctx(), key)
 		}
 	}
 	if fact == nil {
+		// Maybe they were trying to run a command?
+		if !util.IsFactoidAddition(ctx.Text()) {
+			bot.Suggest(ctx)
+		}
 		return
 	}
 	// Chance is used to limit the rate of factoid replies for things
@@ -71,19 +75,19 @@ func lookup(ctx *bot.Context) {
 		// To avoid making this too spammy, forcibly limit the chance to 40%.
 		chance = 0.4
 	}
-	if rand.Float64() < chance {
+	if rand.Float64() < chance && !ctx.Limited("factoid") {
 		// Store this as the last seen factoid
 		LastSeen(ctx.Target(), fact.Id())
 		// Update the Accessed field
 		// TODO(fluffle): fd should take care of updating Accessed internally
 		fact.Access(ctx.Storable())
 		// And store the new factoid data
-		if err := fc.Put(fact); err != nil {
+		if err := fc.Put(ctx.Ctx(), fact); err != nil {
 			ctx.ReplyN("I failed to update '%s' (%s): %s ",
 				fact.Key, fact.Id(), err)
 
 		}
-		recurse(fact, map[string]bool{key: true})
+		recurse(ctx.Ctx(), fact, map[string]bool{key: true})
 		switch fact.Type {
 		case factoids.F_ACTION:
 			ctx.Do("%s", fact.Value)
@@ -94,7 +98,7 @@ func lookup(ctx *bot.Context) {
 }
 
 // Recursively resolve pointers to other factoids
-func recurse(fact *factoids.Factoid, keys map[string]bool) {
+func recurse(ctx context.Context, fact *factoids.Factoid, keys map[string]bool) {
 	val := fact.Value
 	key, start, end := util.FactPointer(val)
 	if key == "" {
@@ -105,19 +109,19 @@ func recurse(fact *factoids.Factoid, keys map[string]bool) {
 		return
 	}
 	keys[key] = true
-	if f2 := fc.GetPseudoRand(key); f2 != nil {
+	if f2 := fc.GetPseudoRand(ctx, key); f2 != nil {
 		fact.Value = val[:start] + f2.Value + val[end:]
 		if start == 0 && fact.Type != f2.Type {
 			// Propagate change of factoid type when the pointer
 			// is at the beginning of the string.
 			fact.Type = f2.Type
 		}
-		recurse(fact, keys)
+		recurse(ctx, fact, keys)
 		return
 	}
 	// if we get here, we found a pointer key but no matching factoid
 	// so recurse on the stuff after that key *only* to avoid loops.
 	fact.Value = val[end:]
-	recurse(fact, keys)
+	recurse(ctx, fact, keys)
 	fact.Value = val[:end] + fact.Value
 }
@@ -5,7 +5,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/util"
 )
@@ -21,7 +20,7 @@ func TestIdentifiers(t *testing.T) {
 	flag.Set("timezone", "UTC")
 	ts := time.Unix(1234567890, 0).UTC()
 	ctx := &bot.Context{
-		Line: &client.Line{
+		Line: &bot.Line{
 			Nick: "tester", Ident: "tests", Host: "goirc.github.com",
 			Src: "tester!tests@goirc.github.com", Cmd: "PRIVMSG",
 			Raw:  ":tester!tests@goirc.github.com PRIVMSG #test :I love testing.",
@@ -5,9 +5,9 @@ import (
 )
 
 func karmaCmd(ctx *bot.Context) {
-	if k := kc.KarmaFor(ctx.Text()); k != nil {
+	if k := kc.KarmaFor(ctx.Ctx(), ctx.Text()); k != nil {
 		ctx.ReplyN("%s", k)
 	} else {
-		ctx.ReplyN("No karma found for '%s'", ctx.Text())
+		ctx.Fail("No karma found for '%s'", ctx.Text())
 	}
 }
@@ -10,7 +10,7 @@ func recordKarma(ctx *bot.Context) {
 	// and there could be multiple occurrences of it in a string.
 	nick, _ := ctx.Storable()
 	for _, kt := range karmaThings(ctx.Text()) {
-		k := kc.KarmaFor(kt.thing)
+		k := kc.KarmaFor(ctx.Ctx(), kt.thing)
 		if k == nil {
 			k = karma.New(kt.thing)
 		}
@@ -19,7 +19,7 @@ func recordKarma(ctx *bot.Context) {
 		} else {
 			k.Minus(nick)
 		}
-		if err := kc.Put(k); err != nil {
+		if err := kc.Put(ctx.Ctx(), k); err != nil {
 			ctx.Reply("Failed to insert Karma: %s", err)
 		}
 	}
@@ -4,7 +4,6 @@ import (
 	"unicode"
 	"unicode/utf8"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/karma"
 	"github.com/fluffle/sp0rkle/util"
@@ -12,13 +11,21 @@ import (
 
 var kc *karma.Collection
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "karmadriver",
+		Description: "Tracks karma from thing++ and thing--.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	kc = karma.Init()
 
-	bot.Handle(recordKarma, client.PRIVMSG, client.ACTION)
+	bot.Handle(recordKarma, bot.PRIVMSG, bot.ACTION)
 
 	bot.Command(karmaCmd, "karma", "karma <thing>  -- "+
-		"Retrieve the karma score of <thing>.")
+		"Retrieve the karma score of <thing>.", bot.Pipes())
 }
 
 type kt struct {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/collections/markov"
 	chain "github.com/fluffle/sp0rkle/util/markov"
 )
 
@@ -15,46 +16,45 @@ func enableMarkov(ctx *bot.Context) {
 
 func disableMarkov(ctx *bot.Context) {
 	key := strings.ToLower(ctx.Nick)
+	prior := conf.Ns(markovNs).String(key)
 	conf.Ns(markovNs).Delete(key)
 	if err := mc.ClearTag("user:" + key); err != nil {
 		ctx.ReplyN("Failed to clear tag: %s", err)
 		return
 	}
+	// The chain itself is too big to keep, so just record that it's gone.
+	ctx.Audit(markov.COLLECTION, "user:"+key, prior)
 	ctx.ReplyN("Sure, bro, I'll stop.")
 }
 
 func randomCmd(ctx *bot.Context) {
-	if len(ctx.Text()) == 0 {
-		ctx.ReplyN("Be who? Your mum?")
-		return
-	}
-	whom := strings.ToLower(strings.Fields(ctx.Text())[0])
+	whom := strings.ToLower(ctx.Params.String("nick"))
 	if whom == strings.ToLower(ctx.Me()) {
-		ctx.ReplyN("Ha, you're funny. No, wait. Retarded... I meant retarded.")
+		ctx.Fail("Ha, you're funny. No, wait. Retarded... I meant retarded.")
 		return
 	}
 	if !shouldMarkov(whom) {
 		if whom == strings.ToLower(ctx.Nick) {
-			ctx.ReplyN("You're not recording markov data. " +
+			ctx.Fail("You're not recording markov data. " +
 				"Use 'markov me' to enable collection.")
 		} else {
-			ctx.ReplyN("Not recording markov data for %s.", ctx.Text())
+			ctx.Fail("Not recording markov data for %s.", ctx.Text())
 		}
 		return
 	}
-	source := mc.Source("user:" + whom)
+	source := mc.Source(ctx.Ctx(), "user:"+whom)
 	if out, err := chain.Sentence(source); err == nil {
 		ctx.Reply("%s would say: %s", ctx.Text(), out)
 	} else {
-		ctx.ReplyN("markov error: %v", err)
+		ctx.Fail("markov error: %v", err)
 	}
 }
 
 func insult(ctx *bot.Context) {
-	source := mc.Source("tag:insult")
+	source := mc.Source(ctx.Ctx(), "tag:insult")
 	whom, lc := ctx.Text(), strings.ToLower(ctx.Text())
 	if lc == strings.ToLower(ctx.Me()) || lc == "yourself" {
-		ctx.ReplyN("Ha, you're funny. No, wait. Retarded... I meant retarded.")
+		ctx.Fail("Ha, you're funny. No, wait. Retarded... I meant retarded.")
 		return
 	}
 	if lc == "me" {
@@ -67,7 +67,7 @@ func insult(ctx *bot.Context) {
 			ctx.Reply("%s", out)
 		}
 	} else {
-		ctx.ReplyN("markov error: %v", err)
+		ctx.Fail("markov error: %v", err)
 	}
 }
 
@@ -79,7 +79,7 @@ func learn(ctx *bot.Context) {
 	}
 
 	// Prepending "tag:" prevents people from learning as "user:foo".
-	mc.AddSentence(s[1], "tag:"+s[0])
+	mc.AddSentence(ctx.Ctx(), s[1], "tag:"+s[0])
 	if ctx.Public() {
 		// Allow large-scale learning via privmsg by not replying there.
 		ctx.ReplyN("Ta. You're a fount of knowledge, you are.")
@@ -3,7 +3,6 @@ package markovdriver
 import (
 	"strings"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/conf"
 )
@@ -18,10 +17,10 @@ func recordMarkov(ctx *bot.Context) {
 		// Only markov lines that are public, not addressed to us,
 		// and from markov-enabled nicks
 		switch ctx.Cmd {
-		case client.PRIVMSG:
-			mc.AddSentence(ctx.Text(), "user:"+whom)
-		case client.ACTION:
-			mc.AddAction(ctx.Text(), "user:"+whom)
+		case bot.PRIVMSG:
+			mc.AddSentence(ctx.Ctx(), ctx.Text(), "user:"+whom)
+		case bot.ACTION:
+			mc.AddAction(ctx.Ctx(), ctx.Text(), "user:"+whom)
 		}
 	}
 }
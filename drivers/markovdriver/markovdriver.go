@@ -1,7 +1,6 @@
 package markovdriver
 
 import (
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/markov"
 )
@@ -10,10 +9,18 @@ const markovNs = "markov"
 
 var mc *markov.Collection
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "markovdriver",
+		Description: "Learns how people talk and imitates them.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	mc = markov.Init()
 
-	bot.Handle(recordMarkov, client.PRIVMSG, client.ACTION)
+	bot.Handle(recordMarkov, bot.PRIVMSG, bot.ACTION)
 	bot.Rewrite(insultPlugin)
 
 	bot.Command(enableMarkov, "markov me", "markov me  -- "+
@@ -23,8 +30,10 @@ func Init() {
 	bot.Command(disableMarkov, "don't markov me, bro", "don't markov me  -- "+
 		"Disable (and delete) recording of your public messages.")
 	bot.Command(randomCmd, "markov", "markov <nick>  -- "+
-		"Generate random sentence for given <nick>.")
-	bot.Command(insult, "insult", "insult <nick>  -- Insult <nick> at random.")
+		"Generate random sentence for given <nick>.", bot.Limit("markov"),
+		bot.Takes(bot.NickArg("nick")), bot.Pipes())
+	bot.Command(insult, "insult", "insult <nick>  -- Insult <nick> at random.",
+		bot.Limit("insult"), bot.Pipes())
 	bot.Command(learn, "learn", "learn <tag> <sentence>  -- "+
 		"Learns a sentence for a particular.")
 }
@@ -8,7 +8,7 @@ import (
 
 func insultPlugin(in string, ctx *bot.Context) string {
 	f := func(string) string {
-		source := mc.Source("tag:insult")
+		source := mc.Source(ctx.Ctx(), "tag:insult")
 		if insult, err := chain.Sentence(source); err == nil {
 			return insult
 		}
@@ -52,7 +52,7 @@ func githubCreateIssue(ctx *bot.Context) {
 	if len(s) == 2 {
 		req.Body = &s[1]
 	}
-	issue, _, err := gh.Issues.Create(bot.Ctx(), githubUser, githubRepo, req)
+	issue, _, err := gh.Issues.Create(ctx.Ctx(), githubUser, githubRepo, req)
 	if err != nil {
 		ctx.ReplyN("Error creating issue: %v", err)
 		return
@@ -76,7 +76,7 @@ func githubUpdateIssue(ctx *bot.Context) {
 	}
 	text = fmt.Sprintf("<%s/%s> %s", ctx.Nick, ctx.Target(), text)
 	comm, _, err := gh.Issues.CreateComment(
-		bot.Ctx(), githubUser, githubRepo, issue,
+		ctx.Ctx(), githubUser, githubRepo, issue,
 		&github.IssueComment{Body: &text})
 	if err != nil {
 		ctx.ReplyN("Error creating issue comment: %v", err)
@@ -102,7 +102,7 @@ func githubWatcher(ctx *bot.Context) {
 	issue := int(l.Number())
 
 	labels, _, err := gh.Issues.ListLabelsByIssue(
-		bot.Ctx(), githubUser, githubRepo, issue, &github.ListOptions{})
+		ctx.Ctx(), githubUser, githubRepo, issue, &github.ListOptions{})
 	if err != nil {
 		logging.Error("Error getting labels for issue %d: %v", issue, err)
 		return
@@ -112,7 +112,7 @@ func githubWatcher(ctx *bot.Context) {
 		if len(kv) == 2 && kv[0] == "nick" {
 			logging.Debug("Recording tell for %s about issue %d.", kv[1], issue)
 			r := reminders.NewTell("that "+text, bot.Nick(kv[1]), "github", "")
-			if err := rc.Put(r); err != nil {
+			if err := rc.Put(ctx.Ctx(), r); err != nil {
 				logging.Error("Error inserting github tell: %v", err)
 			}
 		}
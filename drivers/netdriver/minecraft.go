@@ -30,16 +30,13 @@ const (
 
 var (
 	mcConf      conf.Namespace
+	mcState     = &mcStatus{}
 	mcHandshake = []byte("\xfe\xfd\x09\x00\x00\x00\x00")
 	mcGetStatus = []byte("\xfe\xfd\x00\x00\x00\x00\x00")
 )
 
 func mcSet(ctx *bot.Context) {
-	kv := strings.Fields(ctx.Text())
-	if len(kv) < 2 {
-		ctx.ReplyN("I need a key and a value.")
-		return
-	}
+	kv := []string{ctx.Params.String("key"), ctx.Params.String("value")}
 	switch kv[0] {
 	case mcServer:
 		mcConf.String(mcServer, kv[1])
@@ -63,18 +60,23 @@ func mcSet(ctx *bot.Context) {
 	ctx.ReplyN("Set %s to '%s'", kv[0], kv[1])
 }
 
-func (mcs *mcStatus) Poll(ctxs []*bot.Context) {
+func (mcs *mcStatus) Poll(ctxs []*bot.Context) error {
 	srv := mcConf.String(mcServer)
+	if srv == "" {
+		// Nothing to poll until "mc set server" is used.
+		*mcs = mcStatus{}
+		return nil
+	}
 	logging.Debug("polling minecraft server at %s", srv)
 	st, err := pollServer(srv)
 	if err != nil {
-		logging.Error("minecraft poll failed: %v", err)
-		return
+		return fmt.Errorf("minecraft poll failed: %v", err)
 	}
 	*mcs = *st
 	for _, ctx := range ctxs {
 		ctx.Topic(mcConf.String(mcChan))
 	}
+	return nil
 }
 
 func (mcs *mcStatus) Start() { /* empty */ }
@@ -83,9 +85,15 @@ func (mcs *mcStatus) Tick() time.Duration {
 	return time.Duration(mcConf.Int(mcFreq)) * time.Minute
 }
 
+// mcTopic is a named function so policies can refer to it.
+func mcTopic(ctx *bot.Context) {
+	mcState.Topic(ctx)
+}
+
 func (mcs *mcStatus) Topic(ctx *bot.Context) {
 	ch := mcConf.String(mcChan)
-	if ctx.Args[1] != ch {
+	if ctx.Args[1] != ch || mcs.version == "" {
+		// Not our channel, or we haven't polled the server successfully yet.
 		return
 	}
 	topic := ctx.Text()
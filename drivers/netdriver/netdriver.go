@@ -1,11 +1,11 @@
 package netdriver
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
+	"time"
 
-	"github.com/fluffle/goirc/client"
-	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/conf"
 	"github.com/fluffle/sp0rkle/collections/pushes"
@@ -18,8 +18,12 @@ var pc *pushes.Collection
 var rc *reminders.Collection
 var gh *github.Client
 
-func get(req string) ([]byte, error) {
-	res, err := http.Get(req)
+func get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -27,34 +31,35 @@ func get(req string) ([]byte, error) {
 	return ioutil.ReadAll(res.Body)
 }
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "netdriver",
+		Description: "Looks things up online and files GitHub issues.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	bot.Command(urbanDictionary, "ud", "ud <term>  -- "+
-		"Look up <term> on UrbanDictionary.")
+		"Look up <term> on UrbanDictionary.", bot.Limit("ud"), bot.Pipes(),
+		// Nobody wants to wait long for a definition.
+		bot.Timeout(10*time.Second))
 
+	// The poller does nothing until a server is set with "mc set server".
+	// Use "poller stop minecraft" and "poller start minecraft"
+	// to en/disable polling at runtime.
 	mcConf = conf.Ns("mc")
-	srv := mcConf.String(mcServer)
-	if srv != "" {
-		if st, err := pollServer(srv); err == nil {
-			logging.Info("Starting MC poller for '%s'", srv)
-			bot.Poll(st)
-			bot.Handle(func(ctx *bot.Context) {
-				st.Topic(ctx)
-			}, "332")
-		} else {
-			logging.Error("Not starting MC poller: %v", err)
-		}
-	}
+	bot.Poll("minecraft", mcState)
+	bot.Handle(mcTopic, "332")
 	bot.Command(mcSet, "mc set", "mc set <key> <value>  -- "+
-		"Set minecraft server polling config vars.")
-	// TODO(fluffle): Polling can only be en/disabled at reconnect.
-	//	bot.Command(mcPoll, "mc poll", "mc poll start|stop  -- "+
-	//		"Enable or disable minecraft server polling.")
+		"Set minecraft server polling config vars.", bot.Requires(bot.Admin),
+		bot.Takes(bot.WordArg("key"), bot.WordArg("value")))
 
 	if *githubToken != "" {
 		rc = reminders.Init()
 		gh = githubClient()
 
-		bot.Handle(githubWatcher, client.PRIVMSG)
+		bot.Handle(githubWatcher, bot.PRIVMSG)
 
 		bot.Command(githubCreateIssue, "file bug:", "file bug: <title>. "+
 			"<descriptive body>  -- Files a bug on GitHub. Abusers will be hurt.")
@@ -73,11 +78,12 @@ func Init() {
 		bot.Command(pushDisable, "push disable", "push disable  -- "+
 			"Disable pushbullet notifications and delete tokens.")
 		bot.Command(pushConfirm, "push auth", "push auth <pin>  -- "+
-			"Confirm pushed PIN to finish pushbullet auth dance.")
-		bot.Command(pushAddAlias, "push add alias", "push add alias  -- "+
-			"Add a push alias for your nick.")
-		bot.Command(pushDelAlias, "push del alias", "push del alias  -- "+
-			"Delete a push alias for your nick.")
+			"Confirm pushed PIN to finish pushbullet auth dance.",
+			bot.Takes(bot.WordArg("pin")))
+		bot.Command(pushAddAlias, "push add alias", "push add alias <alias>  -- "+
+			"Add a push alias for your nick.", bot.Takes(bot.NickArg("alias")))
+		bot.Command(pushDelAlias, "push del alias", "push del alias <alias>  -- "+
+			"Delete a push alias for your nick.", bot.Takes(bot.NickArg("alias")))
 
 		http.HandleFunc("/oauth/auth", pushAuthHTTP)
 		http.HandleFunc("/oauth/device", pushDeviceHTTP)
@@ -33,7 +33,7 @@ func pushDeviceURL(state string) string {
 // a confirmation notification to the chosen device with a 6
 // digit pin and require that they msg that to us via IRC.
 func pushEnable(ctx *bot.Context) {
-	if s := pc.GetByNick(ctx.Nick, true); s != nil {
+	if s := pc.GetByNick(ctx.Ctx(), ctx.Nick, true); s != nil {
 		if s.HasAlias(ctx.Nick) {
 			ctx.ReplyN("Your nick is already used as an alias for %s.", s.Nick)
 			return
@@ -43,11 +43,11 @@ func pushEnable(ctx *bot.Context) {
 			return
 		}
 		ctx.Privmsg(ctx.Nick, "Hmm. Deleting partially-complete state...")
-		if err := pc.Del(s); err != nil {
+		if err := pc.Del(ctx.Ctx(), s); err != nil {
 			logging.Error("Deleting state with id=%q: %v", s.Id_, err)
 		}
 	}
-	s, err := pc.NewState(ctx.Nick)
+	s, err := pc.NewState(ctx.Ctx(), ctx.Nick)
 	if err != nil {
 		ctx.ReplyN("Error creating push state: %v", err)
 		return
@@ -61,12 +61,12 @@ func pushEnable(ctx *bot.Context) {
 func pushDisable(ctx *bot.Context) {
 	// Do not search by aliases here: it allows someone to change nick
 	// to a known alias and then disable pushes for that user.
-	s := pc.GetByNick(ctx.Nick, false)
+	s := pc.GetByNick(ctx.Ctx(), ctx.Nick, false)
 	if s == nil {
 		ctx.ReplyN("Pushes not enabled.")
 		return
 	}
-	if err := pc.Del(s); err != nil {
+	if err := pc.Del(ctx.Ctx(), s); err != nil {
 		ctx.ReplyN("Error deleting push state: %v", err)
 		return
 	}
@@ -74,8 +74,8 @@ func pushDisable(ctx *bot.Context) {
 }
 
 func pushConfirm(ctx *bot.Context) {
-	pin := strings.Fields(ctx.Text())[0]
-	s := pc.GetByNick(ctx.Nick, false)
+	pin := ctx.Params.String("pin")
+	s := pc.GetByNick(ctx.Ctx(), ctx.Nick, false)
 	switch {
 	case s == nil:
 		ctx.ReplyN("No authentication state found.")
@@ -88,7 +88,7 @@ func pushConfirm(ctx *bot.Context) {
 		return
 	}
 	s.Done = true
-	if err := pc.Put(s); err != nil {
+	if err := pc.Put(ctx.Ctx(), s); err != nil {
 		ctx.ReplyN("Error setting push state: %v", err)
 		return
 	}
@@ -96,8 +96,8 @@ func pushConfirm(ctx *bot.Context) {
 }
 
 func pushAddAlias(ctx *bot.Context) {
-	alias := strings.Fields(ctx.Text())[0]
-	s := pc.GetByNick(ctx.Nick, false)
+	alias := ctx.Params.String("alias")
+	s := pc.GetByNick(ctx.Ctx(), ctx.Nick, false)
 	if s == nil || !s.CanPush() {
 		ctx.ReplyN("Pushes not enabled.")
 		return
@@ -106,12 +106,12 @@ func pushAddAlias(ctx *bot.Context) {
 		ctx.ReplyN("Alias %q already exists.", alias)
 		return
 	}
-	if a := pc.GetByNick(alias, true); a != nil {
+	if a := pc.GetByNick(ctx.Ctx(), alias, true); a != nil {
 		ctx.ReplyN("Alias %q already exists for nick %s.", alias, a.Nick)
 		return
 	}
 	s.AddAlias(alias)
-	if err := pc.Put(s); err != nil {
+	if err := pc.Put(ctx.Ctx(), s); err != nil {
 		ctx.ReplyN("Error setting push state: %v", err)
 		return
 	}
@@ -119,8 +119,8 @@ func pushAddAlias(ctx *bot.Context) {
 }
 
 func pushDelAlias(ctx *bot.Context) {
-	alias := strings.Fields(ctx.Text())[0]
-	s := pc.GetByNick(ctx.Nick, false)
+	alias := ctx.Params.String("alias")
+	s := pc.GetByNick(ctx.Ctx(), ctx.Nick, false)
 	if s == nil || !s.CanPush() {
 		ctx.ReplyN("Pushes not enabled.")
 		return
@@ -130,7 +130,7 @@ func pushDelAlias(ctx *bot.Context) {
 		return
 	}
 	s.DelAlias(alias)
-	if err := pc.Put(s); err != nil {
+	if err := pc.Put(ctx.Ctx(), s); err != nil {
 		ctx.ReplyN("Error setting push state: %v", err)
 		return
 	}
@@ -147,7 +147,7 @@ func pushAuthHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 	id := req.FormValue("state")
-	s := pc.GetByB64(id)
+	s := pc.GetByB64(req.Context(), id)
 	if id == "" || s == nil {
 		http.Redirect(rw, req, pushFailureURL("nostate"), 302)
 		return
@@ -157,7 +157,7 @@ func pushAuthHTTP(rw http.ResponseWriter, req *http.Request) {
 		http.Redirect(rw, req, pushFailureURL("notoken"), 302)
 		return
 	}
-	tok, err := push.Exchange(code)
+	tok, err := push.Exchange(req.Context(), code)
 	if err != nil {
 		logging.Error("Failed to get access token for %s: %v", s.Nick, err)
 		http.Redirect(rw, req, pushFailureURL("exchange"), 302)
@@ -165,7 +165,7 @@ func pushAuthHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	s.Token = tok
-	if err := pc.Put(s); err != nil {
+	if err := pc.Put(req.Context(), s); err != nil {
 		logging.Error("Failed to write state to db: %v", err)
 		http.Redirect(rw, req, pushFailureURL("writestate"), 302)
 		return
@@ -179,7 +179,7 @@ func pushDeviceHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 	id := req.FormValue("state")
-	s := pc.GetByB64(id)
+	s := pc.GetByB64(req.Context(), id)
 	if id == "" || s == nil {
 		http.Redirect(rw, req, pushFailureURL("nostate"), 302)
 		return
@@ -190,12 +190,12 @@ func pushDeviceHTTP(rw http.ResponseWriter, req *http.Request) {
 			return
 		}
 		s.Pin = fmt.Sprintf("%06x", rand.Intn(1e6))
-		if err := push.Confirm(s); err != nil {
+		if err := push.Confirm(req.Context(), s); err != nil {
 			logging.Error("Failed to send confirmation push for %s: %v", s.Nick, err)
 			http.Redirect(rw, req, pushFailureURL("push"), 302)
 			return
 		}
-		if err := pc.Put(s); err != nil {
+		if err := pc.Put(req.Context(), s); err != nil {
 			logging.Error("Failed to write state to db: %v", err)
 			http.Redirect(rw, req, pushFailureURL("writestate"), 302)
 		}
@@ -203,7 +203,7 @@ func pushDeviceHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 	// get device list and print a form
-	devs, err := push.GetDevices(s)
+	devs, err := push.GetDevices(req.Context(), s)
 	if err != nil {
 		logging.Error("Failed to get devices for %s: %v", s.Nick, err)
 		http.Redirect(rw, req, pushFailureURL("device"), 302)
@@ -1,6 +1,7 @@
 package netdriver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -54,14 +55,14 @@ func (udc udCache) prune() {
 	}
 }
 
-func (udc udCache) fetch(term string) (entry udCacheEntry, ok bool, err error) {
+func (udc udCache) fetch(ctx context.Context, term string) (entry udCacheEntry, ok bool, err error) {
 	udc.prune()
 	entry, ok = udc[term]
 	if ok {
 		return
 	}
 	entry.result = &udResult{}
-	data, err := get(fmt.Sprintf(udUrl, url.QueryEscape(term)))
+	data, err := get(ctx, fmt.Sprintf(udUrl, url.QueryEscape(term)))
 	if err != nil {
 		return
 	}
@@ -79,9 +80,9 @@ func (udc udCache) fetch(term string) (entry udCacheEntry, ok bool, err error) {
 var cache = udCache{}
 
 func urbanDictionary(ctx *bot.Context) {
-	entry, ok, err := cache.fetch(strings.ToLower(ctx.Text()))
+	entry, ok, err := cache.fetch(ctx.Ctx(), strings.ToLower(ctx.Text()))
 	if err != nil {
-		ctx.ReplyN("ud request failed: %s", err)
+		ctx.Fail("ud request failed: %s", err)
 		return
 	}
 	cached, r := "", entry.result
@@ -90,7 +91,7 @@ func urbanDictionary(ctx *bot.Context) {
 			datetime.Format(entry.stamp))
 	}
 	if r.Total == 0 || r.Type == "no_results" {
-		ctx.ReplyN("%s isn't defined yet%s.", ctx.Text(), cached)
+		ctx.Fail("%s isn't defined yet%s.", ctx.Text(), cached)
 		return
 	}
 	// Cycle through all the definitions on repeated calls for the same term
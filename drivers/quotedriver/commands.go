@@ -1,7 +1,7 @@
 package quotedriver
 
 import (
-	"strconv"
+	"fmt"
 
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/quotes"
@@ -10,12 +10,13 @@ import (
 func add(ctx *bot.Context) {
 	n, c := ctx.Storable()
 	quote := quotes.NewQuote(ctx.Text(), n, c)
+	quote.Timestamp = ctx.Time
 	var err error
-	if quote.QID, err = qc.NewQID(); err != nil {
+	if quote.QID, err = qc.NewQID(ctx.Ctx()); err != nil {
 		ctx.ReplyN("Retrieving new quote ID failed: %v", err)
 		return
 	}
-	if err = qc.Put(quote); err == nil {
+	if err = qc.Put(ctx.Ctx(), quote); err == nil {
 		ctx.ReplyN("Quote added succesfully, id #%d.", quote.QID)
 	} else {
 		ctx.ReplyN("Error adding quote: %s.", err)
@@ -23,18 +24,10 @@ func add(ctx *bot.Context) {
 }
 
 func del(ctx *bot.Context) {
-	txt := ctx.Text()
-	// Strip optional # before qid
-	if txt[0] == '#' {
-		txt = txt[1:]
-	}
-	qid, err := strconv.Atoi(txt)
-	if err != nil {
-		ctx.ReplyN("'%s' doesn't look like a quote id.", ctx.Text())
-		return
-	}
-	if quote := qc.GetByQID(qid); quote != nil {
-		if err := qc.Del(quote); err == nil {
+	qid := ctx.Params.Int("qID")
+	if quote := qc.GetByQID(ctx.Ctx(), qid); quote != nil {
+		if err := qc.Del(ctx.Ctx(), quote); err == nil {
+			ctx.Audit(quotes.COLLECTION, fmt.Sprintf("#%d", qid), quote.Quote)
 			ctx.ReplyN("I forgot quote #%d: %s", qid, quote.Quote)
 		} else {
 			ctx.ReplyN("I failed to forget quote #%d: %s", qid, err)
@@ -45,35 +38,25 @@ func del(ctx *bot.Context) {
 }
 
 func fetch(ctx *bot.Context) {
-	if RateLimit(ctx.Nick) {
-		return
-	}
-	qid, err := strconv.Atoi(ctx.Text())
-	if err != nil {
-		ctx.ReplyN("'%s' doesn't look like a quote id.", ctx.Text())
-		return
-	}
-	quote := qc.GetByQID(qid)
+	qid := ctx.Params.Int("qID")
+	quote := qc.GetByQID(ctx.Ctx(), qid)
 	if quote != nil {
 		ctx.Reply("#%d: %s", quote.QID, quote.Quote)
 	} else {
-		ctx.ReplyN("No quote found for id %d", qid)
+		ctx.Fail("No quote found for id %d", qid)
 	}
 }
 
 func lookup(ctx *bot.Context) {
-	if RateLimit(ctx.Nick) {
-		return
-	}
-	quote := qc.GetPseudoRand(ctx.Text())
+	quote := qc.GetPseudoRand(ctx.Ctx(), ctx.Text())
 	if quote == nil {
-		ctx.ReplyN("No quotes matching '%s' found.", ctx.Text())
+		ctx.Fail("No quotes matching '%s' found.", ctx.Text())
 		return
 	}
 
 	// TODO(fluffle): qd should take care of updating Accessed internally
 	quote.Accessed++
-	if err := qc.Put(quote); err != nil {
+	if err := qc.Put(ctx.Ctx(), quote); err != nil {
 		ctx.ReplyN("I failed to update quote #%d: %s", quote.QID, err)
 	}
 	ctx.Reply("#%d: %s", quote.QID, quote.Quote)
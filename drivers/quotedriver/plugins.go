@@ -13,10 +13,10 @@ func quotePlugin(in string, ctx *bot.Context) string {
 		var quote *quotes.Quote
 		if s[0] == '#' {
 			if qid, err := strconv.Atoi(s[1:]); err == nil {
-				quote = qc.GetByQID(qid)
+				quote = qc.GetByQID(ctx.Ctx(), qid)
 			}
 		} else {
-			quote = qc.GetPseudoRand(s)
+			quote = qc.GetPseudoRand(ctx.Ctx(), s)
 		}
 		if quote == nil {
 			return "<plugin error>"
@@ -2,7 +2,6 @@ package reminddriver
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -22,37 +21,37 @@ func del(ctx *bot.Context) {
 			"to be sure of what you're deleting.")
 		return
 	}
-	idx, err := strconv.Atoi(ctx.Text())
-	if err != nil || idx > len(list) || idx <= 0 {
+	idx := ctx.Params.Int("N")
+	if idx > len(list) || idx <= 0 {
 		ctx.ReplyN("Invalid reminder index '%s'", ctx.Text())
 		return
 	}
 	idx--
-	Forget(list[idx], true)
+	if r := rc.GetById(ctx.Ctx(), list[idx]); r != nil {
+		ctx.Audit(reminders.COLLECTION, list[idx].Hex(), r.List(ctx.Nick))
+	}
+	Forget(ctx.Ctx(), list[idx], true)
 	delete(listed, ctx.Nick)
 	ctx.ReplyN("I'll forget that one, then...")
 }
 
 // remind list
 func list(ctx *bot.Context) {
-	r := rc.RemindersFor(ctx.Nick)
+	r := rc.RemindersFor(ctx.Ctx(), ctx.Nick)
 	c := len(r)
 	if c == 0 {
 		ctx.ReplyN("You have no reminders set.")
 		return
 	}
-	if c > 5 && ctx.Public() {
-		ctx.ReplyN("You've got lots of reminders, ask me privately.")
-		return
-	}
 	// Save an ordered list of ObjectIds for easy reminder deletion
-	ctx.ReplyN("You have %d reminders set:", c)
 	list := make([]bson.ObjectId, c)
+	lines := make([]string, c)
 	for i := range r {
-		ctx.Reply("%d: %s", i+1, r[i].List(ctx.Nick))
+		lines[i] = fmt.Sprintf("%d: %s", i+1, r[i].List(ctx.Nick))
 		list[i] = r[i].Id()
 	}
 	listed[ctx.Nick] = list
+	ctx.ReplyPaged(fmt.Sprintf("You have %d reminders set:", c), lines)
 }
 
 // remind
@@ -109,7 +108,7 @@ func set(ctx *bot.Context) {
 		t = n
 	}
 	r := reminders.NewReminder(reminder, at, t, n, c)
-	if err := rc.Put(r); err != nil {
+	if err := rc.Put(ctx.Ctx(), r); err != nil {
 		ctx.ReplyN("Error saving reminder: %v", err)
 		return
 	}
@@ -130,21 +129,15 @@ func snooze(ctx *bot.Context) {
 	z := datetime.ZoneOrLocal(conf.Zone(ctx.Nick))
 	now := time.Now().In(z)
 	at := now.Add(30 * time.Minute)
-	if ctx.Text() != "" {
-		var err error
-		at, err = datetime.ParseZ(ctx.Text(), z)
-		if err != nil {
-			ctx.ReplyN("Couldn't parse time string %q: %v.", ctx.Text(), err)
-			return
-		}
-		if at.Before(now) {
+	if ctx.Params.Has("time") {
+		if at = ctx.Params.Time("time"); at.Before(now) {
 			ctx.ReplyN("You can't snooze reminder into the past, fool.")
 			return
 		}
 	}
 	r.Created = now
 	r.RemindAt = at
-	if err := rc.Put(r); err != nil {
+	if err := rc.Put(ctx.Ctx(), r); err != nil {
 		ctx.ReplyN("Error saving reminder: %v", err)
 		return
 	}
@@ -171,13 +164,13 @@ func tell(ctx *bot.Context) {
 		return
 	}
 	r := reminders.NewTell(tell, t, n, c)
-	if err := rc.Put(r); err != nil {
+	if err := rc.Put(ctx.Ctx(), r); err != nil {
 		ctx.ReplyN("Error saving tell: %v", err)
 		return
 	}
 	if pc != nil {
-		if s := pc.GetByNick(txt[:idx], true); s.CanPush() {
-			push.Push(s, fmt.Sprintf("%s in %s asked me to tell you:",
+		if s := pc.GetByNick(ctx.Ctx(), txt[:idx], true); s.CanPush() {
+			push.Push(ctx.Ctx(), s, fmt.Sprintf("%s in %s asked me to tell you:",
 				ctx.Nick, ctx.Target()), tell)
 		}
 	}
@@ -188,16 +181,12 @@ func tell(ctx *bot.Context) {
 
 // zone
 func zone(ctx *bot.Context) {
-	fields := strings.Fields(ctx.Text())
-	if len(fields) == 0 {
-		ctx.ReplyN("Your timezone is ... fat? Like your mum?")
-		return
-	}
-	if z := datetime.Zone(fields[0]); z != nil {
-		conf.Zone(ctx.Nick, fields[0])
+	name := ctx.Params.String("zone")
+	if z := datetime.Zone(name); z != nil {
+		conf.Zone(ctx.Nick, name)
 		ctx.ReplyN("Reminders will now be in %q.", z)
 	} else {
-		ctx.ReplyN("Don't recognise %q as a valid timezone, sorry.", fields[0])
+		ctx.ReplyN("Don't recognise %q as a valid timezone, sorry.", name)
 	}
 }
 
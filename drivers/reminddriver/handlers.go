@@ -1,14 +1,13 @@
 package reminddriver
 
 import (
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
 )
 
 func load(ctx *bot.Context) {
 	// We're connected to IRC, so load saved reminders
-	r := rc.LoadAndPrune()
+	r := rc.LoadAndPrune(ctx.Ctx())
 	for i := range r {
 		if r[i] == nil {
 			logging.Warn("Nil reminder %d from LoadAndPrune", i)
@@ -21,21 +20,27 @@ func load(ctx *bot.Context) {
 func unload(ctx *bot.Context) {
 	// We've been disconnected from IRC: stop all remind goroutines
 	// since they will be restarted when we reconnect.
+	Shutdown()
+}
+
+// Shutdown stops all remind goroutines.
+func Shutdown() {
 	for id, cancel := range running {
 		cancel()
 		delete(running, id)
 	}
+	pending.Set(0)
 }
 
 func tellCheck(ctx *bot.Context) {
 	nick := ctx.Nick
-	if ctx.Cmd == client.NICK {
+	if ctx.Cmd == bot.NICK {
 		// We want the destination nick, not the source.
 		nick = ctx.Target()
 	}
-	r := rc.TellsFor(nick)
+	r := rc.TellsFor(ctx.Ctx(), nick)
 	for i := range r {
-		if ctx.Cmd == client.NICK {
+		if ctx.Cmd == bot.NICK {
 			if r[i].Chan != "" {
 				ctx.Privmsg(string(r[i].Chan), nick+": "+r[i].Reply())
 			}
@@ -46,7 +51,7 @@ func tellCheck(ctx *bot.Context) {
 				ctx.ReplyN("%s", r[i].Reply())
 			}
 		}
-		rc.Del(r[i])
+		rc.Del(ctx.Ctx(), r[i])
 	}
 	if len(r) > 0 {
 		delete(listed, ctx.Nick)
@@ -6,11 +6,11 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/pushes"
 	"github.com/fluffle/sp0rkle/collections/reminders"
+	"github.com/fluffle/sp0rkle/util/metrics"
 	"github.com/fluffle/sp0rkle/util/push"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -22,12 +22,24 @@ var pc *pushes.Collection
 // We need to be able to kill reminder goroutines
 var running = map[bson.ObjectId]context.CancelFunc{}
 
+var pending = metrics.NewGauge("sp0rkle_reminders_pending",
+	"Reminders waiting to be delivered.")
+
 // It's also nice for people to be able to snooze them
 var finished = map[string]*reminders.Reminder{}
 
 // And it's useful to index them for deletion per-person
 var listed = map[string][]bson.ObjectId{}
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "reminddriver",
+		Description: "Delivers reminders and messages for absent nicks.",
+		Init:        Init,
+		Shutdown:    Shutdown,
+	})
+}
+
 func Init() {
 	rc = reminders.Init()
 	if push.Enabled() {
@@ -35,10 +47,10 @@ func Init() {
 	}
 
 	// Set up the handlers and commands.
-	bot.Handle(load, client.CONNECTED)
-	bot.Handle(unload, client.DISCONNECTED)
+	bot.Handle(load, bot.CONNECTED)
+	bot.Handle(unload, bot.DISCONNECTED)
 	bot.Handle(tellCheck,
-		client.PRIVMSG, client.ACTION, client.JOIN, client.NICK)
+		bot.PRIVMSG, bot.ACTION, bot.JOIN, bot.NICK)
 
 	bot.Command(tell, "tell", "tell <nick> <msg>  -- "+
 		"Stores a message for the (absent) nick.")
@@ -47,13 +59,15 @@ func Init() {
 	bot.Command(list, "remind list",
 		"remind list  -- Lists reminders set by or for your nick.")
 	bot.Command(del, "remind del",
-		"remind del <N>  -- Deletes (previously listed) reminder N.")
+		"remind del <N>  -- Deletes (previously listed) reminder N.",
+		bot.Takes(bot.IntArg("N")))
 	bot.Command(set, "remind", "remind <nick> <msg> "+
 		"in|at|on <time>  -- Reminds nick about msg at time.")
-	bot.Command(snooze, "snooze", "snooze [duration]  -- "+
-		"Resets the previously-triggered reminder.")
+	bot.Command(snooze, "snooze", "snooze [time]  -- "+
+		"Resets the previously-triggered reminder.",
+		bot.Takes(bot.TimeArg("time").Optional()))
 	bot.Command(zone, "my timezone is", "my timezone is <zone>  -- "+
-		"Sets a local timezone for your nick.")
+		"Sets a local timezone for your nick.", bot.Takes(bot.WordArg("zone")))
 	bot.Command(unzone, "forget my timezone", "forget my timezone  -- "+
 		"Unsets a local timezone for your nick.")
 }
@@ -65,6 +79,7 @@ func Remind(r *reminders.Reminder, ctx *bot.Context) {
 	}
 	c, cancel := context.WithDeadline(bot.Ctx(), r.RemindAt)
 	running[r.Id()] = cancel
+	pending.Set(float64(len(running)))
 	go func() {
 		<-c.Done()
 		if errors.Is(c.Err(), context.DeadlineExceeded) {
@@ -74,29 +89,32 @@ func Remind(r *reminders.Reminder, ctx *bot.Context) {
 			// This is used in snooze to reinstate reminders.
 			finished[strings.ToLower(string(r.Target))] = r
 			if pc != nil {
-				if s := pc.GetByNick(string(r.Target), true); s.CanPush() {
-					push.Push(s, "Reminder from sp0rkle!", r.Reply())
+				if s := pc.GetByNick(bot.Ctx(), string(r.Target), true); s.CanPush() {
+					pctx, cancel := context.WithTimeout(bot.Ctx(), time.Minute)
+					push.Push(pctx, s, "Reminder from sp0rkle!", r.Reply())
+					cancel()
 				}
 			}
-			Forget(r.Id(), false)
+			Forget(bot.Ctx(), r.Id(), false)
 		}
 	}()
 }
 
-func Forget(id bson.ObjectId, stop bool) {
+func Forget(ctx context.Context, id bson.ObjectId, stop bool) {
 	cancel, ok := running[id]
 	if ok {
 		// If it's *not* in running, it's probably a Tell.
 		delete(running, id)
+		pending.Set(float64(len(running)))
 		if stop {
 			cancel()
 		}
 	}
-	r := rc.GetById(id)
+	r := rc.GetById(ctx, id)
 	if r == nil {
 		return
 	}
-	if err := rc.Del(r); err != nil {
+	if err := rc.Del(ctx, r); err != nil {
 		logging.Error("Failure removing reminder %s: %v", id, err)
 	}
 }
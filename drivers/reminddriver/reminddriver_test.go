@@ -0,0 +1,81 @@
+package reminddriver
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "reminddriver")
+	if err != nil {
+		panic(err)
+	}
+	if err := bottest.UseDB(dir); err != nil {
+		panic(err)
+	}
+	bottest.Init()
+	Init()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestTell(t *testing.T) {
+	b := bottest.New()
+	b.Say("tester", "#chan", "sp0rkle: tell alice hello")
+	b.Say("tester", "#chan", "sp0rkle: ask bob how are you?")
+	b.Say("tester", "#chan", "sp0rkle: tell carol psst")
+	b.Say("tester", "#chan", "sp0rkle: tell me something")
+	exp := []string{
+		"tester: okay, i'll tell alice hello when I see them",
+		"tester: okay, i'll tell bob how are you? when I see them",
+		"tester: okay, i'll tell carol psst when I see them",
+		"tester: You're a dick. Oh, wait, that wasn't *quite* it...",
+	}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("tell: exp %q got %q", exp, texts)
+	}
+
+	tests := []struct {
+		nick, cmd string
+		args      []string
+		exp       []bottest.Sent
+	}{
+		// Tells are delivered privately, and in the channel they were
+		// asked in, when the nick joins or speaks.
+		{"alice", bot.JOIN, []string{"#chan"}, []bottest.Sent{
+			{bot.PRIVMSG, "alice", "tester asked me to tell you hello"},
+			{bot.PRIVMSG, "#chan", "alice: tester asked me to tell you hello"},
+		}},
+		{"alice", bot.JOIN, []string{"#other"}, nil},
+		{"bob", bot.PRIVMSG, []string{"#other", "hi"}, []bottest.Sent{
+			{bot.PRIVMSG, "bob", "tester asked me to tell you how are you?"},
+			{bot.PRIVMSG, "#other", "bob: tester asked me to tell you how are you?"},
+		}},
+		// Or when someone changes to the nick.
+		{"carol_", bot.NICK, []string{"carol"}, []bottest.Sent{
+			{bot.PRIVMSG, "#chan", "carol: tester asked me to tell you psst"},
+			{bot.PRIVMSG, "carol", "tester asked me to tell you psst"},
+		}},
+	}
+	for i, test := range tests {
+		b.Hear(test.nick, test.cmd, test.args...)
+		if sent := b.Sent(); !reflect.DeepEqual(sent, test.exp) {
+			t.Errorf("Tell(%d) %s %s: exp %v got %v",
+				i, test.nick, test.cmd, test.exp, sent)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	b := bottest.New()
+	b.Say("tester", "#chan", "sp0rkle: remind list")
+	exp := []string{"tester: You have no reminders set."}
+	if texts := b.Texts(); !reflect.DeepEqual(texts, exp) {
+		t.Errorf("remind list: exp %q got %q", exp, texts)
+	}
+}
@@ -1,6 +1,7 @@
 package seendriver
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/fluffle/golog/logging"
@@ -11,30 +12,25 @@ func seenCmd(ctx *bot.Context) {
 	s := strings.Fields(ctx.Text())
 	if len(s) == 2 {
 		// Assume we have "seen <nick> <action>"
-		if n := sc.LastSeenDoing(s[0], strings.ToUpper(s[1])); n != nil {
+		if n := sc.LastSeenDoing(ctx.Ctx(), s[0], strings.ToUpper(s[1])); n != nil {
 			ctx.ReplyN("%s", n)
 			return
 		}
 	}
 	// Not specifically asking for that action, or no matching action.
-	if n := sc.LastSeen(s[0]); n != nil {
+	if n := sc.LastSeen(ctx.Ctx(), s[0]); n != nil {
 		ctx.ReplyN("%s", n)
 		return
 	}
 	// No exact matches for nick found, look for possible partial matches.
-	if m := sc.SeenAnyMatching(s[0]); len(m) > 0 {
+	if m := sc.SeenAnyMatching(ctx.Ctx(), s[0]); len(m) > 0 {
 		if len(m) == 1 {
-			if n := sc.LastSeen(m[0]); n != nil {
+			if n := sc.LastSeen(ctx.Ctx(), m[0]); n != nil {
 				ctx.ReplyN("1 possible match: %s", n)
 			}
-		} else if len(m) > 10 {
-			ctx.ReplyN("%d possible matches, most recent 10 are: %s.",
-				len(m), strings.Join(m[:9], ", "))
-
 		} else {
-			ctx.ReplyN("%d possible matches: %s.",
-				len(m), strings.Join(m, ", "))
-
+			ctx.ReplyPagedList(fmt.Sprintf("%d possible matches: ", len(m)),
+				m, ", ")
 		}
 		return
 	}
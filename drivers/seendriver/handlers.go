@@ -1,8 +1,6 @@
 package seendriver
 
 import (
-	"time"
-
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/seen"
@@ -13,18 +11,21 @@ func smoke(ctx *bot.Context) {
 	if !smokeRx.MatchString(ctx.Text()) {
 		return
 	}
-	sn := sc.LastSeenDoing(ctx.Nick, "SMOKE")
+	sn := sc.LastSeenDoing(ctx.Ctx(), ctx.Nick, "SMOKE")
 	n, c := ctx.Storable()
+	if !newer(ctx, sn) {
+		return
+	}
 	if sn != nil {
 		ctx.ReplyN("You last went for a smoke %s ago...",
 			util.TimeSince(sn.Timestamp))
 
 		sn.Nick, sn.Chan = n, c
-		sn.Timestamp = time.Now()
 	} else {
 		sn = seen.SawNick(n, c, "SMOKE", "")
 	}
-	if err := sc.Put(sn); err != nil {
+	sn.Timestamp = ctx.Time
+	if err := sc.Put(ctx.Ctx(), sn); err != nil {
 		ctx.Reply("Failed to store smoke data: %v", err)
 	}
 }
@@ -34,28 +35,37 @@ func recordPrivmsg(ctx *bot.Context) {
 		return
 	}
 	sn := seenNickFromLine(ctx)
+	if sn == nil {
+		return
+	}
 	sn.Text = ctx.Text()
-	if err := sc.Put(sn); err != nil {
+	if err := sc.Put(ctx.Ctx(), sn); err != nil {
 		ctx.Reply("Failed to store seen data: %v", err)
 	}
 }
 
 func recordJoin(ctx *bot.Context) {
 	sn := seenNickFromLine(ctx)
+	if sn == nil {
+		return
+	}
 	if len(ctx.Args) > 1 {
 		// If we have a PART message
 		sn.Text = ctx.Text()
 	}
-	if err := sc.Put(sn); err != nil {
+	if err := sc.Put(ctx.Ctx(), sn); err != nil {
 		ctx.Reply("Failed to store seen data: %v", err)
 	}
 }
 
 func recordNick(ctx *bot.Context) {
 	sn := seenNickFromLine(ctx)
+	if sn == nil {
+		return
+	}
 	sn.Chan = ""
 	sn.Text = ctx.Target()
-	if err := sc.Put(sn); err != nil {
+	if err := sc.Put(ctx.Ctx(), sn); err != nil {
 		// We don't have anyone to reply to in this case, so log instead.
 		logging.Warn("Failed to store seen data: %v", err)
 	}
@@ -66,27 +76,30 @@ func recordKick(ctx *bot.Context) {
 	kn := bot.Nick(ctx.Text())
 	// seenNickFromLine doesn't work with the hacks for KICKING and KICKED
 	// First, handle KICKING
-	kr := sc.LastSeenDoing(ctx.Nick, "KICKING")
-	if kr == nil {
-		kr = seen.SawNick(n, c, "KICKING", ctx.Args[2])
-	} else {
-		kr.Nick, kr.Chan = n, c
-		kr.Timestamp, kr.Text = time.Now(), ctx.Args[2]
-	}
-	kr.OtherNick = kn
-	if err := sc.Put(kr); err != nil {
-		ctx.Reply("Failed to store seen data: %v", err)
+	// Either may already be newer than a replayed line.
+	if kr := sc.LastSeenDoing(ctx.Ctx(), ctx.Nick, "KICKING"); newer(ctx, kr) {
+		if kr == nil {
+			kr = seen.SawNick(n, c, "KICKING", ctx.Args[2])
+		} else {
+			kr.Nick, kr.Chan, kr.Text = n, c, ctx.Args[2]
+		}
+		kr.Timestamp = ctx.Time
+		kr.OtherNick = kn
+		if err := sc.Put(ctx.Ctx(), kr); err != nil {
+			ctx.Reply("Failed to store seen data: %v", err)
+		}
 	}
 	// Now, handle KICKED
-	ke := sc.LastSeenDoing(ctx.Text(), "KICKED")
-	if ke == nil {
-		ke = seen.SawNick(kn, c, "KICKED", ctx.Args[2])
-	} else {
-		ke.Nick, ke.Chan = kn, c
-		ke.Timestamp, ke.Text = time.Now(), ctx.Args[2]
-	}
-	ke.OtherNick = n
-	if err := sc.Put(ke); err != nil {
-		ctx.Reply("Failed to store seen data: %v", err)
+	if ke := sc.LastSeenDoing(ctx.Ctx(), ctx.Text(), "KICKED"); newer(ctx, ke) {
+		if ke == nil {
+			ke = seen.SawNick(kn, c, "KICKED", ctx.Args[2])
+		} else {
+			ke.Nick, ke.Chan, ke.Text = kn, c, ctx.Args[2]
+		}
+		ke.Timestamp = ctx.Time
+		ke.OtherNick = n
+		if err := sc.Put(ctx.Ctx(), ke); err != nil {
+			ctx.Reply("Failed to store seen data: %v", err)
+		}
 	}
 }
@@ -2,9 +2,7 @@ package seendriver
 
 import (
 	"regexp"
-	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/seen"
 )
@@ -32,6 +30,11 @@ var wittyComebacks []stupidQuestion = []stupidQuestion{
 }
 
 func init() {
+	bot.Register(&bot.Driver{
+		Name:        "seendriver",
+		Description: "Remembers when nicks were last seen.",
+		Init:        Init,
+	})
 	for i, w := range wittyComebacks {
 		// all regex matches for comebacks should be case-insensitive
 		wittyComebacks[i].rx = regexp.MustCompile("(?i)" + w.re)
@@ -43,26 +46,38 @@ var sc *seen.Collection
 func Init() {
 	sc = seen.Init()
 
-	bot.Handle(smoke, client.PRIVMSG, client.ACTION)
-	bot.Handle(recordPrivmsg, client.PRIVMSG, client.ACTION)
-	bot.Handle(recordJoin, client.JOIN, client.PART)
-	bot.Handle(recordNick, client.NICK, client.QUIT)
-	bot.Handle(recordKick, client.KICK)
+	bot.Handle(smoke, bot.PRIVMSG, bot.ACTION)
+	bot.HandleReplays(recordPrivmsg, bot.PRIVMSG, bot.ACTION)
+	bot.HandleReplays(recordJoin, bot.JOIN, bot.PART)
+	bot.HandleReplays(recordNick, bot.NICK, bot.QUIT)
+	bot.HandleReplays(recordKick, bot.KICK)
 
 	bot.Command(seenCmd, "seen", "seen <nick> [action]  -- "+
 		"display the last time <nick> was seen on IRC [doing action]")
 }
 
+// newer returns true if the line happened after sn, which may be nil, so
+// that lines replayed from before it don't turn back the clock.
+func newer(ctx *bot.Context, sn *seen.Nick) bool {
+	return sn == nil || ctx.Time.After(sn.Timestamp)
+}
+
 // Look up or create a "seen" entry for the line.
 // Explicitly don't handle updating line.Text or line.OtherNick
+// Returns nil if the entry is already newer than the line.
 func seenNickFromLine(ctx *bot.Context) *seen.Nick {
-	sn := sc.LastSeenDoing(ctx.Nick, ctx.Cmd)
+	sn := sc.LastSeenDoing(ctx.Ctx(), ctx.Nick, ctx.Cmd)
 	n, c := ctx.Storable()
+	if !newer(ctx, sn) {
+		return nil
+	}
 	if sn == nil {
 		sn = seen.SawNick(n, c, ctx.Cmd, "")
 	} else {
 		sn.Nick, sn.Chan = n, c
-		sn.Timestamp = time.Now()
 	}
+	// The line may have been sent a while ago, e.g. if a bouncer
+	// replayed it, so use the time it was sent rather than now.
+	sn.Timestamp = ctx.Time
 	return sn
 }
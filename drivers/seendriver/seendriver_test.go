@@ -0,0 +1,59 @@
+package seendriver
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "seendriver")
+	if err != nil {
+		panic(err)
+	}
+	if err := bottest.UseDB(dir); err != nil {
+		panic(err)
+	}
+	bottest.Init()
+	Init()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestSeen(t *testing.T) {
+	b := bottest.New()
+	b.Hear("alice", bot.JOIN, "#chan")
+	b.Say("bob", "#chan", "hello there")
+	b.Hear("bob", bot.PART, "#chan", "bye")
+	b.Hear("carol", bot.NICK, "dave")
+	if sent := b.Sent(); len(sent) != 0 {
+		t.Fatalf("unexpected output: %v", sent)
+	}
+
+	tests := []struct {
+		text string
+		exp  string
+	}{
+		{"seen alice", `^tester: I last saw alice on .* \(.* ago\), joining #chan\.$`},
+		{"seen bob", `^tester: I last saw bob on .* \(.* ago\), ` +
+			`parting #chan with the message 'bye'\.$`},
+		{"seen bob privmsg", `^tester: I last saw bob on .* \(.* ago\), ` +
+			`in #chan, saying 'hello there'\.$`},
+		{"seen carol", `^tester: I last saw carol on .* \(.* ago\), ` +
+			`changing their nick to 'dave'\.$`},
+		{"seen ali", `^tester: 1 possible match: I last saw alice on `},
+		{"seen erin", `^tester: Haven't seen erin before, sorry\.$`},
+		{"seen me", `^tester: You're right there, fool\.$`},
+	}
+	for i, test := range tests {
+		b.Say("tester", "#chan", "sp0rkle: "+test.text)
+		texts := b.Texts()
+		if len(texts) != 1 || !regexp.MustCompile(test.exp).MatchString(texts[0]) {
+			t.Errorf("Seen(%d) %q: exp %q got %q", i, test.text, test.exp, texts)
+		}
+	}
+}
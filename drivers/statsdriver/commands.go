@@ -12,14 +12,14 @@ func statsCmd(ctx *bot.Context) {
 	if len(ctx.Text()) > 0 {
 		n = ctx.Text()
 	}
-	ns := sc.StatsFor(n, ctx.Target())
+	ns := sc.StatsFor(ctx.Ctx(), n, ctx.Target())
 	if ns != nil {
 		ctx.ReplyN("%s", ns)
 	}
 }
 
 func topten(ctx *bot.Context) {
-	top := sc.TopTen(ctx.Target())
+	top := sc.TopTen(ctx.Ctx(), ctx.Target())
 	s := make([]string, 0, 10)
 	for i, n := range top {
 		s = append(s, fmt.Sprintf("#%d: %s - %d", i+1, n.Nick, n.Lines))
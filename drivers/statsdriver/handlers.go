@@ -6,19 +6,19 @@ import (
 )
 
 func recordStats(ctx *bot.Context) {
-	ns := sc.StatsFor(ctx.Nick, ctx.Target())
+	ns := sc.StatsFor(ctx.Ctx(), ctx.Nick, ctx.Target())
 	if ns == nil {
 		n, c := ctx.Storable()
 		ns = stats.NewStat(n, c)
 	}
-	ns.Update(ctx.Text())
+	ns.Update(ctx.Text(), ctx.Time)
 	if ns.Lines%10000 == 0 {
 		ctx.Reply("%s has said %d lines in this channel and "+
 			"should now shut the fuck up and do something useful",
 			ctx.Nick, ns.Lines)
 
 	}
-	if err := sc.Put(ns); err != nil {
+	if err := sc.Put(ctx.Ctx(), ns); err != nil {
 		ctx.Reply("Failed to store stats data: %v", err)
 	}
 }
@@ -1,17 +1,24 @@
 package statsdriver
 
 import (
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/stats"
 )
 
 var sc *stats.Collection
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "statsdriver",
+		Description: "Counts lines said by each nick.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	sc = stats.Init()
 
-	bot.Handle(recordStats, client.PRIVMSG, client.ACTION)
+	bot.Handle(recordStats, bot.PRIVMSG, bot.ACTION)
 
 	bot.Command(statsCmd, "lines", "lines [nick]  -- "+
 		"display how many lines you [or nick] has said in the channel")
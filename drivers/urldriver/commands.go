@@ -10,7 +10,7 @@ import (
 )
 
 func find(ctx *bot.Context) {
-	if u := uc.GetRand(ctx.Text()); u != nil {
+	if u := uc.GetRand(ctx.Ctx(), ctx.Text()); u != nil {
 		ctx.ReplyN("%s", u)
 	}
 }
@@ -19,7 +19,7 @@ func shorten(ctx *bot.Context) {
 	var u *urls.Url
 	if ctx.Text() == "" {
 		// assume we have been given "shorten that"
-		if u = uc.GetById(lastseen[ctx.Target()]); u == nil {
+		if u = uc.GetById(ctx.Ctx(), lastseen[ctx.Target()]); u == nil {
 			ctx.ReplyN("I seem to have forgotten what to shorten")
 			return
 		}
@@ -38,7 +38,7 @@ func shorten(ctx *bot.Context) {
 			ctx.ReplyN("'%s' doesn't look URLish", url)
 			return
 		}
-		if u = uc.GetByUrl(url); u == nil {
+		if u = uc.GetByUrl(ctx.Ctx(), url); u == nil {
 			n, c := ctx.Storable()
 			u = urls.NewUrl(url, n, c)
 		} else if u.Shortened != "" {
@@ -48,7 +48,7 @@ func shorten(ctx *bot.Context) {
 			return
 		}
 	}
-	if err := Shorten(u); err != nil {
+	if err := Shorten(ctx.Ctx(), u); err != nil {
 		ctx.ReplyN("Failed to store shortened url: %s", err)
 		return
 	}
@@ -61,7 +61,7 @@ func cache(ctx *bot.Context) {
 	var u *urls.Url
 	if ctx.Text() == "" {
 		// assume we have been given "cache that"
-		if u = uc.GetById(lastseen[ctx.Target()]); u == nil {
+		if u = uc.GetById(ctx.Ctx(), lastseen[ctx.Target()]); u == nil {
 			ctx.ReplyN("I seem to have forgotten what to cache")
 			return
 		}
@@ -80,7 +80,7 @@ func cache(ctx *bot.Context) {
 			ctx.ReplyN("'%s' doesn't look URLish", url)
 			return
 		}
-		if u = uc.GetByUrl(url); u == nil {
+		if u = uc.GetByUrl(ctx.Ctx(), url); u == nil {
 			n, c := ctx.Storable()
 			u = urls.NewUrl(url, n, c)
 		} else if u.CachedAs != "" {
@@ -91,7 +91,7 @@ func cache(ctx *bot.Context) {
 			return
 		}
 	}
-	if err := Cache(u); err != nil {
+	if err := Cache(ctx.Ctx(), u); err != nil {
 		ctx.ReplyN("Failed to store cached url: %s", err)
 		return
 	}
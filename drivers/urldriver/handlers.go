@@ -14,7 +14,7 @@ func urlScan(ctx *bot.Context) {
 	n, c := ctx.Storable()
 	for _, w := range words {
 		if util.LooksURLish(w) {
-			if u := uc.GetByUrl(w); u.Exists() {
+			if u := uc.GetByUrl(ctx.Ctx(), w); u.Exists() {
 				if u.Nick != bot.Nick(ctx.Nick) &&
 					time.Since(u.Timestamp) > 2*time.Hour {
 					ctx.Reply("that URL first mentioned by %s %s ago",
@@ -23,10 +23,10 @@ func urlScan(ctx *bot.Context) {
 				continue
 			}
 			u := urls.NewUrl(w, n, c)
-			if len(w) > autoShortenLimit && ctx.Public() {
-				u.Shortened = Encode(w)
+			if len(w) > autoShortenLimit.Get() && ctx.Public() {
+				u.Shortened = Encode(ctx.Ctx(), w)
 			}
-			if err := uc.Put(u); err != nil {
+			if err := uc.Put(ctx.Ctx(), u); err != nil {
 				ctx.ReplyN("Couldn't insert url '%s': %s", w, err)
 				continue
 			}
@@ -8,7 +8,7 @@ func shortenedServer(rw http.ResponseWriter, req *http.Request) {
 	if req.URL.Path == "" {
 		http.NotFound(rw, req)
 	}
-	if u := uc.GetShortened(req.URL.Path); u != nil {
+	if u := uc.GetShortened(req.Context(), req.URL.Path); u != nil {
 		rw.Header().Set("Location", u.Url)
 		rw.WriteHeader(302)
 		return
@@ -1,6 +1,7 @@
 package urldriver
 
 import (
+	"context"
 	"encoding/base64"
 	"flag"
 	"fmt"
@@ -13,23 +14,28 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/urls"
 	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/config"
 	"gopkg.in/mgo.v2/bson"
 )
 
 const shortenPath string = "/s/"
 const cachePath string = "/c/"
-const autoShortenLimit int = 120
 const maxCacheSize = 1 << 22 // 4MB
 
+// Fetching up to maxCacheSize from a slow server can take a while.
+const cacheTimeout = 2 * time.Minute
+
 var badUrlStrings = []string{
 	"4chan",
 }
 
+var autoShortenLimit = config.Int("autoshorten_limit", 120,
+	"URLs longer than this are automatically shortened.")
+
 var urlCacheDir *string = flag.String("url_cache_dir",
 	util.JoinPath(os.Getenv("HOME"), ".sp0rkle"),
 	"Path to store cached content under.")
@@ -39,6 +45,14 @@ var uc *urls.Collection
 // Remember the last url seen on a per-channel basis
 var lastseen = map[string]bson.ObjectId{}
 
+func init() {
+	bot.Register(&bot.Driver{
+		Name:        "urldriver",
+		Description: "Records, shortens and caches URLs.",
+		Init:        Init,
+	})
+}
+
 func Init() {
 	uc = urls.Init()
 
@@ -46,7 +60,7 @@ func Init() {
 		logging.Fatal("Couldn't create URL cache dir: %v", err)
 	}
 
-	bot.Handle(urlScan, client.PRIVMSG)
+	bot.Handle(urlScan, bot.PRIVMSG)
 
 	bot.Command(find, "urlfind", "urlfind <regex>  -- "+
 		"searches for previously mentioned URLs matching <regex>")
@@ -57,19 +71,23 @@ func Init() {
 	bot.Command(find, "url search", "url search <regex>  -- "+
 		"searches for previously mentioned URLs matching <regex>")
 
-	bot.Command(find, "randurl", "randurl  -- displays a random URL")
-	bot.Command(find, "random url", "random url  -- displays a random URL")
+	bot.Command(find, "randurl", "randurl  -- displays a random URL",
+		bot.Pipes())
+	bot.Command(find, "random url", "random url  -- displays a random URL",
+		bot.Pipes())
 
 	bot.Command(shorten, "shorten that", "shorten that  -- "+
 		"shortens the last mentioned URL.")
 	bot.Command(shorten, "shorten", "shorten <url>  -- shortens <url>")
 
 	bot.Command(cache, "cache that", "cache that  -- "+
-		"caches the last mentioned URL.")
-	bot.Command(cache, "cache", "cache <url>  -- caches <url>")
+		"caches the last mentioned URL.", bot.Timeout(cacheTimeout))
+	bot.Command(cache, "cache", "cache <url>  -- caches <url>",
+		bot.Timeout(cacheTimeout))
 	bot.Command(cache, "save that", "save that  -- "+
-		"caches the last mentioned URL.")
-	bot.Command(cache, "save", "save <url>  -- caches <url>")
+		"caches the last mentioned URL.", bot.Timeout(cacheTimeout))
+	bot.Command(cache, "save", "save <url>  -- caches <url>",
+		bot.Timeout(cacheTimeout))
 
 	// This serves "shortened" urls
 	http.Handle(shortenPath, http.StripPrefix(shortenPath,
@@ -80,7 +98,7 @@ func Init() {
 		http.FileServer(http.Dir(*urlCacheDir))))
 }
 
-func Encode(url string) string {
+func Encode(ctx context.Context, url string) string {
 	// We shorten/cache a url with it's base-64 encoded CRC32 hash
 	crc := crc32.ChecksumIEEE([]byte(url))
 	crcb := make([]byte, 4)
@@ -93,8 +111,8 @@ func Encode(url string) string {
 		// resulting in 5 1/3 bytes of encoded data, we can drop
 		// the two padding equals signs for brevity.
 		s := (base64.URLEncoding.EncodeToString(crcb))[:6]
-		cached := uc.GetCached(s)
-		shortened := uc.GetShortened(s)
+		cached := uc.GetCached(ctx, s)
+		shortened := uc.GetShortened(ctx, s)
 		if !(cached.Exists() || shortened.Exists()) {
 			return s
 		}
@@ -104,16 +122,24 @@ func Encode(url string) string {
 	return ""
 }
 
-func Shorten(u *urls.Url) error {
-	u.Shortened = Encode(u.Url)
-	if err := uc.Put(u); err != nil {
+func Shorten(ctx context.Context, u *urls.Url) error {
+	u.Shortened = Encode(ctx, u.Url)
+	if err := uc.Put(ctx, u); err != nil {
 		return err
 	}
 	return nil
 }
 
-func Cache(u *urls.Url) error {
-	u.CachedAs = Encode(u.Url)
+func fetch(ctx context.Context, method, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func Cache(ctx context.Context, u *urls.Url) error {
+	u.CachedAs = Encode(ctx, u.Url)
 	if u.CachedAs == "" {
 		return fmt.Errorf("collided 10 times while encoding URL")
 	}
@@ -123,7 +149,7 @@ func Cache(u *urls.Url) error {
 		}
 	}
 	// Try a HEAD req first to get Content-Length header.
-	res, err := http.Head(u.Url)
+	res, err := fetch(ctx, "HEAD", u.Url)
 	if err != nil {
 		return err
 	}
@@ -137,7 +163,7 @@ func Cache(u *urls.Url) error {
 			return fmt.Errorf("response too large (%d MB) to cache safely", bytes/1024/1024)
 		}
 	}
-	res, err = http.Get(u.Url)
+	res, err = fetch(ctx, "GET", u.Url)
 	if err != nil {
 		return err
 	}
@@ -157,7 +183,7 @@ func Cache(u *urls.Url) error {
 	}
 	u.CacheTime = time.Now()
 	u.MimeType = res.Header.Get("Content-Type")
-	if err := uc.Put(u); err != nil {
+	if err := uc.Put(ctx, u); err != nil {
 		return err
 	}
 	return nil
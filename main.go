@@ -19,18 +19,21 @@ import (
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/db"
-	"github.com/fluffle/sp0rkle/drivers/calcdriver"
-	"github.com/fluffle/sp0rkle/drivers/decisiondriver"
-	"github.com/fluffle/sp0rkle/drivers/factdriver"
-	"github.com/fluffle/sp0rkle/drivers/karmadriver"
-	"github.com/fluffle/sp0rkle/drivers/markovdriver"
-	"github.com/fluffle/sp0rkle/drivers/netdriver"
-	"github.com/fluffle/sp0rkle/drivers/quotedriver"
-	"github.com/fluffle/sp0rkle/drivers/reminddriver"
-	"github.com/fluffle/sp0rkle/drivers/seendriver"
-	"github.com/fluffle/sp0rkle/drivers/statsdriver"
-	"github.com/fluffle/sp0rkle/drivers/urldriver"
+	_ "github.com/fluffle/sp0rkle/drivers/auditdriver"
+	_ "github.com/fluffle/sp0rkle/drivers/calcdriver"
+	_ "github.com/fluffle/sp0rkle/drivers/decisiondriver"
+	_ "github.com/fluffle/sp0rkle/drivers/factdriver"
+	_ "github.com/fluffle/sp0rkle/drivers/karmadriver"
+	_ "github.com/fluffle/sp0rkle/drivers/markovdriver"
+	_ "github.com/fluffle/sp0rkle/drivers/netdriver"
+	_ "github.com/fluffle/sp0rkle/drivers/quotedriver"
+	_ "github.com/fluffle/sp0rkle/drivers/reminddriver"
+	_ "github.com/fluffle/sp0rkle/drivers/seendriver"
+	_ "github.com/fluffle/sp0rkle/drivers/statsdriver"
+	_ "github.com/fluffle/sp0rkle/drivers/urldriver"
+	"github.com/fluffle/sp0rkle/util/config"
 	"github.com/fluffle/sp0rkle/util/datetime"
+	"github.com/fluffle/sp0rkle/util/metrics"
 )
 
 var (
@@ -41,12 +44,28 @@ var (
 	backupDir   = flag.String("backup_dir", "backup", "Where to write BoltDB backups to.")
 	backupEvery = flag.Duration("backup_every", 24*time.Hour, "How often to write backups.")
 	timezone    = flag.String("timezone", "Europe/London", "Default timezone for date/time.")
+	drainHTTP   = flag.Duration("http_drain", 10*time.Second,
+		"How long to wait for HTTP requests to finish when shutting down.")
 )
 
+func init() {
+	// These can be changed in the config file or environment at runtime,
+	// by sending sp0rkle a SIGHUP.
+	// Flags defined with config.Int, config.Duration and config.String,
+	// and the server_config section of the config file, are reloadable too.
+	config.Reloadable("ratelimit_nick", "ratelimit_chan", "ratelimit_cmd",
+		"backup_every")
+}
+
 func main() {
 	flag.Parse()
+	// Config may set logging flags, so load it first but complain later.
+	cfgErr := config.Load()
 	logging.InitFromFlags()
 	golog.Init()
+	if cfgErr != nil {
+		logging.Fatal("Failed to load config: %v", cfgErr)
+	}
 	if err := datetime.SetTZ(*timezone); err != nil {
 		logging.Fatal("Failed to set default timezone from --timezone=%q: %v", *timezone, err)
 	}
@@ -54,8 +73,9 @@ func main() {
 	// Slightly more random than 1.
 	rand.Seed(time.Now().UnixNano() * int64(os.Getpid()))
 
-	// Initialise bot state
-	ctx := context.Background()
+	// Initialise bot state. Cancelling ctx stops anything the bot
+	// started in the background, like reminders.
+	ctx, cancel := context.WithCancel(context.Background())
 	bot.Init(ctx)
 
 	// Connect to databases
@@ -63,49 +83,75 @@ func main() {
 	if err := db.Mongo.Init(bot.GetSecret(*mongoDB)); err != nil {
 		logging.Fatal("Unable to connect to MongoDB at %q: %v", *mongoDB, err)
 	}
-	defer db.Mongo.Close()
 	if err := db.Bolt.Init(*boltDB, *backupDir, *backupEvery); err != nil {
 		logging.Fatal("Unable to open BoltDB file %q: %v", *boltDB, err)
 	}
-	defer db.Bolt.Close()
-
-	// Add drivers
-	calcdriver.Init()
-	decisiondriver.Init()
-	factdriver.Init()
-	karmadriver.Init()
-	markovdriver.Init()
-	netdriver.Init()
-	quotedriver.Init()
-	reminddriver.Init()
-	seendriver.Init()
-	statsdriver.Init()
-	urldriver.Init()
+	config.OnReload(func() { db.Bolt.BackupEvery(*backupEvery) })
+
+	// Load drivers; they register themselves when imported above.
+	if err := bot.LoadDrivers(); err != nil {
+		logging.Fatal("Failed to load drivers: %v", err)
+	}
 
 	// Start up the HTTP server
-	go http.ListenAndServe(*httpPort, nil)
+	http.Handle("/metrics", metrics.Handler())
+	http.Handle("/help", bot.HelpHandler())
+	srv := &http.Server{Addr: *httpPort}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logging.Error("HTTP server failed: %v", err)
+		}
+	}()
 
 	// Set up a signal handler to shut things down gracefully.
-	// NOTE: net/http doesn't provide for graceful shutdown :-/
 	go func() {
 		called := new(int32)
-		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, syscall.SIGINT)
-		for _ = range sigint {
+		sigterm := make(chan os.Signal, 1)
+		signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+		for sig := range sigterm {
 			if atomic.AddInt32(called, 1) > 1 {
-				logging.Fatal("Recieved multiple interrupts, dying.")
+				logging.Fatal("Received multiple signals, dying.")
+			}
+			logging.Info("Received %v, shutting down.", sig)
+			go bot.Shutdown()
+		}
+	}()
+
+	// Reload settings on SIGHUP.
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for _ = range sighup {
+			logging.Info("Received SIGHUP, reloading config.")
+			if err := config.Reload(); err != nil {
+				logging.Error("Failed to reload config: %v", err)
 			}
-			bot.Shutdown()
 		}
 	}()
 
 	// Connect the bot to IRC and wait; reconnects are handled automatically.
+	rebuild := <-bot.Connect()
+
+	// Shut down drivers, stop background work and let HTTP requests
+	// finish before taking a final backup and closing the DBs.
+	status := 0
+	bot.UnloadDrivers()
+	cancel()
+	hctx, hcancel := context.WithTimeout(context.Background(), *drainHTTP)
+	if err := srv.Shutdown(hctx); err != nil {
+		logging.Error("HTTP requests didn't finish in time: %v", err)
+		status = 1
+	}
+	hcancel()
+	if err := db.Bolt.Backup(); err != nil {
+		logging.Error("Final backup failed: %v", err)
+		status = 1
+	}
+	db.Mongo.Close()
+	db.Bolt.Close()
+
 	// If we get true back from the bot, re-exec the (rebuilt) binary.
-	if <-bot.Connect() {
-		// Calling syscall.Exec probably means deferred functions won't get
-		// called, so disconnect from DBs first for politeness' sake.
-		db.Mongo.Close()
-		db.Bolt.Close()
+	if rebuild {
 		// If sp0rkle was run from PATH, we need to do that lookup manually.
 		fq, _ := exec.LookPath(os.Args[0])
 		logging.Warn("Re-executing sp0rkle with args '%v'.", os.Args)
@@ -115,5 +161,10 @@ func main() {
 			logging.Fatal("Couldn't re-exec sp0rkle: %v", err)
 		}
 	}
-	logging.Info("Shutting down cleanly.")
+	if status != 0 {
+		logging.Warn("Shut down with errors.")
+	} else {
+		logging.Info("Shutting down cleanly.")
+	}
+	os.Exit(status)
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest/ircd"
+	"github.com/fluffle/sp0rkle/db"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// TestReminders runs the bot as main does, with reminddriver loaded and
+// BoltDB for storage, against an in-process IRC server.
+func TestReminders(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	s, err := ircd.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	dir, err := os.MkdirTemp("", "sp0rkle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logging.InitFromFlags()
+	datetime.SetTZ("UTC")
+	flag.Set("servers", s.Addr())
+	flag.Set("nick", "sp0rkle")
+	flag.Set("channels", "#test")
+	flag.Set("pause", "50ms")
+	flag.Set("send_delay", "50ms")
+	flag.Set("drivers", "reminddriver")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bot.Init(ctx)
+	db.BoltOnly()
+	if err := db.Bolt.Init(filepath.Join(dir, "sp0rkle.boltdb"),
+		filepath.Join(dir, "backup"), 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Bolt.Close()
+	if err := bot.LoadDrivers(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.UnloadDrivers()
+
+	alice, err := s.Connect("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+	alice.Join("#test")
+	expect := func(u *ircd.User, substr string) {
+		t.Helper()
+		if _, err := u.Expect(substr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := bot.Connect()
+	expect(alice, ":sp0rkle!boing@localhost JOIN #test")
+
+	// Tells are delivered when their target joins a channel.
+	alice.Privmsg("#test", "sp0rkle: tell bob hello")
+	expect(alice, "PRIVMSG #test :alice: okay, i'll tell bob hello when I see them")
+	bob, err := s.Connect("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+	bob.Join("#test")
+	expect(bob, ":sp0rkle!boing@localhost PRIVMSG bob :alice asked me to tell you hello")
+	expect(alice, ":sp0rkle!boing@localhost PRIVMSG #test :bob: alice asked me to tell you hello")
+
+	// Or when someone changes to their nick.
+	alice.Privmsg("#test", "sp0rkle: tell carol hi")
+	expect(alice, "PRIVMSG #test :alice: okay, i'll tell carol hi when I see them")
+	bob.SetNick("carol")
+	expect(alice, ":sp0rkle!boing@localhost PRIVMSG #test :carol: alice asked me to tell you hi")
+	expect(bob, ":sp0rkle!boing@localhost PRIVMSG carol :alice asked me to tell you hi")
+
+	// Reminders fire in the channel they were set in, and at their target.
+	alice.Privmsg("#test", "sp0rkle: remind carol to go home in 1 second")
+	expect(alice, "PRIVMSG #test :alice: okay, i'll remind carol to go home at ")
+	expect(alice, ":sp0rkle!boing@localhost PRIVMSG #test :carol, alice asked me to remind you to go home")
+	expect(bob, ":sp0rkle!boing@localhost PRIVMSG carol :carol, alice asked me to remind you to go home")
+
+	go bot.Shutdown()
+	expect(alice, ":sp0rkle!boing@localhost QUIT :Quit: Shutting down.")
+	select {
+	case <-done:
+	case <-time.After(ircd.Timeout):
+		t.Fatal("timed out waiting for shutdown")
+	}
+}
@@ -0,0 +1,218 @@
+// Package config sets flags from a JSON config file and SP0RKLE_*
+// environment variables, and reloads some of them on request. It is
+// not to be confused with collections/conf, which stores runtime state.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fluffle/golog/logging"
+)
+
+var file *string = flag.String("config", "",
+	"Path to a JSON file mapping flag names to values. Every flag can "+
+		"also be set from an environment variable, e.g. SP0RKLE_HTTP_HOST.")
+
+const envPrefix = "SP0RKLE_"
+
+var (
+	mu         sync.Mutex
+	reloadable = map[string]bool{}
+	hooks      []func()
+	// Flags given on the command line are never overridden.
+	explicit map[string]bool
+
+	// Sections of the config file hold structured settings that aren't
+	// flags, like the list of servers. They're reloaded along with it.
+	sections = map[string]json.RawMessage{}
+)
+
+// EnvName returns the environment variable that sets a flag.
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(
+		"-", "_", ".", "_").Replace(flagName))
+}
+
+// Reloadable marks flags as safe to change while the bot is running.
+// Only OnReload hooks may read them directly; code running elsewhere
+// should use flags defined with Int, Duration or String instead.
+func Reloadable(names ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, n := range names {
+		reloadable[n] = true
+	}
+}
+
+// AddSection makes name a section of the config file, holding JSON to be
+// read with Section rather than a flag value.
+func AddSection(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := sections[name]; !ok {
+		sections[name] = nil
+	}
+}
+
+// Section returns the JSON for the named section of the config file,
+// or nil if it isn't in the file.
+func Section(name string) json.RawMessage {
+	mu.Lock()
+	defer mu.Unlock()
+	return sections[name]
+}
+
+// OnReload registers f to be called after settings are reloaded,
+// so that changes can be acted upon. Hooks are called on every reload,
+// since they may also re-read other files.
+func OnReload(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	hooks = append(hooks, f)
+}
+
+// Load must be called after flag.Parse(). In increasing order of
+// precedence, flags are set from their defaults, the config file,
+// the environment and the command line.
+func Load() error {
+	mu.Lock()
+	defer mu.Unlock()
+	explicit = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return apply(true)
+}
+
+// Reload re-reads the config file and environment and updates any
+// reloadable flags that have changed. Changes to other flags are
+// logged and ignored until restart. Settings removed from the config
+// file keep their current values.
+func Reload() error {
+	mu.Lock()
+	err := apply(false)
+	fns := make([]func(), len(hooks))
+	copy(fns, hooks)
+	mu.Unlock()
+	for _, f := range fns {
+		f()
+	}
+	return err
+}
+
+type setting struct {
+	value, source string
+}
+
+// read collects settings from the config file and environment, and
+// updates sections from the file. It must be called with mu held.
+func read() (map[string]setting, []string) {
+	settings := map[string]setting{}
+	var errs []string
+	if *file != "" {
+		fromFile, err := readFile(*file)
+		if err != nil {
+			// Keep the sections from the last good read.
+			errs = append(errs, err.Error())
+		} else {
+			for name := range sections {
+				sections[name] = nil
+			}
+		}
+		for name, v := range fromFile {
+			if _, ok := sections[name]; ok {
+				sections[name] = json.RawMessage(v)
+				continue
+			}
+			if flag.Lookup(name) == nil || name == "config" {
+				errs = append(errs, fmt.Sprintf("%s: unknown setting %q", *file, name))
+				continue
+			}
+			settings[name] = setting{v, *file}
+		}
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		env := EnvName(f.Name)
+		if v, ok := os.LookupEnv(env); ok && f.Name != "config" {
+			settings[f.Name] = setting{v, "$" + env}
+		}
+	})
+	return settings, errs
+}
+
+// readFile reads a JSON object of flag names to strings, numbers or bools.
+// Sections are returned as JSON, whatever they hold. It must be called
+// with mu held.
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, msg := range raw {
+		if _, ok := sections[name]; ok {
+			values[name] = string(msg)
+			continue
+		}
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(msg))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%s: %q: %v", path, name, err)
+		}
+		switch v := v.(type) {
+		case string:
+			values[name] = v
+		case json.Number, bool:
+			values[name] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s: %q should be a string, number or bool", path, name)
+		}
+	}
+	return values, nil
+}
+
+// apply must be called with mu held.
+func apply(initial bool) error {
+	settings, errs := read()
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, f := settings[name], flag.Lookup(name)
+		if explicit[name] || f.Value.String() == s.value {
+			continue
+		}
+		if !initial && !reloadable[name] {
+			logging.Warn("Setting %q changed in %s, restart to apply it.", name, s.source)
+			continue
+		}
+		old := f.Value.String()
+		if err := flag.Set(name, s.value); err != nil {
+			// Some flag types clobber their value even when Set fails.
+			f.Value.Set(old)
+			errs = append(errs, fmt.Sprintf("%s: bad value %q for %q: %v",
+				s.source, s.value, name, err))
+			continue
+		}
+		if !initial {
+			logging.Info("Setting %q is now %q.", name, s.value)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config errors:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
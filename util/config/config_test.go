@@ -0,0 +1,169 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+)
+
+var (
+	testStatic = flag.String("test_static", "default", "")
+	testReload = flag.Int("test_reload", 1, "")
+	testEnv    = flag.String("test_env", "default", "")
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"http":         "SP0RKLE_HTTP",
+		"backup_every": "SP0RKLE_BACKUP_EVERY",
+		"log.level":    "SP0RKLE_LOG_LEVEL",
+		"send-burst":   "SP0RKLE_SEND_BURST",
+	}
+	for in, exp := range tests {
+		if out := EnvName(in); out != exp {
+			t.Errorf("EnvName(%q): exp %q got %q", in, exp, out)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	path := writeConfig(t, `{"a": "x", "b": 12, "c": true, "d": 1.5}`)
+	values, err := readFile(path)
+	if err != nil {
+		t.Fatalf("readFile: unexpected error %v", err)
+	}
+	exp := map[string]string{"a": "x", "b": "12", "c": "true", "d": "1.5"}
+	if !reflect.DeepEqual(values, exp) {
+		t.Errorf("readFile: exp %v got %v", exp, values)
+	}
+
+	for _, bad := range []string{`{"a": [1]}`, `["a"]`, `{"a":`} {
+		if _, err := readFile(writeConfig(t, bad)); err == nil {
+			t.Errorf("readFile(%s): expected error", bad)
+		}
+	}
+}
+
+func TestLoadAndReload(t *testing.T) {
+	logging.InitFromFlags()
+	*file = writeConfig(t, `{"test_static": "file", "test_reload": 2, "test_env": "file"}`)
+	defer func() { *file = "" }()
+	os.Setenv(EnvName("test_env"), "env")
+	defer os.Unsetenv(EnvName("test_env"))
+	Reloadable("test_reload")
+	called := 0
+	OnReload(func() { called++ })
+
+	if err := Load(); err != nil {
+		t.Fatalf("Load: unexpected error %v", err)
+	}
+	if *testStatic != "file" || *testReload != 2 || *testEnv != "env" {
+		t.Errorf("Load: got %q %d %q", *testStatic, *testReload, *testEnv)
+	}
+
+	*file = writeConfig(t, `{"test_static": "changed", "test_reload": 3}`)
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload: unexpected error %v", err)
+	}
+	if *testStatic != "file" || *testReload != 3 || *testEnv != "env" {
+		t.Errorf("Reload: got %q %d %q", *testStatic, *testReload, *testEnv)
+	}
+	if called != 1 {
+		t.Errorf("Reload: expected hooks to be called once, got %d", called)
+	}
+
+	*file = writeConfig(t, `{"test_reload": "lots", "no_such_flag": 1}`)
+	err := Reload()
+	if err == nil {
+		t.Fatalf("Reload: expected errors")
+	}
+	for _, s := range []string{`bad value "lots"`, `unknown setting "no_such_flag"`} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Reload: expected %q in error, got %v", s, err)
+		}
+	}
+	if *testReload != 3 {
+		t.Errorf("Reload: bad value shouldn't change setting, got %d", *testReload)
+	}
+}
+
+func TestSections(t *testing.T) {
+	logging.InitFromFlags()
+	AddSection("test_section")
+	*file = writeConfig(t, `{"test_section": [{"a": 1}], "test_reload": 4}`)
+	defer func() { *file = "" }()
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload: unexpected error %v", err)
+	}
+	if s := string(Section("test_section")); s != `[{"a": 1}]` {
+		t.Errorf("Section: got %q", s)
+	}
+
+	// A broken file keeps the last good sections.
+	*file = writeConfig(t, `{"test_section": `)
+	if err := Reload(); err == nil {
+		t.Errorf("Reload: expected error")
+	}
+	if Section("test_section") == nil {
+		t.Errorf("Section: lost after bad reload")
+	}
+
+	*file = writeConfig(t, `{}`)
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload: unexpected error %v", err)
+	}
+	if s := Section("test_section"); s != nil {
+		t.Errorf("Section: expected nil after removal, got %q", s)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	i := Int("test_int", 3, "")
+	d := Duration("test_duration", time.Minute, "")
+	s := String("test_string", "x", "")
+	if i.Get() != 3 || d.Get() != time.Minute || s.Get() != "x" {
+		t.Errorf("defaults: got %d %s %q", i.Get(), d.Get(), s.Get())
+	}
+	for name, v := range map[string]string{
+		"test_int": "5", "test_duration": "2s", "test_string": "y"} {
+		if err := flag.Set(name, v); err != nil {
+			t.Errorf("Set(%s): unexpected error %v", name, err)
+		}
+	}
+	if i.Get() != 5 || d.Get() != 2*time.Second || s.Get() != "y" {
+		t.Errorf("Set: got %d %s %q", i.Get(), d.Get(), s.Get())
+	}
+	if flag.Set("test_int", "many") == nil || i.Get() != 5 {
+		t.Errorf("Set: bad int should fail and not change the value")
+	}
+	i.AtLeast(1)
+	d.AtLeast(time.Millisecond)
+	for name, v := range map[string]string{
+		"test_int": "0", "test_duration": "0s"} {
+		if flag.Set(name, v) == nil {
+			t.Errorf("Set(%s): expected %q to be too small", name, v)
+		}
+	}
+	if i.Get() != 5 || d.Get() != 2*time.Second {
+		t.Errorf("AtLeast: got %d %s", i.Get(), d.Get())
+	}
+	if !reloadable["test_duration"] {
+		t.Errorf("Duration: flag not marked reloadable")
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloads set flags from the SIGHUP goroutine while everything else is
+// reading them, so flags that are reloadable and read outside OnReload
+// hooks are defined with these rather than the flag package. Read them
+// with Get().
+
+// An IntFlag is an int flag that is safe to read while it's reloaded.
+type IntFlag struct {
+	v   int64
+	min *int64
+}
+
+// Int defines a reloadable int flag.
+func Int(name string, value int, usage string) *IntFlag {
+	f := &IntFlag{v: int64(value)}
+	flag.Var(f, name, usage)
+	Reloadable(name)
+	return f
+}
+
+// AtLeast makes setting f to less than min an error.
+func (f *IntFlag) AtLeast(min int) *IntFlag {
+	m := int64(min)
+	f.min = &m
+	return f
+}
+
+func (f *IntFlag) Get() int       { return int(atomic.LoadInt64(&f.v)) }
+func (f *IntFlag) String() string { return strconv.Itoa(f.Get()) }
+
+func (f *IntFlag) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return err
+	}
+	if f.min != nil && v < *f.min {
+		return fmt.Errorf("%d is less than %d", v, *f.min)
+	}
+	atomic.StoreInt64(&f.v, v)
+	return nil
+}
+
+// A DurationFlag is a time.Duration flag that is safe to read while
+// it's reloaded.
+type DurationFlag struct {
+	v   int64
+	min *time.Duration
+}
+
+// Duration defines a reloadable time.Duration flag.
+func Duration(name string, value time.Duration, usage string) *DurationFlag {
+	f := &DurationFlag{v: int64(value)}
+	flag.Var(f, name, usage)
+	Reloadable(name)
+	return f
+}
+
+// AtLeast makes setting f to less than min an error.
+func (f *DurationFlag) AtLeast(min time.Duration) *DurationFlag {
+	f.min = &min
+	return f
+}
+
+func (f *DurationFlag) Get() time.Duration { return time.Duration(atomic.LoadInt64(&f.v)) }
+func (f *DurationFlag) String() string     { return f.Get().String() }
+
+func (f *DurationFlag) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if f.min != nil && v < *f.min {
+		return fmt.Errorf("%s is less than %s", v, *f.min)
+	}
+	atomic.StoreInt64(&f.v, int64(v))
+	return nil
+}
+
+// A StringFlag is a string flag that is safe to read while it's reloaded.
+type StringFlag struct {
+	mu sync.RWMutex
+	v  string
+}
+
+// String defines a reloadable string flag.
+func String(name, value, usage string) *StringFlag {
+	f := &StringFlag{v: value}
+	flag.Var(f, name, usage)
+	Reloadable(name)
+	return f
+}
+
+func (f *StringFlag) Get() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.v
+}
+
+func (f *StringFlag) String() string { return f.Get() }
+
+func (f *StringFlag) Set(s string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.v = s
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package metrics keeps counters, gauges and histograms and serves them
+// in the Prometheus text exposition format. Metrics are usually created
+// in package-level vars, and may be split into series by label values.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets suit latencies measured in seconds.
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var registry = struct {
+	sync.Mutex
+	m map[string]*family
+}{m: make(map[string]*family)}
+
+// A family is a named metric and its series, one per set of label values.
+type family struct {
+	sync.Mutex
+	name, help, typ string
+	labels          []string
+	// Upper bounds of histogram buckets, not including +Inf.
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	values []string
+	// Value of a counter or gauge, or sum of a histogram's observations.
+	value float64
+	// Histogram observations in each bucket, the last being +Inf.
+	counts []uint64
+}
+
+func newFamily(name, help, typ string, labels []string) *family {
+	f := &family{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.m[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	registry.m[name] = f
+	return f
+}
+
+// get must be called with f locked.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %q, got values %q",
+			f.name, f.labels, values))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if f.typ == "histogram" {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// A Counter is a value that only goes up.
+type Counter struct{ f *family }
+
+// NewCounter registers a counter with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newFamily(name, help, "counter", labels)}
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to a series.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.f.name + " decreased")
+	}
+	c.f.Lock()
+	defer c.f.Unlock()
+	c.f.get(values).value += v
+}
+
+// A Gauge is a value that can go up and down.
+type Gauge struct{ f *family }
+
+// NewGauge registers a gauge with the given label names.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newFamily(name, help, "gauge", labels)}
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.Lock()
+	defer g.f.Unlock()
+	g.f.get(values).value = v
+}
+
+// Add adds v, which may be negative, to a series.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.Lock()
+	defer g.f.Unlock()
+	g.f.get(values).value += v
+}
+
+// A Histogram counts observations into buckets.
+type Histogram struct{ f *family }
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which must be in increasing order, and label names.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets for " + name + " are not sorted")
+	}
+	f := newFamily(name, help, "histogram", labels)
+	f.buckets = buckets
+	return &Histogram{f}
+}
+
+// Observe records v in the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.Lock()
+	defer h.f.Unlock()
+	s := h.f.get(values)
+	s.value += v
+	s.counts[sort.SearchFloat64s(h.f.buckets, v)]++
+}
+
+// Since observes the seconds elapsed since start.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// Write writes every metric to w in the text exposition format.
+func Write(w io.Writer) error {
+	registry.Lock()
+	fams := make([]*family, 0, len(registry.m))
+	for _, f := range registry.m {
+		fams = append(fams, f)
+	}
+	registry.Unlock()
+	sort.Slice(fams, func(i, j int) bool { return fams[i].name < fams[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fams {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves every metric in the text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+func (f *family) write(w io.Writer) {
+	f.Lock()
+	defer f.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		if f.typ != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelPairs(s.values), formatFloat(s.value))
+			continue
+		}
+		var n uint64
+		for i, c := range s.counts {
+			n += c
+			le := math.Inf(1)
+			if i < len(f.buckets) {
+				le = f.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name,
+				f.labelPairs(s.values, "le", formatFloat(le)), n)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelPairs(s.values), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelPairs(s.values), n)
+	}
+}
+
+// labelPairs formats label values, followed by any extra name/value pairs.
+func (f *family) labelPairs(values []string, extra ...string) string {
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", f.labels[i], escapeValue(v)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extra[i], escapeValue(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeValue(s string) string { return valueEscaper.Replace(s) }
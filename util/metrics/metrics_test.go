@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func output(f *family) string {
+	var b bytes.Buffer
+	f.write(&b)
+	return b.String()
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_counter_total", "Things\\counted.\nTwice.", "cmd")
+	c.Inc("foo")
+	c.Add(2, "foo")
+	c.Inc(`say "hi"`)
+	exp := `# HELP test_counter_total Things\\counted.\nTwice.
+# TYPE test_counter_total counter
+test_counter_total{cmd="foo"} 3
+test_counter_total{cmd="say \"hi\""} 1
+`
+	if out := output(c.f); out != exp {
+		t.Errorf("Counter output:\nexp %q\ngot %q", exp, out)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	g := NewGauge("test_gauge", "A gauge.")
+	g.Set(5)
+	g.Add(-1.5)
+	exp := "# HELP test_gauge A gauge.\n# TYPE test_gauge gauge\ntest_gauge 3.5\n"
+	if out := output(g.f); out != exp {
+		t.Errorf("Gauge output:\nexp %q\ngot %q", exp, out)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_seconds", "Latency.", []float64{0.1, 1}, "op", "db")
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.Observe(v, "get", "bolt")
+	}
+	exp := `# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{op="get",db="bolt",le="0.1"} 2
+test_seconds_bucket{op="get",db="bolt",le="1"} 3
+test_seconds_bucket{op="get",db="bolt",le="+Inf"} 4
+test_seconds_sum{op="get",db="bolt"} 2.65
+test_seconds_count{op="get",db="bolt"} 4
+`
+	if out := output(h.f); out != exp {
+		t.Errorf("Histogram output:\nexp %q\ngot %q", exp, out)
+	}
+}
+
+func TestPanics(t *testing.T) {
+	c := NewCounter("test_panics_total", "", "a", "b")
+	tests := map[string]func(){
+		"duplicate name": func() { NewGauge("test_panics_total", "") },
+		"wrong labels":   func() { c.Inc("a") },
+		"decrease":       func() { c.Add(-1, "a", "b") },
+		"unsorted":       func() { NewHistogram("test_unsorted", "", []float64{2, 1}) },
+	}
+	for name, f := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewCounter("test_handler_total", "Handled.").Inc()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type: got %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "\ntest_handler_total 1\n") {
+		t.Errorf("Handler output missing counter:\n%s", body)
+	}
+}
@@ -2,6 +2,7 @@ package push
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -43,8 +44,8 @@ func config() *oauth2.Config {
 	}
 }
 
-func client(s *pushes.State) *http.Client {
-	return config().Client(oauth2.NoContext, s.Token)
+func client(ctx context.Context, s *pushes.State) *http.Client {
+	return config().Client(ctx, s.Token)
 }
 
 func checkResponseOK(resp *http.Response) error {
@@ -88,14 +89,18 @@ func AuthCodeURL(s *pushes.State) string {
 	return config().AuthCodeURL(s.State())
 }
 
-func Exchange(code string) (*oauth2.Token, error) {
+func Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
 	// Pushbullet don't support passing client secret via http basic auth headers.
-	return config().Exchange(oauth2.NoContext, code)
+	return config().Exchange(ctx, code)
 }
 
-func GetDevices(s *pushes.State) ([]*Device, error) {
+func GetDevices(ctx context.Context, s *pushes.State) ([]*Device, error) {
 	u := pushAPI("/v2/devices")
-	resp, err := client(s).Get(u)
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client(ctx, s).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -113,22 +118,22 @@ func GetDevices(s *pushes.State) ([]*Device, error) {
 	return devs.Devices, nil
 }
 
-func Confirm(s *pushes.State) error {
+func Confirm(ctx context.Context, s *pushes.State) error {
 	if s.CanConfirm() {
-		return push(s, "Pushbullet PIN = "+s.Pin,
+		return push(ctx, s, "Pushbullet PIN = "+s.Pin,
 			"Tell sp0rkle 'push auth <pin>' to complete setup.")
 	}
 	return errors.New("Not in correct state to send confirmation push.")
 }
 
-func Push(s *pushes.State, title, body string) error {
+func Push(ctx context.Context, s *pushes.State, title, body string) error {
 	if s.CanPush() {
-		return push(s, title, body)
+		return push(ctx, s, title, body)
 	}
 	return errors.New("Push not enabled.")
 }
 
-func push(s *pushes.State, title, body string) error {
+func push(ctx context.Context, s *pushes.State, title, body string) error {
 	u := pushAPI("/v2/pushes")
 	enc, err := json.Marshal(&struct {
 		Iden  string `json:"device_iden"`
@@ -139,7 +144,12 @@ func push(s *pushes.State, title, body string) error {
 	if err != nil {
 		return fmt.Errorf("POST %s JSON encode failed: %v", u, err)
 	}
-	resp, err := client(s).Post(u, "application/json", bytes.NewBuffer(enc))
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewBuffer(enc))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client(ctx, s).Do(req)
 	if err != nil {
 		return err
 	}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 func RemovePrefixedNick(text, nick string) (string, bool) {
@@ -36,6 +38,22 @@ func HasPrefixedNick(text, nick string) bool {
 	return prefixed
 }
 
+// RemoveTrigger removes the first of triggers that prefixes text, as long
+// as it is directly followed by a letter or digit, so "!remind" and
+// ".calc 2+2" are triggered but "..." and "!!!" are not.
+func RemoveTrigger(text string, triggers []string) (string, bool) {
+	for _, t := range triggers {
+		if t == "" || len(text) <= len(t) || !strings.HasPrefix(text, t) {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(text[len(t):])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return text[len(t):], true
+		}
+	}
+	return text, false
+}
+
 // Removes mIRC-style colours from a string.
 // These colours match the following BNF notation:
 //   colour ::= idchar | idchar colnum | idchar colnum "," colnum
@@ -207,3 +225,24 @@ func TimeSince(t time.Time) string {
 	}
 	return ""
 }
+
+// ParseDuration extends time.ParseDuration with "d" and "w" units,
+// since people tend to want to do things for days rather than 24h.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 1 {
+		mult := time.Duration(0)
+		switch s[len(s)-1] {
+		case 'd':
+			mult = 24 * time.Hour
+		case 'w':
+			mult = 7 * 24 * time.Hour
+		}
+		if mult > 0 {
+			if n, err := strconv.Atoi(s[:len(s)-1]); err == nil {
+				return time.Duration(n) * mult, nil
+			}
+		}
+	}
+	return time.ParseDuration(s)
+}
@@ -1,6 +1,9 @@
 package util
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 // This is also implicitly testing HasPrefixedNick, I guess...
 func TestHasPrefixedNick(t *testing.T) {
@@ -32,6 +35,32 @@ func TestHasPrefixedNick(t *testing.T) {
 	}
 }
 
+func TestRemoveTrigger(t *testing.T) {
+	triggers := []string{"!", ".", "~~"}
+	tests := []struct {
+		in, out string
+		ok      bool
+	}{
+		{"!remind me", "remind me", true},
+		{".calc 2+2", "calc 2+2", true},
+		{"~~seen foo", "seen foo", true},
+		{"~seen foo", "~seen foo", false},
+		{"...", "...", false},
+		{"!!!", "!!!", false},
+		{"! spaced", "! spaced", false},
+		{"!", "!", false},
+		{"no trigger", "no trigger", false},
+		{"!ñandú", "ñandú", true},
+	}
+	for i, test := range tests {
+		out, ok := RemoveTrigger(test.in, triggers)
+		if out != test.out || ok != test.ok {
+			t.Errorf("RemoveTrigger(%d) %q: exp %q, %t got %q, %t",
+				i, test.in, test.out, test.ok, out, ok)
+		}
+	}
+}
+
 func TestRemoveColours(t *testing.T) {
 	tests := []string{
 		"has no colours",
@@ -207,3 +236,26 @@ func TestFactPointer(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in  string
+		out time.Duration
+		err bool
+	}{
+		{"2h", 2 * time.Hour, false},
+		{"90s", 90 * time.Second, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"3d", 72 * time.Hour, false},
+		{"1w", 168 * time.Hour, false},
+		{"d", 0, true},
+		{"fish", 0, true},
+	}
+	for i, test := range tests {
+		d, err := ParseDuration(test.in)
+		if d != test.out || (err != nil) != test.err {
+			t.Errorf("ParseDuration(%d) %q: exp %s, %t got %s, %v",
+				i, test.in, test.out, test.err, d, err)
+		}
+	}
+}